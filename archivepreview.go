@@ -0,0 +1,333 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Archives are listed in the built-in preview without extracting them.
+// Compressed tar files have to be read from the start, so only the first maxArchiveEntries entries,
+// or as many as can be read before fen.preview_timeout_ms, are listed.
+// The list of files in a zip file is read all at once, so zip files with more than maxZipEntries entries aren't listed
+
+const maxArchiveEntries = 10000
+
+// Checked before reading the list of files, which takes a few hundred bytes of memory per entry
+const maxZipEntries = 100_000
+
+// xz and lzma files say how large a dictionary they need to be decompressed, which is allocated up front
+const maxArchiveDictionaryBytes = 64 * 1024 * 1024
+
+const (
+	ARCHIVE_ZIP      = "zip"
+	ARCHIVE_TAR      = "tar"
+	ARCHIVE_TAR_GZ   = "tar.gz"
+	ARCHIVE_TAR_BZ2  = "tar.bz2"
+	ARCHIVE_TAR_XZ   = "tar.xz"
+	ARCHIVE_TAR_LZMA = "tar.lzma"
+)
+
+// The file extensions of each archive format we can list, these have to be lowercase.
+// Tar files compressed with zstd, lzip or lzop aren't listed since the standard library and xz package can't decompress them
+var archiveFormats = []struct {
+	format     string
+	extensions []string
+}{
+	{ARCHIVE_ZIP, []string{".zip", ".jar", ".kra"}},
+	{ARCHIVE_TAR, []string{".tar"}},
+	{ARCHIVE_TAR_GZ, []string{".tar.gz", ".tgz", ".taz"}},
+	{ARCHIVE_TAR_BZ2, []string{".tar.bz2", ".tb2", ".tbz", ".tbz2", ".tz2"}},
+	{ARCHIVE_TAR_XZ, []string{".tar.xz", ".txz"}},
+	{ARCHIVE_TAR_LZMA, []string{".tar.lzma", ".tlz"}},
+}
+
+var (
+	archiveSummaryStyle = tcell.StyleDefault.Bold(true)
+	archiveNoteStyle    = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	archiveModeStyle    = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	archiveDateStyle    = tcell.StyleDefault.Foreground(tcell.ColorBlue) // Like in the detailed view
+)
+
+// A file or folder in an archive
+type ArchiveEntry struct {
+	Name           string
+	Info           os.FileInfo
+	LinkTarget     string
+	CompressedSize int64 // -1 when only known for the whole archive
+}
+
+// Returns the archive format of path, or false if it can't be listed (like .rar files)
+func ArchiveFormatOf(path string) (string, bool) {
+	lowercasePath := strings.ToLower(path)
+	for _, archiveFormat := range archiveFormats {
+		for _, extension := range archiveFormat.extensions {
+			if strings.HasSuffix(lowercasePath, extension) {
+				return archiveFormat.format, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (job PreviewJob) archivePreview(ctx context.Context, file *os.File, fileSize int64, format string) *PreviewResult {
+	var entries []ArchiveEntry
+	note := ""
+
+	if format == ARCHIVE_ZIP {
+		count, err := ZipEntryCount(file, fileSize)
+		if err != nil {
+			return &PreviewResult{err: err}
+		}
+		if count > maxZipEntries {
+			return &PreviewResult{message: "Zip archive with " + strconv.FormatUint(count, 10) + " entries, too many to list"}
+		}
+
+		// The list of files is at the end of zip files, so we don't need to read all of it
+		zipReader, err := zip.NewReader(file, fileSize)
+		if err != nil {
+			return &PreviewResult{err: err}
+		}
+
+		for _, zipFile := range zipReader.File {
+			if len(entries) == maxArchiveEntries {
+				note = "Only the first " + strconv.Itoa(maxArchiveEntries) + " entries are listed"
+				break
+			}
+			entries = append(entries, ArchiveEntry{Name: zipFile.Name, Info: zipFile.FileInfo(), CompressedSize: int64(zipFile.CompressedSize64)})
+		}
+	} else {
+		// Uncompressed tar files can be seeked through instead of reading every file
+		var reader io.Reader = file
+		if format != ARCHIVE_TAR {
+			var err error
+			reader, err = decompressedArchiveReader(file, fileSize, format)
+			if err != nil {
+				return &PreviewResult{err: err}
+			}
+		}
+
+		tarReader := tar.NewReader(reader)
+		for {
+			if ctx.Err() == context.DeadlineExceeded {
+				note = "Stopped listing after " + job.Timeout.String() + ", see fen.preview_timeout_ms"
+				break
+			} else if ctx.Err() != nil {
+				return job.timedOutResult(ctx)
+			}
+
+			if len(entries) == maxArchiveEntries {
+				note = "Only the first " + strconv.Itoa(maxArchiveEntries) + " entries are listed"
+				break
+			}
+
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				// Show what we could read of a broken or cut off archive
+				if len(entries) == 0 {
+					return &PreviewResult{err: err}
+				}
+				note = "Error reading the rest: " + err.Error()
+				break
+			}
+			entries = append(entries, ArchiveEntry{Name: header.Name, Info: header.FileInfo(), LinkTarget: header.Linkname, CompressedSize: -1})
+		}
+	}
+
+	return NewLinesPreviewResult(ArchiveListingLines(entries, fileSize, note), false)
+}
+
+func decompressedArchiveReader(file io.ReaderAt, fileSize int64, format string) (io.Reader, error) {
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, fileSize))
+	switch format {
+	case ARCHIVE_TAR_GZ:
+		return gzip.NewReader(reader)
+	case ARCHIVE_TAR_BZ2:
+		return bzip2.NewReader(reader), nil
+	case ARCHIVE_TAR_XZ:
+		dictionarySize, err := XZDictionarySize(file, fileSize)
+		if err != nil {
+			return nil, err
+		}
+		if dictionarySize > maxArchiveDictionaryBytes {
+			return nil, errors.New("Needs too much memory to decompress (" + BytesToHumanReadableUnitString(uint64(dictionarySize), 2) + ")")
+		}
+		return xz.NewReader(reader)
+	case ARCHIVE_TAR_LZMA:
+		lzmaReader, err := lzma.ReaderConfig{DictCap: maxArchiveDictionaryBytes}.NewReader(reader)
+		var dictionarySizeError *lzma.ErrDictSize
+		if errors.As(err, &dictionarySizeError) {
+			return nil, errors.New("Needs too much memory to decompress (" + BytesToHumanReadableUnitString(uint64(dictionarySizeError.HeaderDictSize), 2) + ")")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return lzmaReader, nil
+	}
+	panic("Unknown archive format: " + format)
+}
+
+// The entry count from the end of central directory record at the end of a zip file, or the zip64 one it points to
+func ZipEntryCount(file io.ReaderAt, fileSize int64) (uint64, error) {
+	const (
+		endBytes         = 22
+		zip64LocatorSize = 20
+		zip64EndBytes    = 56
+	)
+
+	// The record is followed by a comment of up to 65535 bytes
+	end := make([]byte, min(fileSize, endBytes+65535))
+	_, err := file.ReadAt(end, fileSize-int64(len(end)))
+	if err != nil {
+		return 0, err
+	}
+
+	start := -1
+	for i := len(end) - endBytes; i >= 0; i-- {
+		if string(end[i:i+4]) == "PK\x05\x06" && i+endBytes+int(binary.LittleEndian.Uint16(end[i+20:])) <= len(end) {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return 0, zip.ErrFormat
+	}
+
+	count := uint64(binary.LittleEndian.Uint16(end[start+10:]))
+	if count != 0xffff {
+		return count, nil
+	}
+
+	locatorOffset := fileSize - int64(len(end)-start) - zip64LocatorSize
+	if locatorOffset < 0 {
+		return 0, zip.ErrFormat
+	}
+	locator := make([]byte, zip64LocatorSize)
+	_, err = file.ReadAt(locator, locatorOffset)
+	if err != nil {
+		return 0, err
+	}
+	if string(locator[:4]) != "PK\x06\x07" {
+		return 0, zip.ErrFormat
+	}
+
+	zip64End := make([]byte, zip64EndBytes)
+	zip64EndOffset := binary.LittleEndian.Uint64(locator[8:])
+	if zip64EndOffset > uint64(locatorOffset) {
+		return 0, zip.ErrFormat
+	}
+	_, err = file.ReadAt(zip64End, int64(zip64EndOffset))
+	if err != nil {
+		return 0, err
+	}
+	if string(zip64End[:4]) != "PK\x06\x06" {
+		return 0, zip.ErrFormat
+	}
+	return binary.LittleEndian.Uint64(zip64End[32:]), nil
+}
+
+// A summary line, the note (if any) and a line per entry like "-rw-r--r--  1.2 kB  2024-01-02 15:04  folder/file.txt".
+// The note is set when not every entry was listed, then the archive size isn't compared to the total size
+func ArchiveListingLines(entries []ArchiveEntry, archiveSize int64, note string) []StyledLine {
+	files := 0
+	folders := 0
+	var totalBytes int64
+	var compressedBytes int64
+	sizeWidth := 0
+	for _, entry := range entries {
+		if entry.Info.IsDir() {
+			folders++
+			continue
+		}
+
+		files++
+		totalBytes += entry.Info.Size()
+		compressedBytes += entry.CompressedSize
+		sizeWidth = max(sizeWidth, len(BytesToHumanReadableUnitString(uint64(entry.Info.Size()), 2)))
+	}
+
+	summary := CountText(files, "file") + ", " + CountText(folders, "folder") + ", " + BytesToHumanReadableUnitString(uint64(totalBytes), 2)
+	// Only zip files know the compressed size of each file, for compressed tar files it's the size of the archive
+	if len(entries) > 0 && entries[0].CompressedSize < 0 {
+		compressedBytes = archiveSize
+	}
+	if note != "" {
+		summary = "At least " + summary
+	} else if totalBytes > 0 && compressedBytes < totalBytes {
+		summary += " (" + BytesToHumanReadableUnitString(uint64(compressedBytes), 2) + " compressed, " + strconv.Itoa(int(compressedBytes*100/totalBytes)) + "%)"
+	}
+
+	lines := []StyledLine{{Text: summary, Spans: []StyledSpan{{Start: 0, End: len(summary), Style: archiveSummaryStyle}}}}
+	if note != "" {
+		lines = append(lines, StyledLine{Text: note, Spans: []StyledSpan{{Start: 0, End: len(note), Style: archiveNoteStyle}}})
+	}
+	lines = append(lines, StyledLine{})
+
+	for _, entry := range entries {
+		lines = append(lines, archiveEntryLine(entry, sizeWidth))
+	}
+
+	return lines
+}
+
+func archiveEntryLine(entry ArchiveEntry, sizeWidth int) StyledLine {
+	var builder strings.Builder
+	var spans []StyledSpan
+	addStyled := func(text string, style tcell.Style) {
+		spans = append(spans, StyledSpan{Start: builder.Len(), End: builder.Len() + len(text), Style: style})
+		builder.WriteString(text)
+	}
+
+	mode := entry.Info.Mode()
+	typeCharacter := "-"
+	if mode.IsDir() {
+		typeCharacter = "d"
+	} else if mode&os.ModeSymlink != 0 {
+		typeCharacter = "l"
+	}
+	addStyled(typeCharacter+FilePermissionsString(entry.Info), archiveModeStyle)
+
+	size := ""
+	if !mode.IsDir() {
+		size = BytesToHumanReadableUnitString(uint64(entry.Info.Size()), 2)
+	}
+	builder.WriteString("  " + strings.Repeat(" ", max(0, sizeWidth-len(size))) + size + "  ")
+
+	modified := "                " // Zip files made without a modification time
+	if !entry.Info.ModTime().IsZero() {
+		modified = entry.Info.ModTime().Format("2006-01-02 15:04")
+	}
+	addStyled(modified, archiveDateStyle)
+	builder.WriteString("  ")
+
+	// FileColor() would look at the target of a symlink on our filesystem
+	style := tcell.StyleDefault.Foreground(tcell.ColorTeal)
+	if mode&os.ModeSymlink == 0 {
+		style = FileColor(entry.Info, entry.Name)
+	}
+	addStyled(entry.Name, style)
+
+	if entry.LinkTarget != "" && mode&os.ModeSymlink != 0 {
+		builder.WriteString(" -> " + entry.LinkTarget)
+	}
+
+	return StyledLine{Text: builder.String(), Spans: spans}
+}
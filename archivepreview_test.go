@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/ulikunitz/xz"
+)
+
+func TestArchiveFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"release.zip":         ARCHIVE_ZIP,
+		"app.JAR":             ARCHIVE_ZIP,
+		"source.tar":          ARCHIVE_TAR,
+		"source.tar.gz":       ARCHIVE_TAR_GZ,
+		"source.tgz":          ARCHIVE_TAR_GZ,
+		"source.tar.bz2":      ARCHIVE_TAR_BZ2,
+		"source.tar.xz":       ARCHIVE_TAR_XZ,
+		"/folder.zip/a.tlz":   ARCHIVE_TAR_LZMA,
+		"notes.txt":           "",
+		"compressed.rar":      "",
+		"not-a-tarball.gz":    "",
+		"folder.tar/file.txt": "",
+	}
+
+	for path, expected := range tests {
+		format, ok := ArchiveFormatOf(path)
+		if format != expected || ok != (expected != "") {
+			t.Fatal("Expected \"" + expected + "\" but got \"" + format + "\" for \"" + path + "\"")
+		}
+	}
+}
+
+func TestArchiveListingLines(t *testing.T) {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	modified := time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC)
+	for _, name := range []string{"folder/", "folder/a.txt", "b.txt"} {
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(name, "/") {
+			writer.Write([]byte(strings.Repeat("a", 1000)))
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []ArchiveEntry
+	for _, zipFile := range zipReader.File {
+		entries = append(entries, ArchiveEntry{Name: zipFile.Name, Info: zipFile.FileInfo(), CompressedSize: int64(zipFile.CompressedSize64)})
+	}
+
+	lines := ArchiveListingLines(entries, int64(buffer.Len()), "")
+	if len(lines) != 2+len(entries) {
+		t.Fatal("Expected a summary, an empty line and a line per entry")
+	}
+
+	if !strings.HasPrefix(lines[0].Text, "2 files, 1 folder, 2 kB (") || !strings.HasSuffix(lines[0].Text, "%)") {
+		t.Fatal("Unexpected summary: \"" + lines[0].Text + "\"")
+	}
+
+	if !strings.HasPrefix(lines[2].Text, "d") || !strings.HasSuffix(lines[2].Text, "2024-01-02 15:04  folder/") {
+		t.Fatal("Unexpected folder line: \"" + lines[2].Text + "\"")
+	}
+
+	if !strings.Contains(lines[3].Text, "1 kB") || !strings.HasSuffix(lines[3].Text, "folder/a.txt") {
+		t.Fatal("Unexpected file line: \"" + lines[3].Text + "\"")
+	}
+
+	lines = ArchiveListingLines(entries, int64(buffer.Len()), "Only the first 3 entries are listed")
+	if !strings.HasPrefix(lines[0].Text, "At least ") || strings.Contains(lines[0].Text, "compressed") || lines[1].Text != "Only the first 3 entries are listed" {
+		t.Fatal("Expected the summary to not compare sizes when not every entry was listed")
+	}
+}
+
+func TestDecompressedArchiveReaderDictionarySize(t *testing.T) {
+	// A .lzma header claiming a dictionary of almost 2 GiB and an unknown size, followed by a bit of data
+	lzmaFile := []byte{0x5d, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0}
+	_, err := decompressedArchiveReader(bytes.NewReader(lzmaFile), int64(len(lzmaFile)), ARCHIVE_TAR_LZMA)
+	if err == nil || !strings.HasPrefix(err.Error(), "Needs too much memory") {
+		t.Fatal("Expected an lzma file with a huge dictionary to be rejected")
+	}
+
+	var tarBuffer bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBuffer)
+	tarWriter.WriteHeader(&tar.Header{Name: "file.txt", Mode: 0o644, Size: 5})
+	tarWriter.Write([]byte("hello"))
+	tarWriter.Close()
+
+	var xzStream bytes.Buffer
+	xzWriter, err := xz.NewWriter(&xzStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xzWriter.Write(tarBuffer.Bytes())
+	xzWriter.Close()
+
+	// Two streams with padding between them, like concatenated .xz files
+	xzFile := append(bytes.Clone(xzStream.Bytes()), 0, 0, 0, 0)
+	xzFile = append(xzFile, xzStream.Bytes()...)
+
+	dictionarySize, err := XZDictionarySize(bytes.NewReader(xzFile), int64(len(xzFile)))
+	if err != nil || dictionarySize != 8*1024*1024 {
+		t.Fatal("Expected the default dictionary size of 8 MiB")
+	}
+	_, err = decompressedArchiveReader(bytes.NewReader(xzFile), int64(len(xzFile)), ARCHIVE_TAR_XZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Makes the LZMA2 filter in the first block header claim a 4 GiB dictionary
+	filter := bytes.Index(xzFile[xzHeaderBytes:], []byte{xzFilterLZMA2, 0x01})
+	if filter == -1 {
+		t.Fatal("No LZMA2 filter in the xz block header")
+	}
+	xzFile[xzHeaderBytes+filter+2] = 40
+
+	_, err = decompressedArchiveReader(bytes.NewReader(xzFile), int64(len(xzFile)), ARCHIVE_TAR_XZ)
+	if err == nil || !strings.HasPrefix(err.Error(), "Needs too much memory") {
+		t.Fatal("Expected an xz file with a huge dictionary to be rejected")
+	}
+}
+
+// Records what would be drawn, since a tcell.SimulationScreen hides control characters
+type recordingScreen struct {
+	tcell.Screen
+	runes []rune
+}
+
+func (screen *recordingScreen) SetContent(x, y int, primary rune, combining []rune, style tcell.Style) {
+	screen.runes = append(screen.runes, primary)
+}
+
+func TestArchiveListingControlCharacters(t *testing.T) {
+	// Would set the terminal title if written to the terminal as it is
+	header := &zip.FileHeader{Name: "evil\x1b]0;title\x07.txt"}
+	entries := []ArchiveEntry{{Name: header.Name, Info: header.FileInfo(), CompressedSize: 0}}
+
+	screen := &recordingScreen{}
+	for y, line := range ArchiveListingLines(entries, 100, "") {
+		line.Draw(screen, 0, y, 80, 0)
+	}
+
+	text := string(screen.runes)
+	if strings.ContainsAny(text, "\x1b\x07") {
+		t.Fatal("Expected control characters to not be drawn")
+	}
+	if !strings.Contains(text, "evil?]0;title?.txt") {
+		t.Fatal("Expected control characters to be drawn as '?'")
+	}
+}
+
+func zipWithEntries(t *testing.T, count int, comment string) []byte {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for i := 0; i < count; i++ {
+		_, err := zipWriter.CreateHeader(&zip.FileHeader{Name: strconv.Itoa(i), Method: zip.Store})
+		if err != nil {
+			t.Fatal("Failed to add a file to the zip file: " + err.Error())
+		}
+	}
+	zipWriter.SetComment(comment)
+	err := zipWriter.Close()
+	if err != nil {
+		t.Fatal("Failed to write the zip file: " + err.Error())
+	}
+	return buffer.Bytes()
+}
+
+func TestZipEntryCount(t *testing.T) {
+	data := zipWithEntries(t, 3, "PK\x05\x06 in the comment")
+	count, err := ZipEntryCount(bytes.NewReader(data), int64(len(data)))
+	if err != nil || count != 3 {
+		t.Fatal("Expected 3 entries, but got " + strconv.FormatUint(count, 10))
+	}
+
+	// More than 65535 entries are only in the zip64 record
+	data = zipWithEntries(t, maxZipEntries+1, "")
+	count, err = ZipEntryCount(bytes.NewReader(data), int64(len(data)))
+	if err != nil || count != maxZipEntries+1 {
+		t.Fatal("Expected " + strconv.Itoa(maxZipEntries+1) + " entries, but got " + strconv.FormatUint(count, 10))
+	}
+
+	path := filepath.Join(t.TempDir(), "file.zip")
+	err = os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatal("Failed to write " + path + ": " + err.Error())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal("Failed to open " + path + ": " + err.Error())
+	}
+	defer file.Close()
+
+	result := PreviewJob{Path: path}.archivePreview(context.Background(), file, int64(len(data)), ARCHIVE_ZIP)
+	if result.message != "Zip archive with "+strconv.Itoa(maxZipEntries+1)+" entries, too many to list" {
+		t.Fatal("Expected the zip file to not be listed, but got \"" + result.message + "\"")
+	}
+
+	_, err = ZipEntryCount(bytes.NewReader([]byte("not a zip file")), 14)
+	if err == nil {
+		t.Fatal("Expected an error for a file which isn't a zip file")
+	}
+}
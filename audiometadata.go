@@ -0,0 +1,670 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Tags and the duration of audio files for the built-in preview.
+// MP3 files have ID3 tags, FLAC and Ogg files have Vorbis comments and WAV files have a LIST chunk.
+// M4A files are MP4 files, see mp4.go
+
+// Tags with cover art can be large, we don't read more than this of them
+const maxAudioTagBytes = 16 * 1024 * 1024
+
+// The WAV fmt chunk is 16, 18 or 40 bytes, larger ones are skipped
+const maxWAVFormatBytes = 1024
+
+// The ID3v2 frames we show, https://id3.org/id3v2.3.0#Text_information_frames. ID3v2.2 has 3 letter names
+var id3FrameLabels = map[string]string{
+	"TIT2": "Title", "TT2": "Title",
+	"TPE1": "Artist", "TP1": "Artist",
+	"TALB": "Album", "TAL": "Album",
+	"TPE2": "Album artist", "TP2": "Album artist",
+	"TRCK": "Track", "TRK": "Track",
+	"TYER": "Date", "TDRC": "Date", "TYE": "Date",
+	"TCON": "Genre", "TCO": "Genre",
+}
+
+// Vorbis comment names, https://xiph.org/vorbis/doc/v-comment.html
+var vorbisCommentLabels = map[string]string{
+	"TITLE":       "Title",
+	"ARTIST":      "Artist",
+	"ALBUM":       "Album",
+	"ALBUMARTIST": "Album artist",
+	"TRACKNUMBER": "Track",
+	"DATE":        "Date",
+	"GENRE":       "Genre",
+}
+
+// WAV LIST INFO chunk names
+var wavInfoLabels = map[string]string{
+	"INAM": "Title",
+	"IART": "Artist",
+	"IPRD": "Album",
+	"ITRK": "Track",
+	"ICRD": "Date",
+	"IGNR": "Genre",
+}
+
+// In kbps, by MPEG version 1 or 2 (and 2.5), layer and the bitrate index in a frame header
+var mpegBitrates = [2][3][15]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+var mpeg1SampleRates = [3]int{44100, 48000, 32000}
+
+// An MPEG audio frame header
+type mpegFrame struct {
+	version    int // 1 or 2, MPEG 2.5 is counted as 2
+	layer      int
+	bitrate    int // In kbps
+	sampleRate int
+	mono       bool
+}
+
+func parseMPEGFrameHeader(header []byte) (mpegFrame, bool) {
+	if len(header) < 4 || header[0] != 0xff || header[1]&0xe0 != 0xe0 {
+		return mpegFrame{}, false
+	}
+
+	versionBits := header[1] >> 3 & 3
+	layerBits := header[1] >> 1 & 3
+	bitrateIndex := header[2] >> 4
+	sampleRateIndex := header[2] >> 2 & 3
+	if versionBits == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mpegFrame{}, false
+	}
+
+	frame := mpegFrame{version: 1, layer: 4 - int(layerBits), mono: header[3]>>6 == 3}
+	frame.sampleRate = mpeg1SampleRates[sampleRateIndex]
+	switch versionBits {
+	case 2: // MPEG 2
+		frame.version = 2
+		frame.sampleRate /= 2
+	case 0: // MPEG 2.5
+		frame.version = 2
+		frame.sampleRate /= 4
+	}
+	frame.bitrate = mpegBitrates[frame.version-1][frame.layer-1][bitrateIndex]
+	return frame, true
+}
+
+func (frame mpegFrame) samplesPerFrame() int {
+	switch {
+	case frame.layer == 1:
+		return 384
+	case frame.layer == 3 && frame.version == 2:
+		return 576
+	}
+	return 1152
+}
+
+func MP3Info(file io.ReadSeeker, fileSize int64) (MediaInfo, error) {
+	tags, audioStart, err := readID3v2(file)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	// The first frame, after any padding
+	_, err = file.Seek(audioStart, io.SeekStart)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	data := make([]byte, 64*1024)
+	n, err := io.ReadFull(file, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return MediaInfo{}, err
+	}
+	data = data[:n]
+
+	var frame mpegFrame
+	frameStart := -1
+	for i := 0; i+4 <= len(data); i++ {
+		var ok bool
+		frame, ok = parseMPEGFrameHeader(data[i:])
+		if ok {
+			frameStart = i
+			break
+		}
+	}
+	if frameStart == -1 {
+		return MediaInfo{}, errors.New("No MPEG audio frame found")
+	}
+
+	audioBytes := fileSize - audioStart - int64(frameStart)
+	if id3v1 := readID3v1(file, fileSize); id3v1 != nil {
+		audioBytes -= 128
+		for label, value := range id3v1 {
+			if tags[label] == "" {
+				tags[label] = value
+			}
+		}
+	}
+
+	// Variable bitrate files have the amount of frames in a "Xing" or "Info" header, in the place of the audio data of the first frame
+	sideInfoSize := 32
+	if frame.version == 2 && frame.mono {
+		sideInfoSize = 9
+	} else if frame.version == 2 || frame.mono {
+		sideInfoSize = 17
+	}
+
+	frames := 0
+	xing := data[min(len(data), frameStart+4+sideInfoSize):]
+	vbri := data[min(len(data), frameStart+4+32):]
+	if len(xing) >= 12 && (string(xing[:4]) == "Xing" || string(xing[:4]) == "Info") && binary.BigEndian.Uint32(xing[4:])&1 != 0 {
+		frames = int(binary.BigEndian.Uint32(xing[8:]))
+	} else if len(vbri) >= 18 && string(vbri[:4]) == "VBRI" {
+		frames = int(binary.BigEndian.Uint32(vbri[14:]))
+	}
+
+	var seconds float64
+	bitrate := frame.bitrate
+	if frames > 0 {
+		seconds = float64(frames*frame.samplesPerFrame()) / float64(frame.sampleRate)
+		if seconds > 0 {
+			bitrate = int(float64(audioBytes) * 8 / seconds / 1000)
+		}
+	} else {
+		seconds = float64(audioBytes) * 8 / float64(frame.bitrate*1000)
+	}
+
+	channels := 2
+	if frame.mono {
+		channels = 1
+	}
+
+	formatName := "MP" + strconv.Itoa(frame.layer)
+	return MediaInfo{
+		Summary: joinNonEmpty(formatName, MediaDurationString(seconds), strconv.Itoa(bitrate)+" kbps", SampleRateString(frame.sampleRate), ChannelsString(channels)),
+		Details: mediaTagDetails(tags),
+	}, nil
+}
+
+// Returns the tags and where the audio starts, which is 0 without an ID3v2 tag
+func readID3v2(file io.ReadSeeker) (map[string]string, int64, error) {
+	tags := make(map[string]string)
+
+	header := make([]byte, 10)
+	_, err := io.ReadFull(file, header)
+	if err != nil {
+		return nil, 0, err
+	}
+	if string(header[:3]) != "ID3" {
+		return tags, 0, nil
+	}
+
+	majorVersion := header[3]
+	flags := header[5]
+	size := int64(syncsafeInt(header[6:10]))
+	audioStart := 10 + size
+	if flags&0x10 != 0 { // Footer
+		audioStart += 10
+	}
+
+	tag := make([]byte, min(size, maxAudioTagBytes))
+	_, err = io.ReadFull(file, tag)
+	if err != nil {
+		return tags, audioStart, nil
+	}
+
+	// Extended header
+	if flags&0x40 != 0 && len(tag) >= 4 {
+		extendedSize := int(binary.BigEndian.Uint32(tag))
+		if majorVersion == 3 {
+			extendedSize += 4
+		} else {
+			extendedSize = syncsafeInt(tag)
+		}
+		tag = tag[min(len(tag), extendedSize):]
+	}
+
+	nameSize, headerSize := 4, 10
+	if majorVersion == 2 {
+		nameSize, headerSize = 3, 6
+	}
+
+	for len(tag) >= headerSize && tag[0] != 0 {
+		name := string(tag[:nameSize])
+
+		var frameSize int
+		switch majorVersion {
+		case 2:
+			frameSize = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(tag[4:]))
+		default:
+			frameSize = syncsafeInt(tag[4:8])
+		}
+		if frameSize < 0 || headerSize+frameSize > len(tag) {
+			break
+		}
+
+		if label, ok := id3FrameLabels[name]; ok && tags[label] == "" {
+			tags[label] = id3Text(tag[headerSize : headerSize+frameSize])
+		}
+		tag = tag[headerSize+frameSize:]
+	}
+
+	return tags, audioStart, nil
+}
+
+// 7 bits from each of the 4 bytes
+func syncsafeInt(data []byte) int {
+	return int(data[0]&0x7f)<<21 | int(data[1]&0x7f)<<14 | int(data[2]&0x7f)<<7 | int(data[3]&0x7f)
+}
+
+// Text frames start with their encoding, and can have several values separated by null characters
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	var text string
+	encoding, data := data[0], data[1:]
+	switch encoding {
+	case 0: // ISO-8859-1
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	case 1, 2: // UTF-16 with a byte order mark, and UTF-16BE
+		var order binary.ByteOrder = binary.BigEndian
+		if len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe {
+			order = binary.LittleEndian
+		}
+
+		var units []uint16
+		for i := 0; i+2 <= len(data); i += 2 {
+			unit := order.Uint16(data[i:])
+			if unit == 0xfeff && (encoding == 1 || i == 0) {
+				continue
+			}
+			units = append(units, unit)
+		}
+		text = string(utf16.Decode(units))
+	default: // UTF-8
+		text = strings.ToValidUTF8(string(data), "")
+	}
+
+	var values []string
+	for _, value := range strings.Split(text, "\x00") {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// The older tag at the end of the file, nil if there is none
+func readID3v1(file io.ReadSeeker, fileSize int64) map[string]string {
+	if fileSize < 128 {
+		return nil
+	}
+
+	_, err := file.Seek(fileSize-128, io.SeekStart)
+	if err != nil {
+		return nil
+	}
+	tag := make([]byte, 128)
+	_, err = io.ReadFull(file, tag)
+	if err != nil || string(tag[:3]) != "TAG" {
+		return nil
+	}
+
+	field := func(data []byte) string {
+		return id3Text(append([]byte{0}, bytes.TrimRight(data, "\x00 ")...))
+	}
+
+	tags := map[string]string{
+		"Title":  field(tag[3:33]),
+		"Artist": field(tag[33:63]),
+		"Album":  field(tag[63:93]),
+		"Date":   field(tag[93:97]),
+	}
+	// ID3v1.1 has the track number at the end of the comment
+	if tag[125] == 0 && tag[126] != 0 {
+		tags["Track"] = strconv.Itoa(int(tag[126]))
+	}
+	return tags
+}
+
+// Adds the "NAME=value" comments in data to tags, data starts with the vendor string
+func readVorbisComments(data []byte, tags map[string]string) {
+	readString := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		length := int(binary.LittleEndian.Uint32(data))
+		if length > len(data)-4 {
+			return "", false
+		}
+		text := string(data[4 : 4+length])
+		data = data[4+length:]
+		return text, true
+	}
+
+	if _, ok := readString(); !ok || len(data) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+
+	for i := 0; i < count; i++ {
+		comment, ok := readString()
+		if !ok {
+			return
+		}
+		name, value, found := strings.Cut(comment, "=")
+		label, known := vorbisCommentLabels[strings.ToUpper(name)]
+		if found && known && tags[label] == "" {
+			tags[label] = strings.ToValidUTF8(value, "")
+		}
+	}
+}
+
+func FLACInfo(file io.ReadSeeker) (MediaInfo, error) {
+	_, err := file.Seek(4, io.SeekStart)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	tags := make(map[string]string)
+	var sampleRate, channels, bitsPerSample int
+	var totalSamples int64
+
+	header := make([]byte, 4)
+	for {
+		_, err = io.ReadFull(file, header)
+		if err != nil {
+			return MediaInfo{}, err
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch blockType {
+		case 0: // STREAMINFO
+			block := make([]byte, length)
+			_, err = io.ReadFull(file, block)
+			if err != nil || len(block) < 18 {
+				return MediaInfo{}, errors.New("Invalid FLAC STREAMINFO block")
+			}
+			// 20 bits sample rate, 3 bits channels - 1, 5 bits bits per sample - 1 and 36 bits total samples
+			packed := binary.BigEndian.Uint64(block[10:])
+			sampleRate = int(packed >> 44)
+			channels = int(packed>>41&7) + 1
+			bitsPerSample = int(packed>>36&0x1f) + 1
+			totalSamples = int64(packed & 0xfffffffff)
+		case 4: // VORBIS_COMMENT
+			block := make([]byte, length)
+			_, err = io.ReadFull(file, block)
+			if err != nil {
+				return MediaInfo{}, err
+			}
+			readVorbisComments(block, tags)
+		default:
+			_, err = file.Seek(length, io.SeekCurrent)
+			if err != nil {
+				return MediaInfo{}, err
+			}
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if sampleRate == 0 {
+		return MediaInfo{}, errors.New("No FLAC STREAMINFO block")
+	}
+
+	duration := ""
+	if totalSamples > 0 {
+		duration = MediaDurationString(float64(totalSamples) / float64(sampleRate))
+	}
+
+	return MediaInfo{
+		Summary: joinNonEmpty("FLAC", duration, SampleRateString(sampleRate), strconv.Itoa(bitsPerSample)+"-bit", ChannelsString(channels)),
+		Details: mediaTagDetails(tags),
+	}, nil
+}
+
+// Reads the first packets of the first stream in an Ogg file, packets can be split across pages
+func readOggPackets(file io.ReadSeeker, count int) ([][]byte, uint32, error) {
+	var packets [][]byte
+	var packet []byte
+	var serial uint32
+
+	header := make([]byte, 27)
+	for len(packets) < count {
+		_, err := io.ReadFull(file, header)
+		if err != nil {
+			return packets, serial, err
+		}
+		if string(header[:4]) != "OggS" {
+			return packets, serial, errors.New("Invalid Ogg page")
+		}
+
+		pageSerial := binary.LittleEndian.Uint32(header[14:])
+		if len(packets) == 0 && packet == nil {
+			serial = pageSerial
+		}
+
+		segmentTable := make([]byte, header[26])
+		_, err = io.ReadFull(file, segmentTable)
+		if err != nil {
+			return packets, serial, err
+		}
+
+		pageSize := 0
+		for _, segmentSize := range segmentTable {
+			pageSize += int(segmentSize)
+		}
+		page := make([]byte, pageSize)
+		_, err = io.ReadFull(file, page)
+		if err != nil {
+			return packets, serial, err
+		}
+
+		// Pages of other streams, like the video in an Ogg video file
+		if pageSerial != serial {
+			continue
+		}
+
+		// A packet ends with a segment shorter than 255 bytes
+		for _, segmentSize := range segmentTable {
+			packet = append(packet, page[:segmentSize]...)
+			page = page[segmentSize:]
+			if len(packet) > maxAudioTagBytes {
+				return packets, serial, errors.New("Ogg packet too large")
+			}
+			if segmentSize < 255 {
+				packets = append(packets, packet)
+				packet = []byte{}
+			}
+		}
+	}
+	return packets, serial, nil
+}
+
+// The granule position of the last page of the stream, which is the amount of samples in it
+func lastOggGranule(file io.ReadSeeker, fileSize int64, serial uint32) int64 {
+	start := max(0, fileSize-64*1024)
+	_, err := file.Seek(start, io.SeekStart)
+	if err != nil {
+		return -1
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return -1
+	}
+
+	for end := len(data); end > 0; {
+		i := bytes.LastIndex(data[:end], []byte("OggS"))
+		if i == -1 || i+27 > len(data) {
+			return -1
+		}
+		if binary.LittleEndian.Uint32(data[i+14:]) == serial {
+			return int64(binary.LittleEndian.Uint64(data[i+6:]))
+		}
+		end = i
+	}
+	return -1
+}
+
+func OggInfo(file io.ReadSeeker, fileSize int64) (MediaInfo, error) {
+	packets, serial, err := readOggPackets(file, 2)
+	if len(packets) == 0 {
+		return MediaInfo{}, err
+	}
+
+	tags := make(map[string]string)
+	identification := packets[0]
+	var codec string
+	var sampleRate, originalSampleRate, channels, preSkip int
+	switch {
+	case len(identification) >= 16 && string(identification[:7]) == "\x01vorbis":
+		codec = "Ogg Vorbis"
+		channels = int(identification[11])
+		sampleRate = int(binary.LittleEndian.Uint32(identification[12:]))
+		originalSampleRate = sampleRate
+		if len(packets) > 1 && bytes.HasPrefix(packets[1], []byte("\x03vorbis")) {
+			readVorbisComments(packets[1][7:], tags)
+		}
+	case len(identification) >= 16 && string(identification[:8]) == "OpusHead":
+		// Opus is always decoded at 48 kHz, the sample rate of the original audio is only informational and can be 0
+		codec = "Ogg Opus"
+		channels = int(identification[9])
+		preSkip = int(binary.LittleEndian.Uint16(identification[10:]))
+		sampleRate = 48000
+		originalSampleRate = int(binary.LittleEndian.Uint32(identification[12:]))
+		if len(packets) > 1 && bytes.HasPrefix(packets[1], []byte("OpusTags")) {
+			readVorbisComments(packets[1][8:], tags)
+		}
+	default:
+		return MediaInfo{}, errors.New("Unknown Ogg codec")
+	}
+
+	duration := ""
+	if granule := lastOggGranule(file, fileSize, serial); granule > 0 && sampleRate > 0 {
+		duration = MediaDurationString(float64(granule-int64(preSkip)) / float64(sampleRate))
+	}
+
+	sampleRateText := ""
+	if originalSampleRate > 0 {
+		sampleRateText = SampleRateString(originalSampleRate)
+	}
+
+	return MediaInfo{
+		Summary: joinNonEmpty(codec, duration, sampleRateText, ChannelsString(channels)),
+		Details: mediaTagDetails(tags),
+	}, nil
+}
+
+func WAVInfo(file io.ReadSeeker) (MediaInfo, error) {
+	_, err := file.Seek(12, io.SeekStart)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	tags := make(map[string]string)
+	var format, channels, bitsPerSample int
+	var sampleRate, byteRate int
+	var dataSize int64 = -1
+
+	header := make([]byte, 8)
+	for {
+		_, err = io.ReadFull(file, header)
+		if err != nil {
+			break
+		}
+		name := string(header[:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:]))
+		paddedSize := size + size%2
+
+		switch {
+		case name == "fmt " && size >= 16 && size <= maxWAVFormatBytes:
+			chunk := make([]byte, size)
+			_, err = io.ReadFull(file, chunk)
+			if err != nil {
+				return MediaInfo{}, err
+			}
+			format = int(binary.LittleEndian.Uint16(chunk))
+			channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(chunk[4:]))
+			byteRate = int(binary.LittleEndian.Uint32(chunk[8:]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:]))
+			paddedSize -= size
+		case name == "LIST" && size >= 4 && size <= maxAudioTagBytes:
+			chunk := make([]byte, size)
+			_, err = io.ReadFull(file, chunk)
+			if err != nil {
+				return MediaInfo{}, err
+			}
+			if string(chunk[:4]) == "INFO" {
+				readWAVInfo(chunk[4:], tags)
+			}
+			paddedSize -= size
+		case name == "data":
+			dataSize = size
+		}
+
+		_, err = file.Seek(paddedSize, io.SeekCurrent)
+		if err != nil {
+			break
+		}
+	}
+
+	if sampleRate == 0 {
+		return MediaInfo{}, errors.New("No WAV fmt chunk")
+	}
+
+	duration := ""
+	if dataSize >= 0 && byteRate > 0 {
+		duration = MediaDurationString(float64(dataSize) / float64(byteRate))
+	}
+
+	// 3 is IEEE float, 0xfffe can be either but is usually integer samples
+	bits := strconv.Itoa(bitsPerSample) + "-bit"
+	if format == 3 {
+		bits += " float"
+	}
+
+	return MediaInfo{
+		Summary: joinNonEmpty("WAV", duration, SampleRateString(sampleRate), bits, ChannelsString(channels)),
+		Details: mediaTagDetails(tags),
+	}, nil
+}
+
+func readWAVInfo(data []byte, tags map[string]string) {
+	for len(data) >= 8 {
+		name := string(data[:4])
+		size := int(binary.LittleEndian.Uint32(data[4:]))
+		if size > len(data)-8 {
+			return
+		}
+
+		if label, ok := wavInfoLabels[name]; ok {
+			tags[label] = strings.ToValidUTF8(strings.TrimRight(string(data[8:8+size]), "\x00"), "")
+		}
+		data = data[min(len(data), 8+size+size%2):]
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// With fen.built_in_previews enabled, files without a matching fen.preview entry are previewed by fen itself.
+// Text files are shown with syntax highlighting (see syntaxhighlight.go), images with colored blocks and their EXIF metadata (see imagepreview.go), audio and video files with their tags and streams (see mediapreview.go), archives as a list of their files (see archivepreview.go), JSON, YAML, TOML, CSV and TSV files pretty-printed or as a table (see structuredpreview.go), executables with their format and dependencies (see executablepreview.go) and other binary files as a hex dump (see hexdump.go)
+
+const builtInPreviewTabWidth = 4
+
+var (
+	infoHeadingStyle = tcell.StyleDefault.Bold(true)
+	infoLabelStyle   = tcell.StyleDefault.Foreground(tcell.ColorGray)
+)
+
+// How much of a file is checked for looking like a binary file, like git does
+const binaryDetectionBytes = 8000
+
+// Larger text files are only partly shown, even with fen.preview_max_output_bytes = 0
+const maxBuiltInPreviewBytes = 16 * 1024 * 1024
+
+// A line of a built-in preview. Spans are sorted and don't overlap, the text between them uses the default style
+type StyledLine struct {
+	Text  string
+	Spans []StyledSpan
+}
+
+type StyledSpan struct {
+	Start int // Byte offsets into StyledLine.Text
+	End   int
+	Style tcell.Style
+}
+
+// The width of the line on screen
+func (line StyledLine) Width() int {
+	return runewidth.StringWidth(line.Text)
+}
+
+// Draws the line from column scrollX, cut off after width columns.
+// Control characters are shown as '?', since they would be written to the terminal as they are (like escape sequences in file names or tags)
+func (line StyledLine) Draw(screen tcell.Screen, x, y, width, scrollX int) {
+	column := 0
+	spanIndex := 0
+	for i, r := range line.Text {
+		if unicode.IsControl(r) {
+			r = '?'
+		}
+
+		for spanIndex < len(line.Spans) && line.Spans[spanIndex].End <= i {
+			spanIndex++
+		}
+
+		style := tcell.StyleDefault
+		if spanIndex < len(line.Spans) && line.Spans[spanIndex].Start <= i {
+			style = line.Spans[spanIndex].Style
+		}
+
+		runeWidth := runewidth.RuneWidth(r)
+		if column >= scrollX {
+			if column-scrollX+runeWidth > width {
+				break
+			}
+			screen.SetContent(x+column-scrollX, y, r, nil, style)
+		}
+		column += runeWidth
+	}
+}
+
+// Returns true if data has a null byte or mostly control characters, data should be the start of a file
+func LooksBinary(data []byte) bool {
+	if bytes.IndexByte(data, 0) != -1 {
+		return true
+	}
+
+	controlCharacters := 0
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' && b != '\b' && b != 0x1b {
+			controlCharacters++
+		}
+	}
+
+	return controlCharacters > len(data)/10
+}
+
+// Replaces tabs with spaces up to the next tab stop
+func ExpandTabs(line string, tabWidth int) string {
+	if !strings.ContainsRune(line, '\t') {
+		return line
+	}
+
+	var builder strings.Builder
+	column := 0
+	for _, r := range line {
+		if r == '\t' {
+			spaces := tabWidth - column%tabWidth
+			builder.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		}
+		builder.WriteRune(r)
+		column += runewidth.RuneWidth(r)
+	}
+	return builder.String()
+}
+
+// Splits text into lines with tabs expanded, invisible control characters are shown as '?'
+func TextPreviewLines(text string) []string {
+	text = strings.ToValidUTF8(text, string(utf8.RuneError))
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		line = strings.Map(func(r rune) rune {
+			if r != '\t' && unicode.IsControl(r) {
+				return '?'
+			}
+			return r
+		}, line)
+		lines[i] = ExpandTabs(line, builtInPreviewTabWidth)
+	}
+	return lines
+}
+
+func (job PreviewJob) runBuiltIn(ctx context.Context) (result *PreviewResult) {
+	// A bug in one of the file format parsers is shown as an error instead of crashing fen
+	defer func() {
+		if r := recover(); r != nil {
+			result = &PreviewResult{err: errors.New("Built-in preview failed: " + fmt.Sprint(r))}
+		}
+	}()
+
+	file, err := os.Open(job.Path)
+	if err != nil {
+		return &PreviewResult{err: err}
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return &PreviewResult{err: err}
+	}
+
+	if format, ok := ArchiveFormatOf(job.Path); ok {
+		return job.archivePreview(ctx, file, stat.Size(), format)
+	}
+
+	if IsBuiltInImage(job.Path) {
+		result, ok := job.imagePreview(ctx, file)
+		if ok {
+			return result
+		}
+
+		_, err = file.Seek(0, 0)
+		if err != nil {
+			return &PreviewResult{err: err}
+		}
+	}
+
+	if IsMediaFile(job.Path) {
+		result, ok := job.mediaPreview(file, stat.Size())
+		if ok {
+			return result
+		}
+
+		_, err = file.Seek(0, 0)
+		if err != nil {
+			return &PreviewResult{err: err}
+		}
+	}
+
+	head := make([]byte, binaryDetectionBytes)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return &PreviewResult{err: err}
+	}
+	head = head[:n]
+
+	if LooksBinary(head) {
+		if format := ExecutableFormatOf(head); format != "" {
+			result, ok := job.executablePreview(file, format)
+			if ok {
+				return result
+			}
+		}
+		return job.hexDumpPreview(file, stat.Size())
+	}
+
+	if job.Follow && stat.Size() > int64(job.textPreviewBytes()) {
+		return job.tailPreview(ctx, file, stat.Size())
+	}
+
+	rest, err := io.ReadAll(io.LimitReader(file, int64(max(0, job.textPreviewBytes()-len(head)))))
+	if err != nil {
+		return &PreviewResult{err: err}
+	}
+
+	if ctx.Err() != nil {
+		return job.timedOutResult(ctx)
+	}
+
+	text := string(head) + string(rest)
+	if format, ok := StructuredFormatOf(job.Path); ok {
+		result, ok := job.structuredPreview(text, int64(len(text)) >= stat.Size(), format)
+		if ok {
+			return result
+		}
+	}
+
+	return NewTextPreviewResult(job.ResolvedPath, text)
+}
+
+// How much of a text file is read, fen.preview_max_output_bytes up to maxBuiltInPreviewBytes
+func (job PreviewJob) textPreviewBytes() int {
+	if job.MaxOutputBytes > 0 {
+		return min(job.MaxOutputBytes, maxBuiltInPreviewBytes)
+	}
+	return maxBuiltInPreviewBytes
+}
+
+func NewTextPreviewResult(path string, text string) *PreviewResult {
+	lines := TextPreviewLines(text)
+	language := SyntaxLanguageFor(path, lines[0])
+	return NewLinesPreviewResult(HighlightLines(lines, language), true)
+}
+
+// A bold summary line, followed by labeled values and a list, like the details of an executable or a video
+func InfoLines(summary string, details [][2]string, listName string, list []string) []StyledLine {
+	lines := []StyledLine{headingLine(summary)}
+	if len(details) > 0 {
+		lines = append(lines, StyledLine{})
+		lines = append(lines, DetailLines(details, "")...)
+	}
+	return append(lines, ListLines(listName, list)...)
+}
+
+func headingLine(heading string) StyledLine {
+	return StyledLine{Text: heading, Spans: []StyledSpan{{Start: 0, End: len(heading), Style: infoHeadingStyle}}}
+}
+
+// Like "Architecture  x86-64", with the values lined up
+func DetailLines(details [][2]string, indent string) []StyledLine {
+	labelWidth := 0
+	for _, detail := range details {
+		labelWidth = max(labelWidth, runewidth.StringWidth(detail[0]))
+	}
+
+	var lines []StyledLine
+	for _, detail := range details {
+		label := runewidth.FillRight(detail[0], labelWidth)
+		lines = append(lines, StyledLine{
+			Text:  indent + label + "  " + detail[1],
+			Spans: []StyledSpan{{Start: len(indent), End: len(indent) + len(label), Style: infoLabelStyle}},
+		})
+	}
+	return lines
+}
+
+// An empty line, a heading like "Dependencies (2)" and the indented items. Nothing if list is empty
+func ListLines(name string, list []string) []StyledLine {
+	if len(list) == 0 {
+		return nil
+	}
+
+	lines := []StyledLine{{}, headingLine(name + " (" + strconv.Itoa(len(list)) + ")")}
+	for _, item := range list {
+		lines = append(lines, StyledLine{Text: "  " + item})
+	}
+	return lines
+}
+
+func NewLinesPreviewResult(lines []StyledLine, lineNumbers bool) *PreviewResult {
+	result := &PreviewResult{lines: lines, lineNumbers: lineNumbers}
+	for _, line := range lines {
+		result.linesWidth = max(result.linesWidth, line.Width())
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestExpandTabs(t *testing.T) {
+	tests := map[string]string{
+		"":        "",
+		"\tx":     "    x",
+		"ab\tc":   "ab  c",
+		"abcd\te": "abcd    e",
+		"a\t\tb":  "a       b",
+		"日本\tx":   "日本    x",
+		"no tabs": "no tabs",
+	}
+
+	for input, expected := range tests {
+		if got := ExpandTabs(input, 4); got != expected {
+			t.Fatal("Expected \"" + expected + "\" but got \"" + got + "\" for \"" + input + "\"")
+		}
+	}
+}
+
+func TestLooksBinary(t *testing.T) {
+	tests := map[string]bool{
+		"":                          false,
+		"hello\nworld\n":            false,
+		"\x1b[31mred\x1b[0m\r\n":    false,
+		"ELF\x00\x01":               true,
+		"\x01\x02\x03\x04\x05hello": true,
+	}
+
+	for input, expected := range tests {
+		if LooksBinary([]byte(input)) != expected {
+			t.Fatal("Wrong result for \"" + input + "\"")
+		}
+	}
+}
+
+func TestTextPreviewBytes(t *testing.T) {
+	tests := map[int]int{
+		0:                          maxBuiltInPreviewBytes,
+		1000:                       1000,
+		maxBuiltInPreviewBytes * 2: maxBuiltInPreviewBytes,
+	}
+
+	for maxOutputBytes, expected := range tests {
+		got := PreviewJob{MaxOutputBytes: maxOutputBytes}.textPreviewBytes()
+		if got != expected {
+			t.Fatal("Expected " + strconv.Itoa(expected) + " bytes with fen.preview_max_output_bytes = " + strconv.Itoa(maxOutputBytes) + ", but got " + strconv.Itoa(got))
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// The commander layout (like Midnight Commander) shows two tabs side by side, the current tab and the "other" tab.
+// Each panel is the middlePane of its tab, the other filespanes of the tabs are not drawn.
+
+// Shows the folder of a panel above it in the commander layout
+type CommanderPanelHeader struct {
+	*tview.Box
+	fen      *Fen
+	tabIndex int
+}
+
+func NewCommanderPanelHeader(fen *Fen, tabIndex int) *CommanderPanelHeader {
+	return &CommanderPanelHeader{
+		Box:      tview.NewBox().SetBackgroundColor(tcell.ColorDefault),
+		fen:      fen,
+		tabIndex: tabIndex,
+	}
+}
+
+func (header *CommanderPanelHeader) Draw(screen tcell.Screen) {
+	if *header.fen.helpScreenVisible || *header.fen.librariesScreenVisible {
+		return
+	}
+
+	x, y, w, _ := header.GetInnerRect()
+
+	folder := header.fen.wd
+	style := "[blue::br]"
+	if header.tabIndex != header.fen.currentTab {
+		folder = header.fen.tabs[header.tabIndex].wd
+		style = "[blue::d]"
+	}
+
+	text := style + " " + FilenameInvisibleCharactersAsCodeHighlighted(tview.Escape(PathWithEndSeparator(folder)), style) + " "
+	tview.Print(screen, text, x, y, w, tview.AlignLeft, tcell.ColorDefault)
+}
+
+// Returns the index of the tab shown in the other panel of the commander layout, -1 if there is only 1 tab
+func (fen *Fen) OtherTabIndex() int {
+	if len(fen.tabs) <= 1 {
+		return -1
+	}
+
+	if fen.lastTab != fen.currentTab && fen.lastTab >= 0 && fen.lastTab < len(fen.tabs) {
+		return fen.lastTab
+	}
+
+	return (fen.currentTab + 1) % len(fen.tabs)
+}
+
+// Returns the current folder of the tab at tabIndex
+func (fen *Fen) TabWorkingDirectory(tabIndex int) string {
+	if tabIndex == fen.currentTab {
+		return fen.wd
+	}
+
+	return fen.tabs[tabIndex].wd
+}
+
+func (fen *Fen) SetLayout(layout string) error {
+	if !slices.Contains(ValidLayoutValues[:], layout) {
+		return errors.New("Invalid layout value \"" + layout + "\"")
+	}
+
+	fen.config.Layout = layout
+
+	// The commander layout needs a second tab for the other panel
+	if layout == LAYOUT_COMMANDER && len(fen.tabs) <= 1 {
+		fen.NewTab() // Implicitly calls fen.UpdateLayout()
+		return nil
+	}
+
+	fen.UpdateLayout()
+	return nil
+}
+
+func (fen *Fen) ToggleCommanderLayout() {
+	if fen.config.Layout == LAYOUT_COMMANDER {
+		fen.SetLayout(LAYOUT_MILLER)
+	} else {
+		fen.SetLayout(LAYOUT_COMMANDER)
+	}
+}
+
+// Switches to the other panel in the commander layout
+func (fen *Fen) SwitchCommanderSide() {
+	otherTab := fen.OtherTabIndex()
+	if otherTab == -1 {
+		return
+	}
+
+	fen.SwitchTab(otherTab)
+}
+
+// The default destination folder for copying or moving files with fen.CopyOrMoveToFolder().
+// The other panel's folder in the commander layout, otherwise the folder of the last tab we were in, or the current folder if there is only 1 tab
+func (fen *Fen) DefaultDestinationFolder() string {
+	otherTab := fen.OtherTabIndex()
+	if otherTab == -1 {
+		return fen.wd
+	}
+
+	return fen.TabWorkingDirectory(otherTab)
+}
+
+// Copies (or moves, if move is true) the selected files, or fen.sel if none are selected, into destinationFolder
+// Returns the amount of files queued for copying/moving
+func (fen *Fen) CopyOrMoveToFolder(destinationFolder string, move bool) (int, error) {
+	if fen.config.NoWrite {
+		if move {
+			return 0, errors.New("Can't move in no-write mode")
+		}
+		return 0, errors.New("Can't copy in no-write mode")
+	}
+
+	if destinationFolder == "" {
+		return 0, errors.New("Empty path provided")
+	}
+
+	destinationFolder = filepath.Clean(destinationFolder)
+	if !filepath.IsAbs(destinationFolder) {
+		destinationFolder = filepath.Join(fen.wd, destinationFolder)
+	}
+
+	stat, err := os.Stat(destinationFolder)
+	if err != nil || !stat.IsDir() {
+		return 0, errors.New("No such folder \"" + destinationFolder + "\"")
+	}
+
+	paths := MapStringBoolKeys(fen.selected)
+	if len(paths) <= 0 {
+		paths = []string{fen.sel}
+	}
+
+	// The destination could be a symlink into one of the folders
+	resolvedDestinationFolder, err := filepath.EvalSymlinks(destinationFolder)
+	if err != nil {
+		resolvedDestinationFolder = destinationFolder
+	}
+
+	// Copying a folder into itself would never end, so nothing is queued
+	for _, path := range paths {
+		if PathIsInFolder(destinationFolder, path) || PathIsInFolder(resolvedDestinationFolder, path) {
+			if move {
+				return 0, errors.New("Can't move \"" + filepath.Base(path) + "\" into itself")
+			}
+			return 0, errors.New("Can't copy \"" + filepath.Base(path) + "\" into itself")
+		}
+	}
+
+	count := 0
+	for _, path := range paths {
+		// Copying/moving a file into the folder it is already in does nothing
+		if filepath.Dir(path) == destinationFolder {
+			continue
+		}
+
+		newPath := FilePathUniqueNameIfAlreadyExists(filepath.Join(destinationFolder, filepath.Base(path)))
+		if move {
+			go fen.fileOperationsHandler.QueueOperation(FileOperation{operation: Rename, path: path, newPath: newPath})
+		} else {
+			go fen.fileOperationsHandler.QueueOperation(FileOperation{operation: Copy, path: path, newPath: newPath})
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, errors.New("Nothing done, the destination is the same folder")
+	}
+
+	fen.selected = make(map[string]bool)
+	fen.DisableSelectingWithV()
+	return count, nil
+}
+
+func CopyOrMoveDoneText(count int, move bool) string {
+	text := "Copying "
+	if move {
+		text = "Moving "
+	}
+
+	text += strconv.Itoa(count)
+	if count == 1 {
+		return text + " file"
+	}
+	return text + " files"
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyOrMoveFolderIntoItself(t *testing.T) {
+	folder := t.TempDir()
+	path := filepath.Join(folder, "A")
+	subfolder := filepath.Join(path, "sub")
+	err := os.MkdirAll(subfolder, 0o755)
+	if err != nil {
+		t.Fatal("Failed to create " + subfolder + ": " + err.Error())
+	}
+
+	link := filepath.Join(folder, "link")
+	err = os.Symlink(subfolder, link)
+	if err != nil {
+		t.Fatal("Failed to create " + link + ": " + err.Error())
+	}
+
+	fen := &Fen{wd: folder, sel: path, selected: make(map[string]bool)}
+	for _, destinationFolder := range []string{path, subfolder, link} {
+		for _, move := range []bool{false, true} {
+			count, err := fen.CopyOrMoveToFolder(destinationFolder, move)
+			if err == nil || count != 0 {
+				t.Fatal("Expected copying or moving " + path + " into " + destinationFolder + " to fail")
+			}
+		}
+	}
+}
@@ -11,13 +11,30 @@ fen.show_hostname = true -- Does not apply to Windows, shows username@hostname i
 fen.show_help_text = true
 fen.sort_by = "alphabetical" -- "fen -h" for valid values
 fen.sort_reverse = false
+fen.layout = "miller" -- "miller" (parent, current and preview panes) or "commander" (two panels side by side)
+fen.history_size = 1000 -- How many selected paths to remember between sessions, 0 disables saving the history
+fen.global_selection = false -- Share selected and yanked files with other fen instances, so you can yank in one and paste in another
+fen.pane_ratios = {1, 3, 3} -- Relative widths of each parent folder column, the current folder and the preview
+fen.parent_columns = 1 -- How many parent folders to show on the left, 0 to 5
+fen.collapse_empty_panes = false -- Hide the preview when there is nothing to preview, and the parent folder column in the root folder
+fen.detailed_view = false -- Show a long listing like "ls -l" in the current folder, toggled with i. See fen.detailed_view_columns below
+fen.local_config = false -- Read .fen.lua files in the current folder (or its parents) to override some options, you are asked before running one. See README.md
+fen.frecency = true -- Records the folders you enter, for jumping to them with J. Use "fen --import-frecency=zoxide" (or autojump) to import existing folders
 fen.file_event_interval_ms = 300 -- How often to update the screen on file events (and job count updates), if set to 0, it updates on every event
 fen.always_show_info_numbers = false -- Shows the blue, green and yellow numbers in the bottom right even when they are 0
 fen.scroll_speed = 2 -- When scrolling faster than 30ms per scroll, scroll this many entries
 fen.git_status = false -- EXPERIMENTAL: When true, unstaged/untracked files in local git repositories are shown in red
 fen.preview_safety_blocklist = true -- Prevents common sensitive file types from being previewed
+fen.preview_timeout_ms = 5000 -- File preview programs and Lua scripts taking longer than this are stopped, 0 to never stop them
+fen.preview_max_output_bytes = 1000000 -- File preview programs are stopped after outputting this much, 0 for no limit
+fen.built_in_previews = true -- Preview files without a matching fen.preview entry, text files are shown with syntax highlighting, images as colored blocks and other binary files as a hex dump
+fen.preview_line_numbers = false -- Show line numbers in the built-in text preview
+fen.image_protocol = "auto" -- How images are shown in the built-in preview: "kitty", "sixel" or "none" for colored blocks. "auto" detects it from environment variables like TERM
+fen.preview_cache = true -- Cache the output of file preview programs on disk, so slow programs only run once per file. "fen --clear-preview-cache" deletes it
+fen.preview_cache_size_mb = 100 -- The least recently used previews are deleted when the cache gets larger than this
 fen.close_on_escape = false -- Use the Escape key to close fen, useful for embedding in other applications
 fen.file_size_in_all_panes = false
+fen.folder_summary = false -- Show the total size, file types, newest and oldest file and Git branch of the selected folder above its files
 
 -- Everything below this line is non-default examples
 
@@ -49,6 +66,36 @@ fen.bookmarks = {
 	[10] = "/", -- This is used when pressing '0',
 }
 
+-- The sort mode for specific folders, the first match is used. Folders without a match use fen.sort_by and fen.sort_reverse
+-- Patterns without a slash match the folder name, otherwise the full path ("~/" is your home folder)
+-- Sorting a folder with the sort menu (s) overrides this, and is remembered between sessions until you pick "Reset to default"
+fen.folder_sort = {
+	{
+		sort_by = "modified",
+		sort_reverse = true, -- Newest last
+		match = {"~/Downloads", "~/Desktop"},
+	},
+	{
+		sort_by = "natural", -- "file2" before "file10"
+		match = {"Pictures", "Music", "Videos"},
+		do_not_match = {"~/Pictures"},
+	}
+}
+
+-- The columns of the detailed view (fen.detailed_view = true, or press i), shown in this order before the file name
+-- Valid columns: "permissions", "owner", "size", "modified", "git", "link" (the symlink target, always shown after the file name)
+-- "size" can have format = "si" (default, 1 kB = 1000 bytes), "iec" (1 KiB = 1024 bytes) or "bytes"
+-- "modified" can have format = "absolute" (default) or "relative" (like "5 min ago")
+-- width = 0 (default) fits the widest value on screen
+fen.detailed_view_columns = {
+	{column = "permissions"},
+	{column = "owner"},
+	{column = "size", format = "iec"},
+	{column = "modified", format = "relative", width = 12},
+	{column = "git"},
+	{column = "link"},
+}
+
 -- You can use fen.runtime_os to let your config have specific behaviour on different operating systems
 local textEditor = os.getenv("EDITOR")
 if fen.runtime_os == "windows" then
@@ -77,6 +124,9 @@ fen.open = {
 --
 -- Values in "program" do not expand tildes like "~/some/file.sh"
 -- If you want to use a shell script, it has to have a shebang or you need to explicitly invoke the appropriate shell like "bash /some/file.sh"
+--
+-- Previews can be scrolled with Shift+arrow keys. Program output is scrolled by fen,
+-- unless "scrolls_itself = true" is set, then the program should use $FEN_PREVIEW_SCROLL_Y and $FEN_PREVIEW_SCROLL_X
 fen.preview = {
 	{
 		-- If the first command exits with a non-zero exit code, the next one in the list will be ran
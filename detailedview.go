@@ -0,0 +1,288 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// With fen.detailed_view enabled, the middlePane shows a long listing (like "ls -l") with the columns in fen.detailed_view_columns.
+// The columns are shown in order before the file name, except the symlink target which is shown after it
+const (
+	DETAILED_COLUMN_PERMISSIONS = "permissions"
+	DETAILED_COLUMN_OWNER       = "owner" // user:group
+	DETAILED_COLUMN_SIZE        = "size"  // File count for folders, like the size shown in the normal view
+	DETAILED_COLUMN_MODIFIED    = "modified"
+	DETAILED_COLUMN_GIT         = "git" // "M" for unstaged, "?" for untracked files, only when fen.git_status = true
+	DETAILED_COLUMN_LINK        = "link"
+)
+
+var ValidDetailedViewColumns = [...]string{DETAILED_COLUMN_PERMISSIONS, DETAILED_COLUMN_OWNER, DETAILED_COLUMN_SIZE, DETAILED_COLUMN_MODIFIED, DETAILED_COLUMN_GIT, DETAILED_COLUMN_LINK}
+
+// The first format of each column is the default
+var ValidDetailedViewColumnFormats = map[string][]string{
+	DETAILED_COLUMN_SIZE:     {"si", "iec", "bytes"},
+	DETAILED_COLUMN_MODIFIED: {"absolute", "relative"},
+}
+
+// An entry in fen.detailed_view_columns from config.lua
+type DetailedViewColumn struct {
+	Column string
+	Format string
+	Width  int // 0 fits the widest value on screen
+}
+
+// Used when fen.detailed_view_columns is empty
+var DefaultDetailedViewColumns = []DetailedViewColumn{
+	{Column: DETAILED_COLUMN_PERMISSIONS},
+	{Column: DETAILED_COLUMN_OWNER},
+	{Column: DETAILED_COLUMN_SIZE},
+	{Column: DETAILED_COLUMN_MODIFIED},
+	{Column: DETAILED_COLUMN_GIT},
+	{Column: DETAILED_COLUMN_LINK},
+}
+
+const maxDetailedColumnWidth = 40
+const minDetailedViewNameWidth = 12
+
+func ValidateDetailedViewColumns(columns []DetailedViewColumn) error {
+	for _, column := range columns {
+		if !slices.Contains(ValidDetailedViewColumns[:], column.Column) {
+			return errors.New("Invalid detailed_view_columns column \"" + column.Column + "\"\nValid values: " + strings.Join(ValidDetailedViewColumns[:], ", "))
+		}
+
+		formats := ValidDetailedViewColumnFormats[column.Column]
+		if column.Format != "" && !slices.Contains(formats, column.Format) {
+			if len(formats) == 0 {
+				return errors.New("The detailed_view_columns column \"" + column.Column + "\" has no formats")
+			}
+			return errors.New("Invalid detailed_view_columns format \"" + column.Format + "\" for column \"" + column.Column + "\"\nValid values: " + strings.Join(formats, ", "))
+		}
+
+		if column.Width < 0 || column.Width > maxDetailedColumnWidth {
+			return errors.New("Invalid detailed_view_columns width " + strconv.Itoa(column.Width) + ", valid values are 0 to " + strconv.Itoa(maxDetailedColumnWidth))
+		}
+	}
+
+	return nil
+}
+
+func (fen *Fen) DetailedViewColumns() []DetailedViewColumn {
+	if len(fen.config.DetailedViewColumns) == 0 {
+		return DefaultDetailedViewColumns
+	}
+	return fen.config.DetailedViewColumns
+}
+
+// Whether fp should be drawn as a long listing
+func (fp *FilesPane) ShowsDetailedView() bool {
+	return fp.fen.config.DetailedView && fp.panePos == MiddlePane
+}
+
+func (fen *Fen) ToggleDetailedView() {
+	fen.config.DetailedView = !fen.config.DetailedView
+}
+
+// A value in a column of the detailed view
+type DetailedColumnCell struct {
+	text  string
+	color tcell.Color
+}
+
+var usernameColorCache sync.Map // username -> tcell.Color
+
+// Root is shown in red, like in the bottom bar
+func ownerNameColor(name string, nameColor func(string) string) tcell.Color {
+	if color, ok := usernameColorCache.Load(name); ok {
+		return color.(tcell.Color)
+	}
+
+	color := tcell.ColorGreen
+	if nameColor(name) == rootColor {
+		color = tcell.ColorRed
+	}
+	usernameColorCache.Store(name, color)
+	return color
+}
+
+// stat should be from an os.Lstat()
+func (fp *FilesPane) DetailedColumnCell(column DetailedViewColumn, stat os.FileInfo, path string, gitRepository string) DetailedColumnCell {
+	if stat == nil {
+		return DetailedColumnCell{text: "?", color: tcell.ColorDefault}
+	}
+
+	switch column.Column {
+	case DETAILED_COLUMN_PERMISSIONS:
+		permissions := "-"
+		if stat.IsDir() {
+			permissions = "d"
+		} else if stat.Mode()&os.ModeSymlink != 0 {
+			permissions = "l"
+		}
+		return DetailedColumnCell{text: permissions + FilePermissionsString(stat), color: tcell.ColorTeal}
+	case DETAILED_COLUMN_OWNER:
+		username, groupname, err := FileUserAndGroupName(stat)
+		if err != nil {
+			return DetailedColumnCell{text: "?", color: tcell.ColorDefault}
+		}
+		return DetailedColumnCell{text: username + ":" + groupname, color: ownerNameColor(username, UsernameColor)}
+	case DETAILED_COLUMN_SIZE:
+		// Show the size of the target, not the symlink
+		if stat.Mode()&os.ModeSymlink != 0 {
+			targetStat, err := os.Stat(path)
+			if err != nil {
+				return DetailedColumnCell{text: "?", color: tcell.ColorDefault}
+			}
+			stat = targetStat
+		}
+
+		if stat.IsDir() {
+			count, err := FolderFileCountCached(fp.fen.folderFileCountCache, path, fp.fen.config.HiddenFiles)
+			if err != nil {
+				return DetailedColumnCell{text: "?", color: tcell.ColorDefault}
+			}
+			return DetailedColumnCell{text: strconv.Itoa(count), color: tcell.ColorDefault}
+		}
+
+		switch column.Format {
+		case "iec":
+			return DetailedColumnCell{text: BytesToHumanReadableUnitStringIEC(uint64(stat.Size()), 2), color: tcell.ColorDefault}
+		case "bytes":
+			return DetailedColumnCell{text: strconv.FormatInt(stat.Size(), 10), color: tcell.ColorDefault}
+		default:
+			return DetailedColumnCell{text: BytesToHumanReadableUnitString(uint64(stat.Size()), 2), color: tcell.ColorDefault}
+		}
+	case DETAILED_COLUMN_MODIFIED:
+		if column.Format == "relative" {
+			return DetailedColumnCell{text: RelativeTimeString(stat.ModTime(), time.Now()), color: tcell.ColorBlue}
+		}
+		return DetailedColumnCell{text: stat.ModTime().Format("2006-01-02 15:04"), color: tcell.ColorBlue}
+	case DETAILED_COLUMN_GIT:
+		if !fp.fen.config.GitStatus || gitRepository == "" {
+			return DetailedColumnCell{text: "", color: tcell.ColorDefault}
+		}
+		return DetailedColumnCell{text: fp.fen.gitStatusHandler.PathGitStatusText(path, gitRepository), color: tcell.ColorMaroon}
+	case DETAILED_COLUMN_LINK:
+		if stat.Mode()&os.ModeSymlink == 0 {
+			return DetailedColumnCell{text: "", color: tcell.ColorDefault}
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			return DetailedColumnCell{text: "-> unable to read link", color: tcell.ColorRed}
+		}
+
+		targetAbsolutePath := target
+		if !filepath.IsAbs(target) {
+			targetAbsolutePath = filepath.Join(filepath.Dir(path), target)
+		}
+		targetStat, err := os.Lstat(targetAbsolutePath)
+		if err != nil {
+			return DetailedColumnCell{text: "-> " + target, color: tcell.ColorRed}
+		}
+		color, _, _ := FileColor(targetStat, targetAbsolutePath).Decompose()
+		return DetailedColumnCell{text: "-> " + target, color: color}
+	}
+
+	panic("Invalid detailed view column \"" + column.Column + "\"")
+}
+
+// Returns the cells of the visible entries, and the width of each column
+func (fp *FilesPane) DetailedViewCells(entries []os.DirEntry, gitRepository string) ([][]DetailedColumnCell, []int) {
+	columns := fp.fen.DetailedViewColumns()
+	widths := make([]int, len(columns))
+	cells := make([][]DetailedColumnCell, len(entries))
+
+	for i, entry := range entries {
+		entryInfo, _ := entry.Info()
+		entryFullPath := filepath.Join(fp.folder, entry.Name())
+
+		cells[i] = make([]DetailedColumnCell, len(columns))
+		for j, column := range columns {
+			cells[i][j] = fp.DetailedColumnCell(column, entryInfo, entryFullPath, gitRepository)
+			if column.Width == 0 {
+				widths[j] = min(maxDetailedColumnWidth, max(widths[j], runewidth.StringWidth(cells[i][j].text)))
+			}
+		}
+	}
+
+	for j, column := range columns {
+		if column.Width != 0 {
+			widths[j] = column.Width
+		}
+	}
+
+	return cells, widths
+}
+
+// Draws the columns shown before the file name, returns how many cells were used.
+// Columns that don't fit are left out, so there is always some space left for the file name.
+// When rowStyle is set (the cursor row), it is used instead of the column colors
+func (fp *FilesPane) DrawDetailedColumns(screen tcell.Screen, x, y, maxWidth int, cells []DetailedColumnCell, widths []int, rowStyle *tcell.Style) int {
+	columns := fp.fen.DetailedViewColumns()
+	maxWidth -= max(minDetailedViewNameWidth, maxWidth/3)
+	used := 0
+	for j, column := range columns {
+		if column.Column == DETAILED_COLUMN_LINK || widths[j] == 0 {
+			continue
+		}
+
+		if used+widths[j]+1 > maxWidth {
+			break
+		}
+
+		style := tcell.StyleDefault.Foreground(cells[j].color)
+		if rowStyle != nil {
+			style = *rowStyle
+		}
+
+		// Measured in cells, wide characters like in CJK owner names take up 2
+		text := runewidth.Truncate(cells[j].text, widths[j], "…")
+
+		// Numbers are right-aligned
+		textX := x + used
+		if column.Column == DETAILED_COLUMN_SIZE {
+			textX += widths[j] - runewidth.StringWidth(text)
+		}
+
+		for k := 0; k < widths[j]+1; k++ {
+			screen.SetContent(x+used+k, y, ' ', nil, style)
+		}
+		for _, c := range text {
+			if runewidth.RuneWidth(c) == 0 {
+				continue
+			}
+			screen.SetContent(textX, y, c, nil, style)
+			textX += runewidth.RuneWidth(c)
+		}
+		used += widths[j] + 1
+	}
+
+	return used
+}
+
+// Returns the symlink target text shown after the file name, if there is a link column
+func (fp *FilesPane) DetailedLinkCell(cells []DetailedColumnCell) (DetailedColumnCell, bool) {
+	for j, column := range fp.fen.DetailedViewColumns() {
+		if column.Column == DETAILED_COLUMN_LINK && cells[j].text != "" {
+			return cells[j], true
+		}
+	}
+	return DetailedColumnCell{}, false
+}
+
+func (fen *Fen) DetailedViewHasSizeColumn() bool {
+	return slices.ContainsFunc(fen.DetailedViewColumns(), func(column DetailedViewColumn) bool {
+		return column.Column == DETAILED_COLUMN_SIZE
+	})
+}
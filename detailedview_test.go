@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestDrawDetailedColumnsWideCharacters(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	err := screen.Init()
+	if err != nil {
+		t.Fatal("Failed to create a simulation screen: " + err.Error())
+	}
+	defer screen.Fini()
+	screen.SetSize(80, 1)
+
+	fen := &Fen{}
+	fen.config.DetailedViewColumns = []DetailedViewColumn{{Column: DETAILED_COLUMN_OWNER}, {Column: DETAILED_COLUMN_SIZE}}
+	fp := &FilesPane{fen: fen}
+
+	// The owner is 16 cells wide, cut off to 5
+	cells := []DetailedColumnCell{{text: "日本語:グループ"}, {text: "1K"}}
+	used := fp.DrawDetailedColumns(screen, 0, 0, 80, cells, []int{5, 3}, nil)
+	if used != 10 {
+		t.Fatal("Expected 10 cells to be used")
+	}
+
+	expected := map[int]rune{0: '日', 2: '本', 4: '…', 5: ' ', 6: ' ', 7: '1', 8: 'K'}
+	for x, r := range expected {
+		got, _, _, _ := screen.GetContent(x, 0)
+		if got != r {
+			t.Fatal("Expected '" + string(r) + "' at x = " + strconv.Itoa(x) + ", but got '" + string(got) + "'")
+		}
+	}
+}
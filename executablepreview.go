@@ -0,0 +1,390 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ELF, Mach-O and PE files are shown in the built-in preview with their format, architecture, dependencies and build ID, and the module build info of Go programs.
+// They are recognized by their first bytes rather than the executable permission, so shared libraries and Windows programs are shown too
+
+const (
+	EXECUTABLE_ELF       = "ELF"
+	EXECUTABLE_MACHO     = "Mach-O"
+	EXECUTABLE_MACHO_FAT = "Mach-O universal"
+	EXECUTABLE_PE        = "PE"
+)
+
+// Longer interpreter paths aren't shown, it's normally something like /lib64/ld-linux-x86-64.so.2
+const maxELFInterpreterBytes = 4096
+
+var elfMachineNames = map[elf.Machine]string{
+	elf.EM_386:       "x86",
+	elf.EM_X86_64:    "x86-64",
+	elf.EM_ARM:       "ARM",
+	elf.EM_AARCH64:   "AArch64",
+	elf.EM_RISCV:     "RISC-V",
+	elf.EM_PPC:       "PowerPC",
+	elf.EM_PPC64:     "PowerPC 64",
+	elf.EM_MIPS:      "MIPS",
+	elf.EM_S390:      "S/390",
+	elf.EM_SPARCV9:   "SPARC V9",
+	elf.EM_LOONGARCH: "LoongArch",
+}
+
+var machoCpuNames = map[macho.Cpu]string{
+	macho.Cpu386:   "x86",
+	macho.CpuAmd64: "x86-64",
+	macho.CpuArm:   "ARM",
+	macho.CpuArm64: "arm64",
+	macho.CpuPpc:   "PowerPC",
+	macho.CpuPpc64: "PowerPC 64",
+}
+
+var peMachineNames = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:    "x86",
+	pe.IMAGE_FILE_MACHINE_AMD64:   "x86-64",
+	pe.IMAGE_FILE_MACHINE_ARMNT:   "ARM",
+	pe.IMAGE_FILE_MACHINE_ARM64:   "arm64",
+	pe.IMAGE_FILE_MACHINE_RISCV64: "RISC-V",
+}
+
+// What an executable is, shown as a summary line and a list of labeled values
+type ExecutableInfo struct {
+	Summary      string // Like "ELF 64-bit little-endian executable"
+	Details      [][2]string
+	Dependencies []string
+}
+
+// Returns the executable format head starts with, or an empty string. head should be the start of a file
+func ExecutableFormatOf(head []byte) string {
+	if len(head) < 8 {
+		return ""
+	}
+
+	switch {
+	case string(head[:4]) == elf.ELFMAG:
+		return EXECUTABLE_ELF
+	case binary.LittleEndian.Uint32(head) == macho.Magic32 || binary.LittleEndian.Uint32(head) == macho.Magic64 ||
+		binary.BigEndian.Uint32(head) == macho.Magic32 || binary.BigEndian.Uint32(head) == macho.Magic64:
+		return EXECUTABLE_MACHO
+	// Java class files start with the same bytes, followed by a version that's higher than the amount of architectures
+	case binary.BigEndian.Uint32(head) == macho.MagicFat && binary.BigEndian.Uint32(head[4:]) < 20:
+		return EXECUTABLE_MACHO_FAT
+	case string(head[:2]) == "MZ":
+		return EXECUTABLE_PE
+	}
+	return ""
+}
+
+// Returns false if file couldn't be parsed, so it can be previewed as a binary file instead
+func (job PreviewJob) executablePreview(file *os.File, format string) (*PreviewResult, bool) {
+	var info ExecutableInfo
+	var err error
+	switch format {
+	case EXECUTABLE_ELF:
+		info, err = ELFInfo(file)
+	case EXECUTABLE_MACHO:
+		info, err = MachOInfo(file)
+	case EXECUTABLE_MACHO_FAT:
+		info, err = MachOFatInfo(file)
+	case EXECUTABLE_PE:
+		info, err = PEInfo(file)
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	lines := InfoLines(info.Summary, info.Details, "Dependencies", info.Dependencies)
+
+	// Not every Go binary has the build info, like ones built with "go tool link"
+	goBuildInfo, err := buildinfo.Read(file)
+	if err == nil {
+		var details [][2]string
+		details = append(details, [2]string{"Go version", goBuildInfo.GoVersion})
+		if goBuildInfo.Path != "" {
+			details = append(details, [2]string{"Path", goBuildInfo.Path})
+		}
+		if goBuildInfo.Main.Path != "" {
+			details = append(details, [2]string{"Module", goBuildInfo.Main.Path + " " + goBuildInfo.Main.Version})
+		}
+		for _, setting := range goBuildInfo.Settings {
+			details = append(details, [2]string{setting.Key, setting.Value})
+		}
+
+		var dependencies []string
+		for _, dependency := range goBuildInfo.Deps {
+			text := dependency.Path + " " + dependency.Version
+			if dependency.Replace != nil {
+				text += " => " + dependency.Replace.Path + " " + dependency.Replace.Version
+			}
+			dependencies = append(dependencies, text)
+		}
+
+		lines = append(lines, StyledLine{}, headingLine("Go build info"))
+		lines = append(lines, DetailLines(details, "  ")...)
+		lines = append(lines, ListLines("Go modules", dependencies)...)
+	}
+
+	return NewLinesPreviewResult(lines, false), true
+}
+
+func yesOrNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func ELFInfo(reader io.ReaderAt) (ExecutableInfo, error) {
+	file, err := elf.NewFile(reader)
+	if err != nil {
+		return ExecutableInfo{}, err
+	}
+
+	interpreter := ""
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		// The size comes from the file, so it can be anything
+		if prog.Filesz > maxELFInterpreterBytes {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(prog.Open(), maxELFInterpreterBytes))
+		if err == nil {
+			interpreter = strings.TrimRight(string(data), "\x00")
+		}
+	}
+
+	dependencies, _ := file.ImportedLibraries()
+
+	fileType := "file"
+	switch file.Type {
+	case elf.ET_EXEC:
+		fileType = "executable"
+	case elf.ET_DYN:
+		fileType = "shared object"
+		if interpreter != "" {
+			fileType = "position-independent executable"
+		}
+	case elf.ET_REL:
+		fileType = "relocatable object"
+	case elf.ET_CORE:
+		fileType = "core dump"
+	}
+
+	bits := "32-bit"
+	if file.Class == elf.ELFCLASS64 {
+		bits = "64-bit"
+	}
+
+	endianness := "little-endian"
+	if file.Data == elf.ELFDATA2MSB {
+		endianness = "big-endian"
+	}
+
+	architecture, ok := elfMachineNames[file.Machine]
+	if !ok {
+		architecture = strings.TrimPrefix(file.Machine.String(), "EM_")
+	}
+
+	info := ExecutableInfo{
+		Summary:      "ELF " + bits + " " + endianness + " " + fileType,
+		Details:      [][2]string{{"Architecture", architecture}},
+		Dependencies: dependencies,
+	}
+
+	if interpreter != "" {
+		info.Details = append(info.Details, [2]string{"Interpreter", interpreter})
+	} else if len(dependencies) == 0 && file.Type == elf.ET_EXEC {
+		info.Details = append(info.Details, [2]string{"Linking", "static"})
+	}
+
+	info.Details = append(info.Details,
+		[2]string{"Stripped", yesOrNo(file.Section(".symtab") == nil)},
+		[2]string{"Debug info", yesOrNo(file.Section(".debug_info") != nil || file.Section(".zdebug_info") != nil)},
+	)
+
+	if buildID := elfNote(file, ".note.gnu.build-id"); buildID != nil {
+		info.Details = append(info.Details, [2]string{"Build ID", hex.EncodeToString(buildID)})
+	}
+	if goBuildID := elfNote(file, ".note.go.buildid"); goBuildID != nil {
+		info.Details = append(info.Details, [2]string{"Go build ID", string(goBuildID)})
+	}
+
+	return info, nil
+}
+
+// Returns the description of the first note in the section, or nil if there is none
+func elfNote(file *elf.File, sectionName string) []byte {
+	section := file.Section(sectionName)
+	if section == nil {
+		return nil
+	}
+
+	// A note is the name size, description size and type, followed by the name and description padded to 4 bytes
+	data, err := section.Data()
+	if err != nil || len(data) < 12 {
+		return nil
+	}
+
+	nameSize := uint64(file.ByteOrder.Uint32(data[0:]))
+	descriptionSize := uint64(file.ByteOrder.Uint32(data[4:]))
+	start := 12 + (nameSize+3)&^3
+	if start+descriptionSize > uint64(len(data)) {
+		return nil
+	}
+	return data[start : start+descriptionSize]
+}
+
+func MachOInfo(reader io.ReaderAt) (ExecutableInfo, error) {
+	file, err := macho.NewFile(reader)
+	if err != nil {
+		return ExecutableInfo{}, err
+	}
+	return machOFileInfo(file), nil
+}
+
+// Shows the architectures, and the details of the first one
+func MachOFatInfo(reader io.ReaderAt) (ExecutableInfo, error) {
+	fatFile, err := macho.NewFatFile(reader)
+	if err != nil {
+		return ExecutableInfo{}, err
+	}
+
+	var architectures []string
+	for _, arch := range fatFile.Arches {
+		architectures = append(architectures, machOArchitecture(arch.Cpu))
+	}
+
+	info := machOFileInfo(fatFile.Arches[0].File)
+	info.Summary = "Mach-O universal binary with " + CountText(len(fatFile.Arches), "architecture") + ", " + strings.TrimPrefix(info.Summary, "Mach-O ")
+	info.Details[0] = [2]string{"Architectures", strings.Join(architectures, ", ")}
+	return info, nil
+}
+
+func machOArchitecture(cpu macho.Cpu) string {
+	architecture, ok := machoCpuNames[cpu]
+	if !ok {
+		return cpu.String()
+	}
+	return architecture
+}
+
+func machOFileInfo(file *macho.File) ExecutableInfo {
+	fileType := "file"
+	switch file.Type {
+	case macho.TypeExec:
+		fileType = "executable"
+	case macho.TypeDylib:
+		fileType = "dynamic library"
+	case macho.TypeBundle:
+		fileType = "bundle"
+	case macho.TypeObj:
+		fileType = "object"
+	}
+
+	bits := "32-bit"
+	if file.Magic == macho.Magic64 {
+		bits = "64-bit"
+	}
+
+	dependencies, _ := file.ImportedLibraries()
+
+	// Stripping removes the symbols that aren't exported
+	stripped := true
+	if file.Symtab != nil {
+		for _, symbol := range file.Symtab.Syms {
+			const N_EXT = 0x01
+			if symbol.Type&N_EXT == 0 {
+				stripped = false
+				break
+			}
+		}
+	}
+
+	info := ExecutableInfo{
+		Summary: "Mach-O " + bits + " " + fileType,
+		Details: [][2]string{
+			{"Architecture", machOArchitecture(file.Cpu)},
+			{"Stripped", yesOrNo(stripped)},
+			{"Debug info", yesOrNo(file.Section("__debug_info") != nil || file.Section("__zdebug_info") != nil)},
+		},
+		Dependencies: dependencies,
+	}
+
+	for _, load := range file.Loads {
+		raw := load.Raw()
+		const LC_UUID = 0x1b
+		if len(raw) >= 24 && file.ByteOrder.Uint32(raw) == LC_UUID {
+			uuid := strings.ToUpper(hex.EncodeToString(raw[8:24]))
+			info.Details = append(info.Details, [2]string{"UUID", uuid[:8] + "-" + uuid[8:12] + "-" + uuid[12:16] + "-" + uuid[16:20] + "-" + uuid[20:]})
+		}
+	}
+
+	return info
+}
+
+func PEInfo(reader io.ReaderAt) (ExecutableInfo, error) {
+	file, err := pe.NewFile(reader)
+	if err != nil {
+		return ExecutableInfo{}, err
+	}
+
+	format := "PE32"
+	var subsystem uint16
+	switch optionalHeader := file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		subsystem = optionalHeader.Subsystem
+	case *pe.OptionalHeader64:
+		format = "PE32+"
+		subsystem = optionalHeader.Subsystem
+	}
+
+	fileType := "executable"
+	if file.Characteristics&pe.IMAGE_FILE_DLL != 0 {
+		fileType = "DLL"
+	}
+
+	switch subsystem {
+	case pe.IMAGE_SUBSYSTEM_WINDOWS_GUI:
+		fileType += " (GUI)"
+	case pe.IMAGE_SUBSYSTEM_WINDOWS_CUI:
+		fileType += " (console)"
+	}
+
+	architecture, ok := peMachineNames[file.Machine]
+	if !ok {
+		architecture = "0x" + strconv.FormatUint(uint64(file.Machine), 16)
+	}
+
+	// pe.File.ImportedLibraries() isn't implemented, the imported symbols are like "ExitProcess:kernel32.dll"
+	var dependencies []string
+	symbols, _ := file.ImportedSymbols()
+	for _, symbol := range symbols {
+		_, library, found := strings.Cut(symbol, ":")
+		if found && !slices.Contains(dependencies, library) {
+			dependencies = append(dependencies, library)
+		}
+	}
+
+	return ExecutableInfo{
+		Summary: format + " " + fileType,
+		Details: [][2]string{
+			{"Architecture", architecture},
+			{"Stripped", yesOrNo(file.NumberOfSymbols == 0)},
+			{"Debug info", yesOrNo(file.Section(".debug_info") != nil || file.Section(".zdebug_info") != nil)},
+		},
+		Dependencies: dependencies,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+func TestExecutableFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"\x7fELF\x02\x01\x01\x00":              EXECUTABLE_ELF,
+		"\xcf\xfa\xed\xfe\x07\x00\x00\x01":     EXECUTABLE_MACHO,
+		"\xfe\xed\xfa\xce\x00\x00\x00\x12":     EXECUTABLE_MACHO,
+		"\xca\xfe\xba\xbe\x00\x00\x00\x02":     EXECUTABLE_MACHO_FAT,
+		"\xca\xfe\xba\xbe\x00\x00\x00\x34":     "", // A Java class file
+		"MZ\x90\x00\x03\x00\x00\x00":           EXECUTABLE_PE,
+		"PK\x03\x04\x14\x00\x00\x00":           "",
+		"\x7fELF":                              "",
+		"#!/bin/sh\necho hello\n":              "",
+		"\x00\x00\x00\x00\x00\x00\x00\x00\x00": "",
+	}
+
+	for head, expected := range tests {
+		if got := ExecutableFormatOf([]byte(head)); got != expected {
+			t.Fatal("Expected \"" + expected + "\" but got \"" + got + "\" for " + head)
+		}
+	}
+}
+
+func TestExecutablePreview(t *testing.T) {
+	path, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	head := make([]byte, 64)
+	_, err = file.Read(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format := ExecutableFormatOf(head)
+	if format == "" {
+		t.Fatal("Expected the test binary to be recognized as an executable")
+	}
+
+	result, ok := PreviewJob{}.executablePreview(file, format)
+	if !ok {
+		t.Fatal("Expected the test binary to be parsed")
+	}
+
+	foundGoBuildInfo := false
+	for _, line := range result.lines {
+		if line.Text == "Go build info" {
+			foundGoBuildInfo = true
+		}
+	}
+	if !foundGoBuildInfo {
+		t.Fatal("Expected the Go build info of the test binary to be shown")
+	}
+}
+
+// A 64-bit ELF executable with only a PT_INTERP program header, whose size can be made up
+func elfWithInterpreter(interpreter string, size uint64) []byte {
+	var buffer bytes.Buffer
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     1,
+	}
+	copy(header.Ident[:], "\x7fELF\x02\x01\x01")
+	binary.Write(&buffer, binary.LittleEndian, header)
+	binary.Write(&buffer, binary.LittleEndian, elf.Prog64{
+		Type:   uint32(elf.PT_INTERP),
+		Off:    64 + 56,
+		Filesz: size,
+		Memsz:  size,
+	})
+	buffer.WriteString(interpreter)
+	return buffer.Bytes()
+}
+
+func TestELFInterpreter(t *testing.T) {
+	interpreter := "/lib64/ld-linux-x86-64.so.2\x00"
+	info, err := ELFInfo(bytes.NewReader(elfWithInterpreter(interpreter, uint64(len(interpreter)))))
+	if err != nil {
+		t.Fatal("Expected no error, but got: " + err.Error())
+	}
+	if info.Summary != "ELF 64-bit little-endian position-independent executable" || info.Details[1] != [2]string{"Interpreter", "/lib64/ld-linux-x86-64.so.2"} {
+		t.Fatal("Expected the interpreter to be shown, but got the summary \"" + info.Summary + "\"")
+	}
+
+	// Used to panic by allocating the size
+	info, err = ELFInfo(bytes.NewReader(elfWithInterpreter(interpreter, 1<<60)))
+	if err != nil {
+		t.Fatal("Expected no error, but got: " + err.Error())
+	}
+	if info.Details[1][0] == "Interpreter" {
+		t.Fatal("Expected the interpreter with a made up size to be left out")
+	}
+}
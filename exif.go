@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EXIF metadata of photos, shown below the image in the built-in preview.
+// It's stored like a TIFF file: lists of tags ("IFDs"), where some tags point to more lists like the GPS tags.
+// JPEG, PNG and WebP files have it in a part of the file, TIFF files are one themselves
+
+// The EXIF tags we show, https://exiftool.org/TagNames/EXIF.html
+const (
+	EXIF_MAKE               = 0x010f
+	EXIF_MODEL              = 0x0110
+	EXIF_ORIENTATION        = 0x0112
+	EXIF_SOFTWARE           = 0x0131
+	EXIF_DATE_TIME          = 0x0132
+	EXIF_EXPOSURE_TIME      = 0x829a
+	EXIF_F_NUMBER           = 0x829d
+	EXIF_IFD_POINTER        = 0x8769
+	EXIF_GPS_IFD_POINTER    = 0x8825
+	EXIF_ISO                = 0x8827
+	EXIF_DATE_TIME_ORIGINAL = 0x9003
+	EXIF_FOCAL_LENGTH       = 0x920a
+	EXIF_LENS_MODEL         = 0xa434
+
+	GPS_LATITUDE_REF  = 1
+	GPS_LATITUDE      = 2
+	GPS_LONGITUDE_REF = 3
+	GPS_LONGITUDE     = 4
+	GPS_ALTITUDE_REF  = 5
+	GPS_ALTITUDE      = 6
+)
+
+// Values are read up to this size, the ones we show are much smaller
+const maxEXIFValueBytes = 64 * 1024
+
+var exifOrientations = map[uint32]string{
+	1: "Normal",
+	2: "Mirrored",
+	3: "Rotated 180°",
+	4: "Mirrored and rotated 180°",
+	5: "Mirrored and rotated 90° counterclockwise",
+	6: "Rotated 90° clockwise",
+	7: "Mirrored and rotated 90° clockwise",
+	8: "Rotated 90° counterclockwise",
+}
+
+// The size of one value of each TIFF type, like 8 for a rational which is two 32-bit integers
+var tiffTypeSizes = map[uint16]int64{
+	1:  1, // Byte
+	2:  1, // ASCII
+	3:  2, // Short
+	4:  4, // Long
+	5:  8, // Rational
+	7:  1, // Undefined
+	9:  4, // Signed long
+	10: 8, // Signed rational
+}
+
+type tiffReader struct {
+	reader io.ReaderAt
+	order  binary.ByteOrder
+}
+
+type tiffEntry struct {
+	kind   uint16
+	count  uint32
+	offset int64 // Where the value is
+}
+
+// Returns the EXIF data in file if there is any, read from a JPEG, PNG, WebP or TIFF file
+func FindEXIF(file io.ReadSeeker) (io.ReaderAt, bool) {
+	head := make([]byte, 12)
+	_, err := io.ReadFull(file, head)
+	if err != nil {
+		return nil, false
+	}
+
+	var data []byte
+	switch {
+	case string(head[:4]) == "II*\x00" || string(head[:4]) == "MM\x00*":
+		if readerAt, ok := file.(io.ReaderAt); ok {
+			return readerAt, true
+		}
+		return nil, false
+	case head[0] == 0xff && head[1] == 0xd8:
+		data, err = jpegEXIF(file)
+	case string(head[:8]) == "\x89PNG\r\n\x1a\n":
+		data, err = chunkEXIF(file, 8, binary.BigEndian, "eXIf")
+	case string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		data, err = chunkEXIF(file, 12, binary.LittleEndian, "EXIF")
+	}
+
+	if err != nil || data == nil {
+		return nil, false
+	}
+	return bytes.NewReader(bytes.TrimPrefix(data, []byte("Exif\x00\x00"))), true
+}
+
+// The EXIF data is in an APP1 segment, before the image data starts
+func jpegEXIF(file io.ReadSeeker) ([]byte, error) {
+	_, err := file.Seek(2, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		marker, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if marker != 0xff {
+			return nil, errors.New("Invalid JPEG marker")
+		}
+
+		kind, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		// Padding, or the segments without a length
+		if kind == 0xff || kind == 0x01 || (kind >= 0xd0 && kind <= 0xd8) {
+			continue
+		}
+		// Start of scan or end of image
+		if kind == 0xda || kind == 0xd9 {
+			return nil, nil
+		}
+
+		var length uint16
+		err = binary.Read(reader, binary.BigEndian, &length)
+		if err != nil {
+			return nil, err
+		}
+		if length < 2 {
+			return nil, errors.New("Invalid JPEG segment length")
+		}
+
+		segment := make([]byte, length-2)
+		_, err = io.ReadFull(reader, segment)
+		if err != nil {
+			return nil, err
+		}
+
+		if kind == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment, nil
+		}
+	}
+}
+
+// PNG and WebP files are made of chunks with a length and a name, only PNG chunks have a checksum after them
+func chunkEXIF(file io.ReadSeeker, start int64, order binary.ByteOrder, chunkName string) ([]byte, error) {
+	_, err := file.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	isPNG := order == binary.BigEndian
+	header := make([]byte, 8)
+	for {
+		_, err = io.ReadFull(file, header)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var length uint32
+		var name string
+		if isPNG {
+			length, name = order.Uint32(header), string(header[4:])
+		} else {
+			name, length = string(header[:4]), order.Uint32(header[4:])
+		}
+
+		if name == chunkName {
+			if length > maxEXIFValueBytes*16 {
+				return nil, errors.New("EXIF chunk too large")
+			}
+			data := make([]byte, length)
+			_, err = io.ReadFull(file, data)
+			return data, err
+		}
+		if name == "IEND" {
+			return nil, nil
+		}
+
+		skip := int64(length)
+		if isPNG {
+			skip += 4
+		} else {
+			skip += int64(length % 2)
+		}
+		_, err = file.Seek(skip, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Returns labeled values like {"Camera", "Canon EOS 5D"}, or an error if data isn't valid TIFF data
+func EXIFDetails(data io.ReaderAt) ([][2]string, error) {
+	header := make([]byte, 8)
+	_, err := data.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tiff := tiffReader{reader: data}
+	switch string(header[:2]) {
+	case "II":
+		tiff.order = binary.LittleEndian
+	case "MM":
+		tiff.order = binary.BigEndian
+	default:
+		return nil, errors.New("Invalid TIFF byte order")
+	}
+
+	ifd0, err := tiff.readIFD(int64(tiff.order.Uint32(header[4:])))
+	if err != nil {
+		return nil, err
+	}
+
+	exif := map[uint16]tiffEntry{}
+	if pointer, ok := tiff.uint(ifd0, EXIF_IFD_POINTER); ok {
+		exif, _ = tiff.readIFD(int64(pointer))
+	}
+	gps := map[uint16]tiffEntry{}
+	if pointer, ok := tiff.uint(ifd0, EXIF_GPS_IFD_POINTER); ok {
+		gps, _ = tiff.readIFD(int64(pointer))
+	}
+
+	var details [][2]string
+	add := func(label, value string) {
+		if value != "" {
+			details = append(details, [2]string{label, value})
+		}
+	}
+
+	// Models usually have the make in them already, like "Canon EOS 5D"
+	cameraMake := tiff.string(ifd0, EXIF_MAKE)
+	model := tiff.string(ifd0, EXIF_MODEL)
+	if cameraMake != "" && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(cameraMake)) {
+		model = strings.TrimSpace(cameraMake + " " + model)
+	}
+	add("Camera", model)
+	add("Lens", tiff.string(exif, EXIF_LENS_MODEL))
+
+	date := tiff.string(exif, EXIF_DATE_TIME_ORIGINAL)
+	if date == "" {
+		date = tiff.string(ifd0, EXIF_DATE_TIME)
+	}
+	add("Date", EXIFDateString(date))
+
+	var exposure []string
+	if values := tiff.rationals(exif, EXIF_EXPOSURE_TIME); len(values) > 0 && values[0] > 0 {
+		exposure = append(exposure, ExposureTimeString(values[0]))
+	}
+	if values := tiff.rationals(exif, EXIF_F_NUMBER); len(values) > 0 && values[0] > 0 {
+		exposure = append(exposure, "f/"+strconv.FormatFloat(values[0], 'f', -1, 64))
+	}
+	if iso, ok := tiff.uint(exif, EXIF_ISO); ok {
+		exposure = append(exposure, "ISO "+strconv.Itoa(int(iso)))
+	}
+	if values := tiff.rationals(exif, EXIF_FOCAL_LENGTH); len(values) > 0 && values[0] > 0 {
+		exposure = append(exposure, strconv.FormatFloat(values[0], 'f', -1, 64)+" mm")
+	}
+	add("Exposure", strings.Join(exposure, ", "))
+
+	if orientation, ok := tiff.uint(ifd0, EXIF_ORIENTATION); ok {
+		add("Orientation", exifOrientations[orientation])
+	}
+
+	latitude := tiff.rationals(gps, GPS_LATITUDE)
+	longitude := tiff.rationals(gps, GPS_LONGITUDE)
+	if len(latitude) == 3 && len(longitude) == 3 {
+		add("GPS", GPSCoordinatesString(latitude, tiff.string(gps, GPS_LATITUDE_REF), longitude, tiff.string(gps, GPS_LONGITUDE_REF)))
+	}
+	if altitude := tiff.rationals(gps, GPS_ALTITUDE); len(altitude) > 0 {
+		// A reference of 1 means below sea level
+		if reference, ok := tiff.uint(gps, GPS_ALTITUDE_REF); ok && reference == 1 {
+			altitude[0] = -altitude[0]
+		}
+		add("Altitude", strconv.FormatFloat(math.Round(altitude[0]), 'f', -1, 64)+" m")
+	}
+
+	add("Software", tiff.string(ifd0, EXIF_SOFTWARE))
+
+	return details, nil
+}
+
+func (tiff tiffReader) readIFD(offset int64) (map[uint16]tiffEntry, error) {
+	countBytes := make([]byte, 2)
+	_, err := tiff.reader.ReadAt(countBytes, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	count := int64(tiff.order.Uint16(countBytes))
+	entries := make([]byte, count*12)
+	_, err = tiff.reader.ReadAt(entries, offset+2)
+	if err != nil {
+		return nil, err
+	}
+
+	ifd := make(map[uint16]tiffEntry)
+	for i := int64(0); i < count; i++ {
+		entry := entries[i*12 : i*12+12]
+		tag := tiff.order.Uint16(entry)
+		kind := tiff.order.Uint16(entry[2:])
+		valueCount := tiff.order.Uint32(entry[4:])
+
+		// Values of 4 bytes or less are in the entry itself
+		valueOffset := offset + 2 + i*12 + 8
+		if tiffTypeSizes[kind]*int64(valueCount) > 4 {
+			valueOffset = int64(tiff.order.Uint32(entry[8:]))
+		}
+		ifd[tag] = tiffEntry{kind: kind, count: valueCount, offset: valueOffset}
+	}
+	return ifd, nil
+}
+
+// Returns nil if the tag isn't there or has an unknown type
+func (tiff tiffReader) value(ifd map[uint16]tiffEntry, tag uint16) []byte {
+	entry, ok := ifd[tag]
+	if !ok || tiffTypeSizes[entry.kind] == 0 {
+		return nil
+	}
+
+	size := tiffTypeSizes[entry.kind] * int64(entry.count)
+	if size > maxEXIFValueBytes {
+		return nil
+	}
+
+	data := make([]byte, size)
+	_, err := tiff.reader.ReadAt(data, entry.offset)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func (tiff tiffReader) string(ifd map[uint16]tiffEntry, tag uint16) string {
+	text := string(tiff.value(ifd, tag))
+	if i := strings.IndexByte(text, 0); i != -1 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(text, ""))
+}
+
+// The first value of a byte, short or long tag
+func (tiff tiffReader) uint(ifd map[uint16]tiffEntry, tag uint16) (uint32, bool) {
+	data := tiff.value(ifd, tag)
+	if len(data) == 0 {
+		return 0, false
+	}
+
+	switch ifd[tag].kind {
+	case 1:
+		return uint32(data[0]), true
+	case 3:
+		return uint32(tiff.order.Uint16(data)), true
+	case 4:
+		return tiff.order.Uint32(data), true
+	}
+	return 0, false
+}
+
+func (tiff tiffReader) rationals(ifd map[uint16]tiffEntry, tag uint16) []float64 {
+	data := tiff.value(ifd, tag)
+	kind := ifd[tag].kind
+	if kind != 5 && kind != 10 {
+		return nil
+	}
+
+	var values []float64
+	for i := 0; i+8 <= len(data); i += 8 {
+		numerator := float64(tiff.order.Uint32(data[i:]))
+		denominator := float64(tiff.order.Uint32(data[i+4:]))
+		if kind == 10 {
+			numerator = float64(int32(tiff.order.Uint32(data[i:])))
+			denominator = float64(int32(tiff.order.Uint32(data[i+4:])))
+		}
+		if denominator == 0 {
+			return nil
+		}
+		values = append(values, numerator/denominator)
+	}
+	return values
+}
+
+// "2024:01:02 15:04:05" becomes "2024-01-02 15:04:05"
+func EXIFDateString(date string) string {
+	if len(date) < 10 || date[4] != ':' || date[7] != ':' {
+		return date
+	}
+	return date[:4] + "-" + date[5:7] + "-" + date[8:]
+}
+
+// Like "1/250 s" for short exposures, or "2.5 s"
+func ExposureTimeString(seconds float64) string {
+	if seconds < 1 {
+		return "1/" + strconv.Itoa(int(math.Round(1/seconds))) + " s"
+	}
+	return strconv.FormatFloat(seconds, 'f', -1, 64) + " s"
+}
+
+// Like "59.91234, -10.75012", from degrees, minutes and seconds with references like "N" or "W"
+func GPSCoordinatesString(latitude []float64, latitudeReference string, longitude []float64, longitudeReference string) string {
+	toDecimal := func(values []float64, negative bool) string {
+		decimal := values[0] + values[1]/60 + values[2]/3600
+		if negative {
+			decimal = -decimal
+		}
+		return strconv.FormatFloat(decimal, 'f', 5, 64)
+	}
+	return toDecimal(latitude, latitudeReference == "S") + ", " + toDecimal(longitude, longitudeReference == "W")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+type exifTestEntry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// Little-endian TIFF data with IFD0, an EXIF IFD and a GPS IFD
+func exifTestData() []byte {
+	le := binary.LittleEndian
+	data := []byte("II*\x00\x08\x00\x00\x00")
+
+	// Where IFD0, the EXIF IFD and the GPS IFD will be
+	var ifdOffsets [3]uint32
+	ifds := [3][]exifTestEntry{
+		{
+			{EXIF_MAKE, 2, 6, []byte("Canon\x00")},
+			{EXIF_MODEL, 2, 13, []byte("Canon EOS 5D\x00")},
+			{EXIF_ORIENTATION, 3, 1, le.AppendUint16(nil, 6)},
+			{EXIF_IFD_POINTER, 4, 1, nil},
+			{EXIF_GPS_IFD_POINTER, 4, 1, nil},
+		},
+		{
+			{EXIF_EXPOSURE_TIME, 5, 1, le.AppendUint32(le.AppendUint32(nil, 1), 250)},
+			{EXIF_F_NUMBER, 5, 1, le.AppendUint32(le.AppendUint32(nil, 28), 10)},
+			{EXIF_ISO, 3, 1, le.AppendUint16(nil, 400)},
+			{EXIF_DATE_TIME_ORIGINAL, 2, 20, []byte("2024:01:02 15:04:05\x00")},
+		},
+		{
+			{GPS_LATITUDE_REF, 2, 2, []byte("N\x00")},
+			{GPS_LATITUDE, 5, 3, exifTestRationals(59, 1, 54, 1, 3600, 100)},
+			{GPS_LONGITUDE_REF, 2, 2, []byte("W\x00")},
+			{GPS_LONGITUDE, 5, 3, exifTestRationals(10, 1, 45, 1, 0, 1)},
+		},
+	}
+
+	// Each IFD is the amount of entries, 12 bytes per entry and the next IFD offset, followed by the values that don't fit in the entries
+	offset := uint32(8)
+	for i, ifd := range ifds {
+		ifdOffsets[i] = offset
+		offset += 2 + uint32(len(ifd))*12 + 4
+		for _, entry := range ifd {
+			if len(entry.value) > 4 {
+				offset += uint32(len(entry.value))
+			}
+		}
+	}
+	ifds[0][3].value = le.AppendUint32(nil, ifdOffsets[1])
+	ifds[0][4].value = le.AppendUint32(nil, ifdOffsets[2])
+
+	for _, ifd := range ifds {
+		valuesOffset := uint32(len(data)) + 2 + uint32(len(ifd))*12 + 4
+		var values []byte
+		data = le.AppendUint16(data, uint16(len(ifd)))
+		for _, entry := range ifd {
+			data = le.AppendUint16(data, entry.tag)
+			data = le.AppendUint16(data, entry.kind)
+			data = le.AppendUint32(data, entry.count)
+			if len(entry.value) > 4 {
+				data = le.AppendUint32(data, valuesOffset+uint32(len(values)))
+				values = append(values, entry.value...)
+			} else {
+				data = append(data, entry.value...)
+				data = append(data, make([]byte, 4-len(entry.value))...)
+			}
+		}
+		data = le.AppendUint32(data, 0)
+		data = append(data, values...)
+	}
+	return data
+}
+
+func exifTestRationals(values ...uint32) []byte {
+	var data []byte
+	for _, value := range values {
+		data = binary.LittleEndian.AppendUint32(data, value)
+	}
+	return data
+}
+
+func TestEXIFDetails(t *testing.T) {
+	tiff := exifTestData()
+
+	// In a JPEG file, the EXIF data is in an APP1 segment
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte("\xff\xd8\xff\xe1")
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(2+len(app1)))
+	jpeg = append(jpeg, app1...)
+	jpeg = append(jpeg, "\xff\xda\x00\x02"...)
+
+	expected := [][2]string{
+		{"Camera", "Canon EOS 5D"},
+		{"Date", "2024-01-02 15:04:05"},
+		{"Exposure", "1/250 s, f/2.8, ISO 400"},
+		{"Orientation", "Rotated 90° clockwise"},
+		{"GPS", "59.91000, -10.75000"},
+	}
+
+	for name, file := range map[string][]byte{"TIFF": tiff, "JPEG": jpeg} {
+		exif, ok := FindEXIF(bytes.NewReader(file))
+		if !ok {
+			t.Fatal("Expected to find EXIF data in the " + name + " file")
+		}
+
+		details, err := EXIFDetails(exif)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(details) != len(expected) {
+			t.Fatal("Expected " + strconv.Itoa(len(expected)) + " details in the " + name + " file, got " + strconv.Itoa(len(details)))
+		}
+		for i := range expected {
+			if details[i] != expected[i] {
+				t.Fatal("Expected \"" + expected[i][0] + ": " + expected[i][1] + "\" but got \"" + details[i][0] + ": " + details[i][1] + "\"")
+			}
+		}
+	}
+}
+
+func TestFindEXIFWithoutEXIF(t *testing.T) {
+	files := []string{
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\x00IEND\xae\x42\x60\x82",
+		"\xff\xd8\xff\xda\x00\x02",
+		"GIF89a\x01\x00\x01\x00\x00\x00\x00",
+		"short",
+	}
+
+	for _, file := range files {
+		if _, ok := FindEXIF(bytes.NewReader([]byte(file))); ok {
+			t.Fatal("Expected no EXIF data in " + file)
+		}
+	}
+}
+
+func TestExposureTimeString(t *testing.T) {
+	tests := map[float64]string{
+		0.004: "1/250 s",
+		0.5:   "1/2 s",
+		1:     "1 s",
+		2.5:   "2.5 s",
+	}
+
+	for seconds, expected := range tests {
+		if got := ExposureTimeString(seconds); got != expected {
+			t.Fatal("Expected \"" + expected + "\" but got \"" + got + "\"")
+		}
+	}
+}
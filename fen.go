@@ -12,8 +12,10 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kivattt/gogitstatus"
 	"github.com/rivo/tview"
@@ -28,12 +30,15 @@ type Fen struct {
 	sel              string
 	lastSel          string
 	lastInRepository string
-	history          History
+	history          *History
 
 	selected     map[string]bool
 	yankSelected map[string]bool
 	yankType     string // "", "copy", "cut"
 
+	filter       string // Only entries matching this are shown in filterFolder, see FilterMatches()
+	filterFolder string
+
 	selectingWithV               bool
 	selectingWithVStartIndex     int
 	selectingWithVEndIndex       int
@@ -50,13 +55,57 @@ type Fen struct {
 	runningGitStatus     bool
 	initializedGitStatus bool // This is for Fini() because the user might have disabled git_status in the options menu
 
+	globalSelectionHandler     GlobalSelectionHandler
+	initializedGlobalSelection bool
+
 	folderFileCountCache map[string]int
+	folderSummaries      *FolderSummaryWorker // See foldersummary.go
 
 	topBar     *TopBar
 	bottomBar  *BottomBar
 	leftPane   *FilesPane
 	middlePane *FilesPane
 	rightPane  *FilesPane
+	panesFlex  *tview.Flex // Holds the filespanes of the current tab, see fen.UpdateLayout()
+
+	// See layout.go
+	parentPanes       []*FilesPane
+	shownPanes        []*FilesPane // What fen.UpdateLayout() put in panesFlex
+	fullscreenPreview bool
+
+	// See preview.go and previewworker.go
+	previewWorker       *PreviewWorker
+	previewCacheFolder  string // Created when first needed, see previewcache.go
+	previewScrollPath   string // fen.sel when the scroll offset was last reset
+	previewScrollY      int
+	previewScrollX      int
+	previewScrollLimitY int  // -1 when unknown
+	previewTrueColor    bool // Whether the terminal supports truecolor, for image previews
+
+	// See previewfollow.go
+	previewFollowPath    string // The followed file, empty when not following
+	previewFollowRotated bool   // The followed file is gone, it's selected again when it's back
+	previewFollower      *PreviewFollower
+
+	// See terminalimage.go
+	terminalImages        TerminalImageHandler
+	detectedImageProtocol string // Used when fen.image_protocol is "auto"
+
+	tabs       []*Tab
+	currentTab int
+	lastTab    int // The tab we were in before switching to the current one
+
+	marks       map[rune]Mark // Set with m<letter>, see marks.go
+	frecency    *FrecencyDatabase
+	folderSorts map[string]FolderSort // Sort modes set for specific folders in the sort menu, see foldersort.go
+
+	// See localconfig.go
+	localConfig              *LocalConfig // The .fen.lua file currently layered on top of fen.config, nil if none
+	configWithoutLocalConfig Config       // fen.config before localConfig was applied
+	localConfigCheckedWD     string
+	trustedLocalConfigs      map[string]string // .fen.lua paths to the hash of their trusted contents
+	distrustedLocalConfigs   map[string]string
+	askToTrustLocalConfig    func(path, hash string) bool // Set in main.go, returns false if it couldn't ask right now
 
 	showHomePathAsTilde bool
 }
@@ -94,6 +143,25 @@ type Config struct {
 	PreviewSafetyBlocklist  bool                 `lua:"preview_safety_blocklist"`
 	CloseOnEscape           bool                 `lua:"close_on_escape"`
 	FileSizeInAllPanes      bool                 `lua:"file_size_in_all_panes"`
+	Layout                  string               `lua:"layout"`
+	Frecency                bool                 `lua:"frecency"`
+	HistorySize             int                  `lua:"history_size"`
+	GlobalSelection         bool                 `lua:"global_selection"`
+	FolderSort              []FolderSortEntry    `lua:"folder_sort"`
+	LocalConfig             bool                 `lua:"local_config"`
+	PaneRatios              []int                `lua:"pane_ratios"`
+	ParentColumns           int                  `lua:"parent_columns"`
+	CollapseEmptyPanes      bool                 `lua:"collapse_empty_panes"`
+	DetailedView            bool                 `lua:"detailed_view"`
+	DetailedViewColumns     []DetailedViewColumn `lua:"detailed_view_columns"`
+	PreviewTimeoutMs        int                  `lua:"preview_timeout_ms"`
+	PreviewMaxOutputBytes   int                  `lua:"preview_max_output_bytes"`
+	PreviewCache            bool                 `lua:"preview_cache"`
+	BuiltInPreviews         bool                 `lua:"built_in_previews"`
+	PreviewLineNumbers      bool                 `lua:"preview_line_numbers"`
+	PreviewCacheSizeMb      int                  `lua:"preview_cache_size_mb"`
+	ImageProtocol           string               `lua:"image_protocol"`
+	FolderSummary           bool                 `lua:"folder_summary"`
 }
 
 func NewConfigDefaultValues() Config {
@@ -108,6 +176,17 @@ func NewConfigDefaultValues() Config {
 		FileEventIntervalMillis: 300,
 		ScrollSpeed:             2,
 		PreviewSafetyBlocklist:  true,
+		Layout:                  LAYOUT_MILLER,
+		Frecency:                true,
+		HistorySize:             1000,
+		PaneRatios:              slices.Clone(DefaultPaneRatios),
+		ParentColumns:           1,
+		PreviewTimeoutMs:        5000,
+		PreviewMaxOutputBytes:   1000000,
+		PreviewCache:            true,
+		BuiltInPreviews:         true,
+		PreviewCacheSizeMb:      100,
+		ImageProtocol:           IMAGE_PROTOCOL_AUTO,
 	}
 }
 
@@ -118,9 +197,30 @@ const (
 	SORT_MODIFIED       = "modified"
 	SORT_SIZE           = "size"
 	SORT_FILE_EXTENSION = "file-extension"
+	SORT_NATURAL        = "natural"    // Like alphabetical, but numbers are compared by value (file2 before file10)
+	SORT_CREATED        = "created"    // Not supported on all filesystems
+	SORT_ACCESSED       = "accessed"   // Often not updated, depending on mount options like noatime and relatime
+	SORT_FILE_TYPE      = "file-type"  // Grouped by the file categories from FileColor()
+	SORT_GIT_STATUS     = "git-status" // Unstaged/untracked files first, only when fen.git_status = true
+)
+
+var ValidSortByValues = [...]string{SORT_NONE, SORT_ALPHABETICAL, SORT_NATURAL, SORT_MODIFIED, SORT_CREATED, SORT_ACCESSED, SORT_SIZE, SORT_FILE_EXTENSION, SORT_FILE_TYPE, SORT_GIT_STATUS}
+
+const (
+	LAYOUT_MILLER    = "miller"    // Parent folder, current folder and preview columns, like ranger
+	LAYOUT_COMMANDER = "commander" // Two panels side by side, like Midnight Commander
 )
 
-var ValidSortByValues = [...]string{SORT_NONE, SORT_ALPHABETICAL, SORT_MODIFIED, SORT_SIZE, SORT_FILE_EXTENSION}
+var ValidLayoutValues = [...]string{LAYOUT_MILLER, LAYOUT_COMMANDER}
+
+const (
+	IMAGE_PROTOCOL_AUTO  = "auto"  // Detected from environment variables, see DetectImageProtocol()
+	IMAGE_PROTOCOL_KITTY = "kitty" // The kitty graphics protocol, also supported by WezTerm and Ghostty
+	IMAGE_PROTOCOL_SIXEL = "sixel"
+	IMAGE_PROTOCOL_NONE  = "none" // Images are shown with colored blocks
+)
+
+var ValidImageProtocolValues = [...]string{IMAGE_PROTOCOL_AUTO, IMAGE_PROTOCOL_KITTY, IMAGE_PROTOCOL_SIXEL, IMAGE_PROTOCOL_NONE}
 
 // To prevent previewing sensitive files
 var DefaultPreviewBlocklistCaseInsensitive = []string{
@@ -172,10 +272,11 @@ var DefaultPreviewBlocklistCaseInsensitive = []string{
 }
 
 type PreviewOrOpenEntry struct {
-	Script     string
-	Program    []string // The name used to be "Programs", but this makes more sense for the lua configuration
-	Match      []string
-	DoNotMatch []string
+	Script        string
+	Program       []string // The name used to be "Programs", but this makes more sense for the lua configuration
+	Match         []string
+	DoNotMatch    []string
+	ScrollsItself bool // Only for fen.preview, the program output is not scrolled by fen, since the program uses $FEN_PREVIEW_SCROLL_Y
 }
 
 type PanePos int
@@ -190,6 +291,15 @@ func (fen *Fen) Init(path string, app *tview.Application, helpScreenVisible *boo
 	fen.app = app
 	fen.fileOperationsHandler = FileOperationsHandler{fen: fen}
 	fen.folderFileCountCache = make(map[string]int)
+	fen.previewWorker = NewPreviewWorker(func() {
+		app.QueueUpdateDraw(func() {})
+	})
+	fen.folderSummaries = NewFolderSummaryWorker(func() {
+		app.QueueUpdateDraw(func() {})
+	})
+	fen.previewFollower = NewPreviewFollower(func() {
+		app.QueueUpdateDraw(func() {})
+	})
 
 	if fen.config.GitStatus {
 		fen.gitStatusHandler = GitStatusHandler{app: app, fen: fen}
@@ -209,8 +319,19 @@ func (fen *Fen) Init(path string, app *tview.Application, helpScreenVisible *boo
 
 	fen.selectedBeforeSelectingWithV = map[string]bool{}
 
+	fen.LoadMarks()
+	fen.LoadFrecency()
+	fen.LoadFolderSorts()
+	fen.LoadTrustedLocalConfigs()
+
 	fen.wd = path
 	fen.sel = path // fen.sel has to be set so fen.UpdatePanes() doesn't panic, it's set accordingly when fen.UpdatePanes() completes.
+	fen.history = &History{}
+	fen.LoadHistory()
+
+	if fen.config.GlobalSelection {
+		fen.InitGlobalSelection()
+	}
 
 	fen.topBar = NewTopBar(fen)
 
@@ -222,6 +343,49 @@ func (fen *Fen) Init(path string, app *tview.Application, helpScreenVisible *boo
 	fen.middlePane.Init()
 	fen.rightPane.Init()
 
+	err := ValidateFolderSortEntries(fen.config.FolderSort)
+	if err != nil {
+		return err
+	}
+
+	err = ValidatePaneLayout(&fen.config)
+	if err != nil {
+		return err
+	}
+
+	err = ValidateDetailedViewColumns(fen.config.DetailedViewColumns)
+	if err != nil {
+		return err
+	}
+
+	if fen.config.PreviewTimeoutMs < 0 {
+		return errors.New("Invalid preview_timeout_ms value " + strconv.Itoa(fen.config.PreviewTimeoutMs) + ", it can't be negative")
+	}
+
+	if fen.config.PreviewMaxOutputBytes < 0 {
+		return errors.New("Invalid preview_max_output_bytes value " + strconv.Itoa(fen.config.PreviewMaxOutputBytes) + ", it can't be negative")
+	}
+
+	if fen.config.PreviewCacheSizeMb < 0 {
+		return errors.New("Invalid preview_cache_size_mb value " + strconv.Itoa(fen.config.PreviewCacheSizeMb) + ", it can't be negative")
+	}
+
+	if !slices.Contains(ValidLayoutValues[:], fen.config.Layout) {
+		return errors.New("Invalid layout value \"" + fen.config.Layout + "\"\nValid values: " + strings.Join(ValidLayoutValues[:], ", "))
+	}
+
+	if !slices.Contains(ValidImageProtocolValues[:], fen.config.ImageProtocol) {
+		return errors.New("Invalid image_protocol value \"" + fen.config.ImageProtocol + "\"\nValid values: " + strings.Join(ValidImageProtocolValues[:], ", "))
+	}
+	fen.detectedImageProtocol = DetectImageProtocol(os.Getenv)
+
+	fen.tabs = []*Tab{{}}
+	fen.leftPane.tab = fen.tabs[0]
+	fen.middlePane.tab = fen.tabs[0]
+	fen.rightPane.tab = fen.tabs[0]
+	fen.panesFlex = tview.NewFlex().SetDirection(tview.FlexColumn)
+	fen.UpdateLayout()
+
 	fen.bottomBar = NewBottomBar(fen)
 
 	wdFiles, err := os.ReadDir(fen.wd)
@@ -249,19 +413,53 @@ func (fen *Fen) Init(path string, app *tview.Application, helpScreenVisible *boo
 
 		if shouldSelectSpecifiedFile {
 			fen.sel = path
+		} else if historyEntry, historyErr := fen.history.GetHistoryEntryForPath(fen.wd, fen.config.HiddenFiles); historyErr == nil {
+			fen.sel = historyEntry
 		}
 	}
 
 	fen.history.AddToHistory(fen.sel)
 	fen.UpdatePanes(false)
 
+	// Has to be done after fen.UpdatePanes(), since it might open a new tab at the current path
+	fen.SetLayout(fen.config.Layout)
+
 	return err
 }
 
 func (fen *Fen) Fini() {
-	fen.leftPane.fileWatcher.Close()
-	fen.middlePane.fileWatcher.Close()
-	fen.rightPane.fileWatcher.Close()
+	fen.SaveFrecency()
+
+	// fen.Init() might have returned an error before it was created
+	if fen.previewWorker != nil {
+		fen.previewWorker.CancelAll()
+	}
+	if fen.folderSummaries != nil {
+		fen.folderSummaries.CancelAll()
+	}
+	if fen.previewFollower != nil {
+		fen.previewFollower.Close()
+	}
+	fen.terminalImages.Hide()
+
+	// fen.Init() might have returned an error before the tabs were created
+	if len(fen.tabs) > 0 {
+		fen.saveTab(fen.tabs[fen.currentTab])
+		fen.SaveHistory()
+
+		for _, tab := range fen.tabs {
+			tab.leftPane.Close()
+			tab.middlePane.Close()
+			tab.rightPane.Close()
+			for _, parentPane := range tab.parentPanes {
+				parentPane.Close()
+			}
+		}
+	}
+
+	if fen.initializedGlobalSelection {
+		fen.globalSelectionHandler.Close()
+	}
 
 	if fen.initializedGitStatus {
 		fen.gitStatusHandler.gitIndexFileWatcher.Close()
@@ -271,8 +469,62 @@ func (fen *Fen) Fini() {
 	}
 }
 
+// Errors are ignored, the selection will only be local to this fen instance
+func (fen *Fen) InitGlobalSelection() {
+	if fen.initializedGlobalSelection {
+		return
+	}
+
+	err := fen.globalSelectionHandler.Init(fen)
+	fen.initializedGlobalSelection = err == nil
+}
+
+// Puts the filespanes of the current tab on screen, call it whenever fen.leftPane, fen.middlePane or fen.rightPane change
+func (fen *Fen) UpdateLayout() {
+	fen.panesFlex.Clear()
+
+	otherTab := fen.OtherTabIndex()
+	if fen.config.Layout == LAYOUT_COMMANDER && otherTab != -1 {
+		// The panels keep their positions when switching between them
+		leftTab, rightTab := fen.currentTab, otherTab
+		if rightTab < leftTab {
+			leftTab, rightTab = rightTab, leftTab
+		}
+
+		panel := func(tabIndex int) tview.Primitive {
+			middlePane := fen.middlePane
+			if tabIndex != fen.currentTab {
+				middlePane = fen.tabs[tabIndex].middlePane
+			}
+
+			return tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(NewCommanderPanelHeader(fen, tabIndex), 1, 0, false).
+				AddItem(middlePane, 0, 1, false)
+		}
+
+		fen.panesFlex.
+			AddItem(panel(leftTab), 0, 1, false).
+			AddItem(nil, 1, 0, false).
+			AddItem(panel(rightTab), 0, 1, false)
+		return
+	}
+
+	fen.ensureParentPanes()
+	fen.shownPanes = fen.visiblePanes()
+	for _, pane := range fen.shownPanes {
+		ratio := fen.config.PaneRatios[pane.panePos]
+		if len(fen.shownPanes) == 1 {
+			ratio = 1
+		}
+		fen.panesFlex.AddItem(pane, 0, ratio, false)
+	}
+}
+
 func (fen *Fen) InvalidateFolderFileCountCache() {
 	fen.folderFileCountCache = make(map[string]int)
+	if fen.folderSummaries != nil {
+		fen.folderSummaries.Invalidate()
+	}
 }
 
 func (fen *Fen) PushAndSetTerminalTitle() {
@@ -325,7 +577,14 @@ func (fen *Fen) ReadConfig(path string) error {
 			fieldValue := defaultConfigReflectValues.Field(i).Bool()
 			luaInitialConfigTable.RawSetString(fieldName, lua.LBool(fieldValue))
 		case reflect.Slice: // fen.open and fen.preview are set to empty lists (called a "table" in lua)
-			luaInitialConfigTable.RawSetString(fieldName, L.NewTable())
+			table := L.NewTable()
+			// Lists of numbers like fen.pane_ratios start out with their default values
+			if ints, ok := defaultConfigReflectValues.Field(i).Interface().([]int); ok {
+				for _, v := range ints {
+					table.Append(lua.LNumber(v))
+				}
+			}
+			luaInitialConfigTable.RawSetString(fieldName, table)
 		}
 	}
 
@@ -378,6 +637,10 @@ func (fen *Fen) ReadConfig(path string) error {
 		return errors.New("Failed to convert \"fen\" (of type " + fenGlobal.Type().String() + ") to a *lua.LTable")
 	}
 
+	// Decoding into an existing slice only overwrites its first elements, so fen.pane_ratios = {2, 3} would keep the last default value.
+	// Its default values are already in the Lua table
+	fen.config.PaneRatios = nil
+
 	err = mapper.Map(fenGlobalAsTablePointer, &fen.config)
 	if err != nil {
 		return err
@@ -460,6 +723,11 @@ func (fen *Fen) UpdatePanes(forceReadDir bool) {
 		_, err = os.Stat(fen.wd)
 	}
 
+	if fen.UpdateLocalConfig(forceReadDir) {
+		fen.InvalidateFolderFileCountCache()
+		forceReadDir = true
+	}
+
 	fen.leftPane.SetBorder(fen.config.UiBorders)
 	fen.middlePane.SetBorder(fen.config.UiBorders)
 	fen.rightPane.SetBorder(fen.config.UiBorders)
@@ -467,9 +735,14 @@ func (fen *Fen) UpdatePanes(forceReadDir bool) {
 	if fen.wd != fen.lastWD {
 		// Has to happen before the filespane ChangeDir() calls which will repopulate the cache
 		fen.InvalidateFolderFileCountCache()
+
+		if fen.config.Frecency {
+			fen.frecency.AddVisit(fen.wd, time.Now())
+		}
 	}
 	defer func() {
 		fen.lastWD = fen.wd
+		fen.updateCollapsedPanes()
 	}()
 
 	fen.leftPane.ChangeDir(filepath.Dir(fen.wd), forceReadDir)
@@ -480,11 +753,14 @@ func (fen *Fen) UpdatePanes(forceReadDir bool) {
 	} else {
 		fen.leftPane.SetSelectedEntryFromString(filepath.Base(fen.wd))
 	}
+	fen.updateParentPanes(forceReadDir)
 
+	fen.selectRotatedFollowedFile()
 	fen.middlePane.SetSelectedEntryFromString(filepath.Base(fen.sel))
 	fen.KeepMiddlePaneSelectionInBounds()
 
 	fen.sel = filepath.Join(fen.wd, fen.middlePane.GetSelectedEntryFromIndex(fen.middlePane.selectedEntryIndex))
+	fen.resetPreviewScrollIfSelectionChanged()
 	fen.rightPane.ChangeDir(fen.sel, forceReadDir)
 
 	// Prevents showing 'empty' a second time in rightPane, if middlePane is already showing 'empty'
@@ -602,12 +878,18 @@ func (fen *Fen) HideFilepanes() {
 	fen.leftPane.Invisible = true
 	fen.middlePane.Invisible = true
 	fen.rightPane.Invisible = true
+	for _, parentPane := range fen.parentPanes {
+		parentPane.Invisible = true
+	}
 }
 
 func (fen *Fen) ShowFilepanes() {
 	fen.leftPane.Invisible = false
 	fen.middlePane.Invisible = false
 	fen.rightPane.Invisible = false
+	for _, parentPane := range fen.parentPanes {
+		parentPane.Invisible = false
+	}
 }
 
 func (fen *Fen) RemoveFromSelectedAndYankSelected(path string) {
@@ -898,6 +1180,20 @@ func (fen *Fen) PageDown() {
 	}
 }
 
+// Hides every entry in the current folder not matching filter, an empty filter clears it.
+// Implicitly calls fen.UpdatePanes(true)
+func (fen *Fen) SetFilter(filter string) {
+	fen.filter = filter
+	fen.filterFolder = fen.wd
+	if filter == "" {
+		fen.filterFolder = ""
+	}
+
+	fen.DisableSelectingWithV()
+	fen.UpdatePanes(true)
+	fen.history.AddToHistory(fen.sel)
+}
+
 func (fen *Fen) GoSearchFirstMatch(searchTerm string) error {
 	if searchTerm == "" {
 		return errors.New("Empty search term")
@@ -1212,6 +1508,7 @@ func (fen *Fen) BulkRename(app *tview.Application) error {
 		return err
 	}
 
+	fen.terminalImages.Hide()
 	app.Suspend(func() {
 		var cmd *exec.Cmd
 		if runtime.GOOS == "windows" {
@@ -1282,6 +1579,7 @@ func (fen *Fen) BulkRename(app *tview.Application) error {
 	}
 
 	shouldBulkRenamePrompt := false
+	fen.terminalImages.Hide()
 	app.Suspend(func() {
 		fmt.Print("Bulk-rename on " + strconv.Itoa(len(preRenameList)) + " files? [y/N]: ")
 		reader := bufio.NewReader(os.Stdin)
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"slices"
@@ -20,8 +19,6 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
-	"github.com/yuin/gopher-lua"
-	"layeh.com/gopher-luar"
 )
 
 type FilesPane struct {
@@ -37,9 +34,12 @@ type FilesPane struct {
 	lastFileEventTime   time.Time
 	fileEventBatch      []fsnotify.Event
 	fileEventBatchMutex sync.Mutex
+	closed              atomic.Bool
 
 	lastRenamedPath     string
 	lastRenamedPathTime time.Time
+
+	tab *Tab // The tab this pane is in, see fp.inCurrentTab()
 }
 
 func NewFilesPane(fen *Fen, panePos PanePos) *FilesPane {
@@ -99,6 +99,10 @@ func (fp *FilesPane) Init() {
 		for {
 			time.Sleep(time.Duration(fp.fen.config.FileEventIntervalMillis) * time.Millisecond)
 
+			if fp.closed.Load() {
+				return
+			}
+
 			fp.fileEventBatchMutex.Lock()
 			if len(fp.fileEventBatch) == 0 {
 				fp.fileEventBatchMutex.Unlock()
@@ -120,6 +124,12 @@ func (fp *FilesPane) Init() {
 	}()
 }
 
+// Stops the file watcher, the filespane should not be used afterwards
+func (fp *FilesPane) Close() {
+	fp.closed.Store(true)
+	fp.fileWatcher.Close()
+}
+
 // Adds newEvent to oldEvents, removing duplicate and unnecessary prior events
 func AddEventToBatch(oldEvents []fsnotify.Event, newEvent fsnotify.Event) []fsnotify.Event {
 	newEventPathIsUnique := !slices.ContainsFunc(oldEvents, func(oldEvent fsnotify.Event) bool {
@@ -157,13 +167,19 @@ func (fp *FilesPane) HandleFileEvent(event fsnotify.Event) error {
 	}
 
 	if event.Has(fsnotify.Create) {
+		// Don't add entries hidden by the filter, they would only show up until the next FilterAndSortEntries()
+		if filter := fp.Filter(); filter != "" && !FilterMatches(filter, filepath.Base(event.Name)) {
+			return errors.New("Entry does not match the filter")
+		}
+
 		// A file temporarily renamed, then renamed back to its old path within 200 milliseconds is added back to the history.
 		// This is a hack to fix navigation because when vim saves a file it temporarily renames the file by appending a tilde (~),
 		//  then renaming it back to the original path within a very short period of time.
 		if time.Since(fp.lastRenamedPathTime) < 200*time.Millisecond {
 			if event.Name == fp.lastRenamedPath {
-				fp.fen.history.RemoveFromHistory(fp.GetSelectedPathFromIndex(fp.selectedEntryIndex))
-				fp.fen.history.AddToHistory(event.Name)
+				history, _ := fp.tabHistoryAndSelected()
+				history.RemoveFromHistory(fp.GetSelectedPathFromIndex(fp.selectedEntryIndex))
+				history.AddToHistory(event.Name)
 			}
 		}
 		return fp.AddEntry(event.Name)
@@ -213,10 +229,12 @@ func (fp *FilesPane) RemoveEntry(path string) error {
 	}
 
 	fp.entries.Store(append(fp.entries.Load().([]os.DirEntry)[:index], fp.entries.Load().([]os.DirEntry)[index+1:]...))
-	fp.fen.RemoveFromSelectedAndYankSelected(path) // FIXME: Panic when deleting 4000 files
+	history, selected := fp.tabHistoryAndSelected()
+	delete(selected, path) // FIXME: Panic when deleting 4000 files
+	delete(fp.fen.yankSelected, path)
 
-	fp.fen.history.RemoveFromHistory(path)
-	fp.fen.history.AddToHistory(fp.GetSelectedPathFromIndex(fp.selectedEntryIndex))
+	history.RemoveFromHistory(path)
+	history.AddToHistory(fp.GetSelectedPathFromIndex(fp.selectedEntryIndex))
 
 	return nil
 }
@@ -242,6 +260,8 @@ type FenLuaGlobal struct {
 	SelectedFile string
 	Width        int
 	Height       int
+	ScrollY      int // How many lines the preview is scrolled down
+	ScrollX      int // How many columns the preview is scrolled to the right
 	x            int
 	y            int
 	screen       tcell.Screen
@@ -348,16 +368,54 @@ func (fp *FilesPane) FilterAndSortEntries() {
 		fp.keepSelectionInBounds()
 	}
 
+	if filter := fp.Filter(); filter != "" {
+		matchingFilter := []os.DirEntry{}
+		for _, e := range fp.entries.Load().([]os.DirEntry) {
+			if FilterMatches(filter, e.Name()) {
+				matchingFilter = append(matchingFilter, e)
+			}
+		}
+
+		fp.entries.Store(matchingFilter)
+		fp.keepSelectionInBounds()
+	}
+
+	sortBy, sortReverse := fp.fen.SortForFolder(fp.folder)
+
 	// Sort the files as os.ReadDir() would, to guarantee the order
-	if fp.fen.config.SortBy != SORT_NONE {
+	if sortBy != SORT_NONE {
 		// Should be similar enough to https://cs.opensource.google/go/go/+/refs/tags/go1.23.2:src/os/dir.go;l=126
 		slices.SortFunc(fp.entries.Load().([]os.DirEntry), func(a, b fs.DirEntry) int {
 			return strings.Compare(a.Name(), b.Name())
 		})
 	}
 
-	switch fp.fen.config.SortBy {
+	// Sorts by a value looked up once per entry, since it may be slow to get (like the created time)
+	sortByTime := func(getTime func(info os.FileInfo, path string) (time.Time, error)) {
+		times := make(map[string]time.Time)
+		for _, e := range fp.entries.Load().([]os.DirEntry) {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+
+			t, err := getTime(info, filepath.Join(fp.folder, e.Name()))
+			if err == nil {
+				times[e.Name()] = t
+			}
+		}
+
+		slices.SortStableFunc(fp.entries.Load().([]os.DirEntry), func(a, b fs.DirEntry) int {
+			return times[a.Name()].Compare(times[b.Name()])
+		})
+	}
+
+	switch sortBy {
 	case SORT_ALPHABETICAL: // Since we already sort alphabetically above, we don't need to do anything
+	case SORT_NATURAL:
+		slices.SortStableFunc(fp.entries.Load().([]os.DirEntry), func(a, b fs.DirEntry) int {
+			return NaturalCompare(a.Name(), b.Name())
+		})
 	case SORT_MODIFIED:
 		slices.SortStableFunc(fp.entries.Load().([]os.DirEntry), func(a, b fs.DirEntry) int {
 			aInfo, aErr := a.Info()
@@ -417,16 +475,60 @@ func (fp *FilesPane) FilterAndSortEntries() {
 				return -1
 			}
 
+			return 1
+		})
+	case SORT_CREATED:
+		sortByTime(FileCreatedTime)
+	case SORT_ACCESSED:
+		sortByTime(func(info os.FileInfo, path string) (time.Time, error) {
+			return FileAccessedTime(info)
+		})
+	case SORT_FILE_TYPE:
+		categories := make(map[string]FileCategory)
+		for _, e := range fp.entries.Load().([]os.DirEntry) {
+			info, err := e.Info()
+			if err == nil {
+				categories[e.Name()] = FileCategoryOf(info, filepath.Join(fp.folder, e.Name()))
+			}
+		}
+
+		slices.SortStableFunc(fp.entries.Load().([]os.DirEntry), func(a, b fs.DirEntry) int {
+			return int(categories[a.Name()]) - int(categories[b.Name()])
+		})
+	case SORT_GIT_STATUS:
+		// Unstaged/untracked files first, does nothing until the git status is done
+		if !fp.fen.config.GitStatus {
+			break
+		}
+
+		repositoryPath, err := fp.fen.gitStatusHandler.TryFindTrackedParentGitRepository(fp.folder)
+		if err != nil {
+			break
+		}
+
+		changed := make(map[string]bool)
+		for _, e := range fp.entries.Load().([]os.DirEntry) {
+			changed[e.Name()] = fp.fen.gitStatusHandler.PathIsUnstagedOrUntracked(filepath.Join(fp.folder, e.Name()), repositoryPath)
+		}
+
+		slices.SortStableFunc(fp.entries.Load().([]os.DirEntry), func(a, b fs.DirEntry) int {
+			if changed[a.Name()] == changed[b.Name()] {
+				return 0
+			}
+
+			if changed[a.Name()] {
+				return -1
+			}
 			return 1
 		})
 	case SORT_NONE: // Does nothing, this has the side effect of making file events always show up at the bottom, until the entire folder is re-read
 	default:
-		fmt.Fprintln(os.Stderr, "Invalid sort_by value \""+fp.fen.config.SortBy+"\"")
+		fmt.Fprintln(os.Stderr, "Invalid sort_by value \""+sortBy+"\"")
 		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(ValidSortByValues[:], ", "))
 		os.Exit(1)
 	}
 
-	if fp.fen.config.SortBy != SORT_NONE && fp.fen.config.SortReverse {
+	if sortBy != SORT_NONE && sortReverse {
 		slices.Reverse(fp.entries.Load().([]os.DirEntry))
 	}
 
@@ -435,6 +537,33 @@ func (fp *FilesPane) FilterAndSortEntries() {
 	}
 }
 
+// Returns the filter applied to this filespane, or an empty string if there is none
+func (fp *FilesPane) Filter() string {
+	filter, filterFolder := fp.fen.filter, fp.fen.filterFolder
+	if !fp.inCurrentTab() {
+		filter, filterFolder = fp.tab.filter, fp.tab.filterFolder
+	}
+
+	if filter == "" || fp.folder != filterFolder {
+		return ""
+	}
+
+	return filter
+}
+
+// The state of the current tab is in the Fen struct, other tabs (like the other commander panel) have theirs in their Tab, see tabs.go
+func (fp *FilesPane) inCurrentTab() bool {
+	return fp.tab == nil || fp.fen.currentTab >= len(fp.fen.tabs) || fp.tab == fp.fen.tabs[fp.fen.currentTab]
+}
+
+// The history and selected files of the tab this pane is in
+func (fp *FilesPane) tabHistoryAndSelected() (*History, map[string]bool) {
+	if fp.inCurrentTab() {
+		return fp.fen.history, fp.fen.selected
+	}
+	return fp.tab.history, fp.tab.selected
+}
+
 func (fp *FilesPane) keepSelectionInBounds() bool {
 	// I think Load()ing entries multiple times like this could be unsafe, but might realistically be very rare
 	if fp.selectedEntryIndex >= len(fp.entries.Load().([]os.DirEntry)) {
@@ -460,7 +589,8 @@ func (fp *FilesPane) SetSelectedEntryFromString(entryName string) error {
 	}
 
 	fp.keepSelectionInBounds()
-	fp.fen.history.AddToHistory(fp.GetSelectedPathFromIndex(fp.selectedEntryIndex))
+	history, _ := fp.tabHistoryAndSelected()
+	history.AddToHistory(fp.GetSelectedPathFromIndex(fp.selectedEntryIndex))
 
 	return errors.New("No entry with name: " + entryName)
 }
@@ -577,7 +707,7 @@ func (fp *FilesPane) Draw(screen tcell.Screen) {
 
 	// File previews
 	stat, statErr := os.Stat(fp.fen.sel)
-	if fp.panePos == RightPane && len(fp.fen.config.Preview) > 0 && statErr == nil && stat.Mode().IsRegular() && fp.CanOpenFile(fp.fen.sel) && len(fp.entries.Load().([]os.DirEntry)) <= 0 {
+	if fp.panePos == RightPane && statErr == nil && stat.Mode().IsRegular() && fp.CanOpenFile(fp.fen.sel) && len(fp.entries.Load().([]os.DirEntry)) <= 0 {
 		w--
 
 		filenameResolved, err := filepath.EvalSymlinks(fp.fen.sel)
@@ -603,65 +733,46 @@ func (fp *FilesPane) Draw(screen tcell.Screen) {
 			return
 		}
 
-		for _, previewWith := range fp.fen.config.Preview {
-			matched := PathMatchesList(filenameResolved, previewWith.Match) && !PathMatchesList(filenameResolved, previewWith.DoNotMatch)
-			if !matched {
-				continue
-			}
+		fp.fen.previewTrueColor = screen.Colors() >= 1<<24
+		job, ok := fp.fen.NewPreviewJob(fp.fen.sel, w, h, fp.fen.previewScrollY, fp.fen.previewScrollX)
+		if !ok {
+			return
+		}
 
-			if previewWith.Script != "" {
-				L := lua.NewState()
-				defer L.Close()
-
-				fenLuaGlobal := &FenLuaGlobal{
-					SelectedFile: filenameResolved,
-					x:            x,
-					y:            y,
-					Width:        w,
-					Height:       h,
-					screen:       screen,
-				}
+		following := fp.fen.FollowingPreview()
+		if following {
+			fp.fen.previewFollower.Follow(fp.fen.sel, time.Duration(fp.fen.config.FileEventIntervalMillis)*time.Millisecond)
+		}
 
-				L.SetGlobal("fen", luar.New(L, fenLuaGlobal))
-				err := L.DoFile(previewWith.Script)
-				if err != nil {
-					fp.Box.DrawForSubclass(screen, fp)
-					tview.Print(screen, "File preview Lua error:", x, y, w, tview.AlignLeft, tcell.ColorRed)
-					lines := tview.WordWrap(err.Error(), w)
-					for i, line := range lines {
-						tview.Print(fenLuaGlobal.screen, line, x, y+1+i, w, tview.AlignLeft, tcell.ColorDefault)
-					}
-				}
-				return
-			}
+		result := fp.fen.previewWorker.Request(job, fp.fen.PrefetchPreviewJobs(w, h))
+		if result == nil && following {
+			result = fp.fen.previewWorker.LatestForPath(fp.fen.sel)
+		}
+		if result == nil {
+			tview.Print(screen, "[::d]loading…", x, y, w, tview.AlignLeft, tcell.ColorDefault)
+			return
+		}
 
-			for _, program := range previewWith.Program {
-				programSplitSpace := strings.Split(program, " ")
+		fp.DrawPreviewResult(screen, result, x, y, w, h)
 
-				programName := programSplitSpace[0]
-				programArguments := []string{}
-				if len(programSplitSpace) > 1 {
-					programArguments = programSplitSpace[1:]
-				}
-
-				cmd := exec.Command(programName, append(programArguments, fp.fen.sel)...)
+		// Following scrolled to the new end, which might need a new preview (like for a hex dump)
+		if following && fp.fen.previewScrollY != job.ScrollY {
+			go fp.fen.app.QueueUpdateDraw(func() {})
+		}
+		return
+	}
 
-				textView := tview.NewTextView()
-				textView.Box.SetRect(x, y, w, h)
-				textView.SetBackgroundColor(tcell.ColorDefault)
-				textView.SetTextColor(tcell.ColorDefault)
+	scrollOffset := fp.GetTopScreenEntryIndex()
 
-				cmd.Stdout = tview.ANSIWriter(textView)
+	if fp.panePos == RightPane && fp.fen.config.FolderSummary && statErr == nil && stat.IsDir() {
+		summaryHeight := fp.drawFolderSummary(screen, x, y, w, h)
+		y += summaryHeight
+		h -= summaryHeight
 
-				err := cmd.Run()
-				if err == nil {
-					textView.Draw(screen)
-					return
-				}
-			}
+		// Keeps the selected entry visible below the summary
+		if fp.selectedEntryIndex-scrollOffset >= h {
+			scrollOffset = max(0, fp.selectedEntryIndex-h+1)
 		}
-
-		return
 	}
 
 	var gitRepoContainingPath string
@@ -670,7 +781,22 @@ func (fp *FilesPane) Draw(screen tcell.Screen) {
 		gitRepoContainingPath, repoErr = fp.fen.gitStatusHandler.TryFindTrackedParentGitRepository(fp.folder)
 	}
 
-	scrollOffset := fp.GetTopScreenEntryIndex()
+	selected := fp.fen.SelectedForFilesPane(fp)
+
+	detailedView := fp.ShowsDetailedView()
+	var detailedCells [][]DetailedColumnCell
+	var detailedWidths []int
+	if detailedView {
+		visibleEntries := fp.entries.Load().([]os.DirEntry)[scrollOffset:]
+		visibleEntries = visibleEntries[:min(len(visibleEntries), max(0, h))]
+
+		gitRepository := ""
+		if fp.fen.config.GitStatus && repoErr == nil {
+			gitRepository = gitRepoContainingPath
+		}
+		detailedCells, detailedWidths = fp.DetailedViewCells(visibleEntries, gitRepository)
+	}
+
 	for i, entry := range fp.entries.Load().([]os.DirEntry)[scrollOffset:] {
 		// We don't draw at the bottom row of the screen, since it's occupied by the bottomBar
 		if i >= h {
@@ -683,10 +809,15 @@ func (fp *FilesPane) Draw(screen tcell.Screen) {
 
 		spaceForSelected := ""
 		if i+scrollOffset == fp.selectedEntryIndex {
-			style = style.Reverse(true)
+			if fp.panePos == MiddlePane && fp != fp.fen.middlePane {
+				// The panel not in focus in the commander layout
+				style = style.Underline(true)
+			} else {
+				style = style.Reverse(true)
+			}
 		}
 
-		_, contains := fp.fen.selected[entryFullPath]
+		_, contains := selected[entryFullPath]
 
 		if contains {
 			spaceForSelected = " "
@@ -710,7 +841,7 @@ func (fp *FilesPane) Draw(screen tcell.Screen) {
 		//styleStr := StyleToStyleTagString(style)
 
 		entrySizePrintedSize := 0
-		if fp.fen.config.FileSizeInAllPanes || fp.panePos == MiddlePane {
+		if (fp.fen.config.FileSizeInAllPanes || fp.panePos == MiddlePane) && !(detailedView && fp.fen.DetailedViewHasSizeColumn()) {
 			entrySizeText, err := EntrySizeText(fp.fen.folderFileCountCache, entryInfo, entryFullPath, fp.fen.config.HiddenFiles)
 			if err != nil {
 				entrySizeText = "?"
@@ -740,8 +871,32 @@ func (fp *FilesPane) Draw(screen tcell.Screen) {
 		}
 		screen.SetContent(xToUse, y+i, ' ', nil, style)
 		xToUse++
+
+		if detailedView {
+			var rowStyle *tcell.Style
+			if i+scrollOffset == fp.selectedEntryIndex {
+				rowStyle = &style
+			}
+
+			columnsPrinted := fp.DrawDetailedColumns(screen, xToUse, y+i, w-1-entrySizePrintedSize-(xToUse-x), detailedCells[i], detailedWidths, rowStyle)
+			xToUse += columnsPrinted
+			widthOffset -= columnsPrinted
+		}
+
 		leftSizePrinted := PrintFilenameInvisibleCharactersAsCodeHighlighted(screen, xToUse, y+i, w-1-entrySizePrintedSize+widthOffset, entry.Name(), style)
 
+		if detailedView {
+			if linkCell, ok := fp.DetailedLinkCell(detailedCells[i]); ok {
+				linkStyle := style.Foreground(linkCell.color).Bold(false)
+				_, linkPrinted := tview.Print(screen, " "+tview.Escape(linkCell.text), xToUse+leftSizePrinted, y+i, max(0, w-1-entrySizePrintedSize+widthOffset-leftSizePrinted), tview.AlignLeft, tcell.ColorDefault)
+				for k := 0; k < linkPrinted; k++ {
+					mainc, combc, _, _ := screen.GetContent(xToUse+leftSizePrinted+k, y+i)
+					screen.SetContent(xToUse+leftSizePrinted+k, y+i, mainc, combc, linkStyle)
+				}
+				leftSizePrinted += linkPrinted
+			}
+		}
+
 		for j := 0; j < w-1-leftSizePrinted-entrySizePrintedSize-(xToUse-x); j++ {
 			screen.SetContent(xToUse+leftSizePrinted+j, y+i, ' ', nil, style)
 		}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -50,3 +52,60 @@ func TestAddEventToBatch(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveEntryInBackgroundTab(t *testing.T) {
+	folder := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		err := os.WriteFile(filepath.Join(folder, name), nil, 0o644)
+		if err != nil {
+			t.Fatal("Failed to write " + name + ": " + err.Error())
+		}
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removedPath := filepath.Join(folder, "a.txt")
+	currentTab := &Tab{}
+	backgroundTab := &Tab{history: &History{}, selected: map[string]bool{removedPath: true}}
+	fen := &Fen{
+		history:      &History{},
+		selected:     map[string]bool{removedPath: true},
+		yankSelected: map[string]bool{removedPath: true},
+		tabs:         []*Tab{currentTab, backgroundTab},
+	}
+
+	pane := &FilesPane{fen: fen, folder: folder, tab: backgroundTab}
+	pane.entries.Store(entries)
+
+	err = pane.RemoveEntry(removedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fen.selected[removedPath] || len(fen.history.history) != 0 {
+		t.Fatal("Expected the current tab's selection and history to be left alone")
+	}
+	if backgroundTab.selected[removedPath] || len(backgroundTab.history.history) == 0 {
+		t.Fatal("Expected the background tab's selection and history to be updated")
+	}
+	if fen.yankSelected[removedPath] {
+		t.Fatal("Expected the removed file to not be yanked anymore, yanked files are shared between tabs")
+	}
+}
+
+func TestFilterInBackgroundTab(t *testing.T) {
+	backgroundTab := &Tab{filter: "txt", filterFolder: "/folder"}
+	fen := &Fen{filter: "go", filterFolder: "/folder", tabs: []*Tab{{}, backgroundTab}}
+
+	pane := &FilesPane{fen: fen, folder: "/folder", tab: backgroundTab}
+	if filter := pane.Filter(); filter != "txt" {
+		t.Fatal("Expected the filter of the pane's own tab \"txt\", but got \"" + filter + "\"")
+	}
+
+	pane.tab = fen.tabs[0]
+	if filter := pane.Filter(); filter != "go" {
+		t.Fatal("Expected the filter of the current tab \"go\", but got \"" + filter + "\"")
+	}
+}
@@ -0,0 +1,31 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+func FileCreatedTime(stat os.FileInfo, path string) (time.Time, error) {
+	syscallStat, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, errors.New("Unable to syscall stat")
+	}
+
+	return time.Unix(int64(syscallStat.Birthtimespec.Sec), int64(syscallStat.Birthtimespec.Nsec)), nil
+}
+
+func FileAccessedTime(stat os.FileInfo) (time.Time, error) {
+	syscallStat, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, errors.New("Unable to syscall stat")
+	}
+
+	return time.Unix(int64(syscallStat.Atimespec.Sec), int64(syscallStat.Atimespec.Nsec)), nil
+}
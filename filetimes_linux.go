@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Returns an error if the filesystem (or kernel older than 4.11) does not support it
+func FileCreatedTime(stat os.FileInfo, path string) (time.Time, error) {
+	var statx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &statx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if statx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, errors.New("Creation time not supported")
+	}
+
+	return time.Unix(statx.Btime.Sec, int64(statx.Btime.Nsec)), nil
+}
+
+func FileAccessedTime(stat os.FileInfo) (time.Time, error) {
+	syscallStat, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, errors.New("Unable to syscall stat")
+	}
+
+	return time.Unix(int64(syscallStat.Atim.Sec), int64(syscallStat.Atim.Nsec)), nil
+}
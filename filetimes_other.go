@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+func FileCreatedTime(stat os.FileInfo, path string) (time.Time, error) {
+	return time.Time{}, errors.New("Creation time not supported")
+}
+
+func FileAccessedTime(stat os.FileInfo) (time.Time, error) {
+	return time.Time{}, errors.New("Access time not supported")
+}
@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+func FileCreatedTime(stat os.FileInfo, path string) (time.Time, error) {
+	attributes, ok := stat.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, errors.New("Unable to get file attributes")
+	}
+
+	return time.Unix(0, attributes.CreationTime.Nanoseconds()), nil
+}
+
+func FileAccessedTime(stat os.FileInfo) (time.Time, error) {
+	attributes, ok := stat.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, errors.New("Unable to get file attributes")
+	}
+
+	return time.Unix(0, attributes.LastAccessTime.Nanoseconds()), nil
+}
@@ -10,9 +10,14 @@ import (
 	"os"
 	"os/user"
 	"strconv"
+	"sync"
 	"syscall"
 )
 
+// The lookups read /etc/passwd and /etc/group, which is too slow to do for every file in the detailed view on every draw
+var usernameCache sync.Map  // uid -> string
+var groupnameCache sync.Map // gid -> string
+
 func FileUserAndGroupName(stat os.FileInfo) (string, string, error) {
 	syscallStat, ok := stat.Sys().(*syscall.Stat_t)
 	if !ok {
@@ -22,19 +27,25 @@ func FileUserAndGroupName(stat os.FileInfo) (string, string, error) {
 	uid := int(syscallStat.Uid)
 	gid := int(syscallStat.Gid)
 
-	username, usernameErr := user.LookupId(strconv.Itoa(uid))
-	groupname, groupnameErr := user.LookupGroupId(strconv.Itoa(gid))
-
-	usernameStr := ""
-	groupnameStr := ""
-
-	if usernameErr == nil {
-		usernameStr = username.Username
+	usernameStr, usernameCached := usernameCache.Load(uid)
+	if !usernameCached {
+		usernameStr = ""
+		username, usernameErr := user.LookupId(strconv.Itoa(uid))
+		if usernameErr == nil {
+			usernameStr = username.Username
+		}
+		usernameCache.Store(uid, usernameStr)
 	}
 
-	if groupnameErr == nil {
-		groupnameStr = groupname.Name
+	groupnameStr, groupnameCached := groupnameCache.Load(gid)
+	if !groupnameCached {
+		groupnameStr = ""
+		groupname, groupnameErr := user.LookupGroupId(strconv.Itoa(gid))
+		if groupnameErr == nil {
+			groupnameStr = groupname.Name
+		}
+		groupnameCache.Store(gid, groupnameStr)
 	}
 
-	return usernameStr, groupnameStr, nil
+	return usernameStr.(string), groupnameStr.(string), nil
 }
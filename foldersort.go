@@ -0,0 +1,224 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The sort mode and direction can be set per folder in the sort menu, which are remembered in this file in FenDataFolder().
+// Otherwise, a .fen.lua file is used (see localconfig.go), then the first matching entry in fen.folder_sort from config.lua,
+// falling back to fen.sort_by and fen.sort_reverse
+const folderSortFilename = "folder_sort.json"
+
+type FolderSort struct {
+	SortBy      string `json:"sort_by"`
+	SortReverse bool   `json:"sort_reverse"`
+}
+
+// An entry in fen.folder_sort from config.lua
+type FolderSortEntry struct {
+	Match       []string
+	DoNotMatch  []string
+	SortBy      string
+	SortReverse bool
+}
+
+// Patterns containing a path separator are matched against the full path (with a leading ~ being the home folder),
+// otherwise against the folder name
+func FolderMatchesList(folder string, matchList []string) bool {
+	for _, match := range matchList {
+		if !strings.ContainsRune(match, os.PathSeparator) && !strings.ContainsRune(match, '/') {
+			matched, _ := filepath.Match(match, filepath.Base(folder))
+			if matched {
+				return true
+			}
+			continue
+		}
+
+		if match == "~" || strings.HasPrefix(match, "~/") || strings.HasPrefix(match, "~"+string(os.PathSeparator)) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				continue
+			}
+			match = filepath.Join(home, match[1:])
+		}
+
+		matched, _ := filepath.Match(filepath.Clean(filepath.FromSlash(match)), filepath.Clean(folder))
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns an error for the first invalid sort_by value in fen.folder_sort
+func ValidateFolderSortEntries(entries []FolderSortEntry) error {
+	for _, entry := range entries {
+		if entry.SortBy != "" && !slices.Contains(ValidSortByValues[:], entry.SortBy) {
+			return errors.New("Invalid folder_sort sort_by value \"" + entry.SortBy + "\"\nValid values: " + strings.Join(ValidSortByValues[:], ", "))
+		}
+	}
+
+	return nil
+}
+
+// Returns the sort mode and whether it is reversed for folder
+func (fen *Fen) SortForFolder(folder string) (string, bool) {
+	if folderSort, ok := fen.folderSorts[folder]; ok {
+		return folderSort.SortBy, folderSort.SortReverse
+	}
+
+	// Set by a .fen.lua file, see localconfig.go
+	if fen.localConfig != nil && fen.localConfig.Contains(folder) && (fen.localConfig.SortBy != nil || fen.localConfig.SortReverse != nil) {
+		sortBy, sortReverse := fen.config.SortBy, fen.config.SortReverse
+		if fen.localConfig.SortBy != nil {
+			sortBy = *fen.localConfig.SortBy
+		}
+		if fen.localConfig.SortReverse != nil {
+			sortReverse = *fen.localConfig.SortReverse
+		}
+		return sortBy, sortReverse
+	}
+
+	for _, entry := range fen.config.FolderSort {
+		if !FolderMatchesList(folder, entry.Match) || FolderMatchesList(folder, entry.DoNotMatch) {
+			continue
+		}
+
+		sortBy := entry.SortBy
+		if sortBy == "" {
+			sortBy = fen.config.SortBy
+		}
+		return sortBy, entry.SortReverse
+	}
+
+	return fen.config.SortBy, fen.config.SortReverse
+}
+
+// Returns an empty map if the file doesn't exist
+func ReadFolderSortFile(path string) (map[string]FolderSort, error) {
+	folderSorts := make(map[string]FolderSort)
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return folderSorts, nil
+		}
+		return folderSorts, err
+	}
+
+	err = json.Unmarshal(bytes, &folderSorts)
+	if err != nil {
+		return make(map[string]FolderSort), err
+	}
+
+	for folder, folderSort := range folderSorts {
+		if !filepath.IsAbs(folder) || !slices.Contains(ValidSortByValues[:], folderSort.SortBy) {
+			delete(folderSorts, folder)
+		}
+	}
+
+	return folderSorts, nil
+}
+
+func WriteFolderSortFile(path string, folderSorts map[string]FolderSort) error {
+	bytes, err := json.MarshalIndent(folderSorts, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(path, bytes, 0o664)
+}
+
+func folderSortFilePath() (string, error) {
+	dataFolder, err := FenDataFolder()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataFolder, folderSortFilename), nil
+}
+
+// Errors are ignored, no folders will have a remembered sort mode
+func (fen *Fen) LoadFolderSorts() {
+	fen.folderSorts = make(map[string]FolderSort)
+
+	path, err := folderSortFilePath()
+	if err != nil {
+		return
+	}
+
+	folderSorts, err := ReadFolderSortFile(path)
+	if err != nil {
+		return
+	}
+
+	fen.folderSorts = folderSorts
+}
+
+// Remembers the sort mode for folder, or forgets it if folderSort is nil.
+// The file is re-read first so changes from other fen instances are kept. In no-write mode, it is only remembered for this session
+func (fen *Fen) SetFolderSort(folder string, folderSort *FolderSort) error {
+	change := func(folderSorts map[string]FolderSort) {
+		if folderSort == nil {
+			delete(folderSorts, folder)
+		} else {
+			folderSorts[folder] = *folderSort
+		}
+	}
+
+	if folderSort != nil && !slices.Contains(ValidSortByValues[:], folderSort.SortBy) {
+		return errors.New("Invalid sort_by value \"" + folderSort.SortBy + "\"")
+	}
+
+	if fen.config.NoWrite {
+		change(fen.folderSorts)
+		return nil
+	}
+
+	path, err := folderSortFilePath()
+	if err != nil {
+		change(fen.folderSorts)
+		return err
+	}
+
+	unlock, err := LockFile(path)
+	if err != nil {
+		change(fen.folderSorts)
+		return err
+	}
+	defer unlock()
+
+	folderSorts, err := ReadFolderSortFile(path)
+	if err != nil {
+		// Don't overwrite a file we can't understand
+		change(fen.folderSorts)
+		return errors.New("Unable to read folder sort file: " + err.Error())
+	}
+
+	change(folderSorts)
+	fen.folderSorts = folderSorts
+	return WriteFolderSortFile(path, folderSorts)
+}
+
+// Re-sorts the filespanes sorted by git status, call it when a git status finishes
+func (fen *Fen) ResortGitStatusSortedPanes() {
+	resorted := false
+	for _, fp := range []*FilesPane{fen.leftPane, fen.middlePane, fen.rightPane} {
+		if sortBy, _ := fen.SortForFolder(fp.folder); sortBy == SORT_GIT_STATUS {
+			fp.FilterAndSortEntries()
+			resorted = true
+		}
+	}
+
+	if resorted {
+		fen.UpdatePanes(false)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kivattt/gogitstatus"
+	"github.com/rivo/tview"
+)
+
+// With fen.folder_summary enabled, the selected folder gets a summary above its files in the rightPane:
+// the size of everything in it, how many files of each type it has (the categories from FileColor()), its newest and oldest file
+// and the Git branch if it's a repository. Summaries are made in the background like file previews, since large folders take a while to go through.
+// They are kept until fen.InvalidateFolderFileCountCache(), after which they are still shown while being remade
+
+const maxCachedFolderSummaries = 32
+
+// Folders with more files and folders than this are only partly summarized
+const maxFolderSummaryEntries = 1_000_000
+
+// Used like "3 code files", folders are counted separately
+var fileCategoryNouns = map[FileCategory]string{
+	FILE_CATEGORY_EXECUTABLE:        "executable",
+	FILE_CATEGORY_SYMLINK_TO_FOLDER: "folder symlink",
+	FILE_CATEGORY_SYMLINK:           "symlink",
+	FILE_CATEGORY_IMAGE:             "image",
+	FILE_CATEGORY_VIDEO:             "video",
+	FILE_CATEGORY_ARCHIVE:           "archive",
+	FILE_CATEGORY_CODE:              "code file",
+	FILE_CATEGORY_AUDIO:             "audio file",
+	FILE_CATEGORY_DOCUMENT:          "document",
+	FILE_CATEGORY_OTHER:             "other file",
+	FILE_CATEGORY_SPECIAL:           "special file",
+}
+
+type FolderSummary struct {
+	Size       int64 // Symlinks are not followed
+	Files      int
+	Folders    int
+	Categories map[FileCategory]int // Files by category
+	Newest     string               // Relative to the folder
+	NewestTime time.Time
+	Oldest     string
+	OldestTime time.Time
+	Unreadable int  // Folders inside it which couldn't be read
+	Truncated  bool // Stopped after maxFolderSummaryEntries
+
+	GitBranch  string // Empty if the folder is not a Git repository
+	GitChanged int    // Unstaged and untracked files, -1 if unknown
+}
+
+// Goes through everything in path, returns an error if path itself couldn't be read
+func FolderSummaryOf(ctx context.Context, path string, hiddenFiles bool) (FolderSummary, error) {
+	summary := FolderSummary{Categories: make(map[FileCategory]int), GitChanged: -1}
+
+	entries := 0
+	err := filepath.WalkDir(path, func(entryPath string, entry fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			if entryPath == path {
+				return err
+			}
+			summary.Unreadable++
+			return nil
+		}
+
+		if entryPath == path {
+			return nil
+		}
+
+		if !hiddenFiles && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		entries++
+		if entries > maxFolderSummaryEntries {
+			summary.Truncated = true
+			return filepath.SkipAll
+		}
+
+		if entry.IsDir() {
+			summary.Folders++
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		summary.Files++
+		summary.Size += info.Size()
+		summary.Categories[FileCategoryOf(info, entryPath)]++
+
+		relativePath, err := filepath.Rel(path, entryPath)
+		if err != nil {
+			relativePath = entry.Name()
+		}
+		if summary.Newest == "" || info.ModTime().After(summary.NewestTime) {
+			summary.Newest = relativePath
+			summary.NewestTime = info.ModTime()
+		}
+		if summary.Oldest == "" || info.ModTime().Before(summary.OldestTime) {
+			summary.Oldest = relativePath
+			summary.OldestTime = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return FolderSummary{}, err
+	}
+
+	if stat, err := os.Stat(filepath.Join(path, ".git")); err == nil && stat.IsDir() {
+		summary.GitBranch = GitBranch(path)
+		changedFiles, err := gogitstatus.StatusWithContext(ctx, path)
+		if err == nil {
+			summary.GitChanged = len(changedFiles)
+		}
+	}
+
+	return summary, ctx.Err()
+}
+
+// Returns the checked out branch of the Git repository at repositoryPath, or the start of the commit hash when no branch is checked out
+func GitBranch(repositoryPath string) string {
+	head, err := os.ReadFile(filepath.Join(repositoryPath, ".git", "HEAD"))
+	if err != nil {
+		return "unknown branch"
+	}
+
+	text := strings.TrimSpace(string(head))
+	if branch, ok := strings.CutPrefix(text, "ref: refs/heads/"); ok {
+		return branch
+	}
+	return text[:min(len(text), 7)] + " (detached)"
+}
+
+// The lines shown above the files of the folder
+func FolderSummaryLines(summary FolderSummary, now time.Time) []StyledLine {
+	size := BytesToHumanReadableUnitString(uint64(summary.Size), 2) + " in " + CountText(summary.Files, "file") + ", " + CountText(summary.Folders, "folder")
+	if summary.Truncated {
+		size = "At least " + size
+	}
+	details := [][2]string{{"Size", size}}
+
+	// The most common types first
+	var categories []FileCategory
+	for category := range summary.Categories {
+		categories = append(categories, category)
+	}
+	slices.SortFunc(categories, func(a, b FileCategory) int {
+		if summary.Categories[a] != summary.Categories[b] {
+			return summary.Categories[b] - summary.Categories[a]
+		}
+		return int(a) - int(b)
+	})
+
+	var types []string
+	for _, category := range categories {
+		types = append(types, CountText(summary.Categories[category], fileCategoryNouns[category]))
+	}
+	if len(types) > 0 {
+		details = append(details, [2]string{"Types", strings.Join(types, ", ")})
+	}
+
+	if summary.Newest != "" && summary.Newest != summary.Oldest {
+		details = append(details, [2]string{"Newest", summary.Newest + ", " + RelativeTimeString(summary.NewestTime, now)})
+		details = append(details, [2]string{"Oldest", summary.Oldest + ", " + RelativeTimeString(summary.OldestTime, now)})
+	}
+
+	if summary.Unreadable > 0 {
+		details = append(details, [2]string{"Unreadable", CountText(summary.Unreadable, "folder")})
+	}
+
+	if summary.GitBranch != "" {
+		git := summary.GitBranch
+		if summary.GitChanged == 0 {
+			git += ", no changes"
+		} else if summary.GitChanged > 0 {
+			git += ", " + CountText(summary.GitChanged, "changed file")
+		}
+		details = append(details, [2]string{"Git", git})
+	}
+
+	lines := DetailLines(details, "")
+
+	// Each type in the color of its files
+	if len(types) > 0 {
+		line := &lines[1]
+		start := len(line.Text) - len(details[1][1])
+		for i, category := range categories {
+			line.Spans = append(line.Spans, StyledSpan{Start: start, End: start + len(types[i]), Style: FileCategoryStyle(category)})
+			start += len(types[i]) + len(", ")
+		}
+	}
+
+	return lines
+}
+
+type folderSummaryKey struct {
+	path        string
+	hiddenFiles bool
+}
+
+type folderSummaryCacheEntry struct {
+	key     folderSummaryKey
+	summary *FolderSummary // Nil if the folder couldn't be read
+	stale   bool           // Invalidated, but shown until the new summary is made
+}
+
+type runningFolderSummary struct {
+	cancel context.CancelFunc
+}
+
+type FolderSummaryWorker struct {
+	mutex   sync.Mutex
+	cache   []folderSummaryCacheEntry // Least recently used first
+	running map[folderSummaryKey]*runningFolderSummary
+	onDone  func() // Called from the job goroutine when a summary is finished
+}
+
+func NewFolderSummaryWorker(onDone func()) *FolderSummaryWorker {
+	return &FolderSummaryWorker{
+		running: make(map[folderSummaryKey]*runningFolderSummary),
+		onDone:  onDone,
+	}
+}
+
+// Starts making the summary of path if it isn't cached or is stale, and cancels the summaries of other folders.
+// Returns the cached summary, or nil and true while it's loading. Returns nil and false if the folder couldn't be read
+func (worker *FolderSummaryWorker) Request(path string, hiddenFiles bool) (*FolderSummary, bool) {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	key := folderSummaryKey{path: path, hiddenFiles: hiddenFiles}
+	for runningKey, running := range worker.running {
+		if runningKey != key {
+			running.cancel()
+			delete(worker.running, runningKey)
+		}
+	}
+
+	index := slices.IndexFunc(worker.cache, func(entry folderSummaryCacheEntry) bool { return entry.key == key })
+	if index == -1 {
+		worker.start(key)
+		return nil, true
+	}
+
+	// Marks it as recently used
+	entry := worker.cache[index]
+	worker.cache = append(slices.Delete(worker.cache, index, index+1), entry)
+
+	if entry.stale {
+		worker.start(key)
+	}
+	return entry.summary, false
+}
+
+// Marks every summary as stale, so they are made again when requested
+func (worker *FolderSummaryWorker) Invalidate() {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	for i := range worker.cache {
+		worker.cache[i].stale = true
+	}
+}
+
+// Cancels all running jobs
+func (worker *FolderSummaryWorker) CancelAll() {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	for key, running := range worker.running {
+		running.cancel()
+		delete(worker.running, key)
+	}
+}
+
+// Has to be called with worker.mutex locked, does nothing if it's already running
+func (worker *FolderSummaryWorker) start(key folderSummaryKey) {
+	if _, ok := worker.running[key]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &runningFolderSummary{cancel: cancel}
+	worker.running[key] = running
+
+	go func() {
+		defer cancel()
+
+		var summaryPointer *FolderSummary
+		summary, err := FolderSummaryOf(ctx, key.path, key.hiddenFiles)
+		if err == nil {
+			summaryPointer = &summary
+		}
+
+		worker.mutex.Lock()
+		// Cancelled, or replaced by a newer job
+		if ctx.Err() != nil || worker.running[key] != running {
+			worker.mutex.Unlock()
+			return
+		}
+		delete(worker.running, key)
+
+		worker.cache = slices.DeleteFunc(worker.cache, func(entry folderSummaryCacheEntry) bool { return entry.key == key })
+		worker.cache = append(worker.cache, folderSummaryCacheEntry{key: key, summary: summaryPointer})
+		if len(worker.cache) > maxCachedFolderSummaries {
+			worker.cache = slices.Delete(worker.cache, 0, len(worker.cache)-maxCachedFolderSummaries)
+		}
+		worker.mutex.Unlock()
+
+		if worker.onDone != nil {
+			worker.onDone()
+		}
+	}()
+}
+
+// Draws the summary of the selected folder at the top of the rightPane, returns how many rows it used
+func (fp *FilesPane) drawFolderSummary(screen tcell.Screen, x, y, w, h int) int {
+	summary, loading := fp.fen.folderSummaries.Request(fp.folder, fp.fen.config.HiddenFiles)
+	if loading {
+		tview.Print(screen, "[::d]summarizing…", x, y, w, tview.AlignLeft, tcell.ColorDefault)
+		return min(h, 2)
+	}
+	if summary == nil {
+		return 0
+	}
+
+	lines := FolderSummaryLines(*summary, time.Now())
+
+	// Leaves room for at least half of the files, and an empty line below the summary
+	lines = lines[:min(len(lines), max(0, h/2-1))]
+	for i, line := range lines {
+		line.Draw(screen, x+1, y+i, w-2, 0)
+	}
+	if len(lines) == 0 {
+		return 0
+	}
+	return len(lines) + 1
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFolderSummaryOf(t *testing.T) {
+	folder := t.TempDir()
+	now := time.Now()
+
+	files := map[string]time.Duration{
+		"main.go":              time.Hour,
+		"README.md":            24 * time.Hour,
+		"images/photo.png":     2 * time.Hour,
+		"images/old/photo.jpg": 365 * 24 * time.Hour,
+		".hidden/secret.txt":   time.Minute,
+		".env":                 time.Minute,
+	}
+	for name, age := range files {
+		path := filepath.Join(folder, name)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte("hello"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(path, now.Add(-age), now.Add(-age))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := FolderSummaryOf(context.Background(), folder, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Files != 4 || summary.Folders != 2 || summary.Size != 20 {
+		t.Fatal("Expected 4 files, 2 folders and 20 bytes without hidden files")
+	}
+	if summary.Categories[FILE_CATEGORY_IMAGE] != 2 || summary.Categories[FILE_CATEGORY_CODE] != 1 || summary.Categories[FILE_CATEGORY_DOCUMENT] != 1 {
+		t.Fatal("Expected 2 images, 1 code file and 1 document")
+	}
+	if summary.Newest != "main.go" || summary.Oldest != filepath.Join("images", "old", "photo.jpg") {
+		t.Fatal("Expected main.go to be the newest and images/old/photo.jpg the oldest, but got " + summary.Newest + " and " + summary.Oldest)
+	}
+	if summary.GitBranch != "" {
+		t.Fatal("Expected no Git branch outside of a Git repository")
+	}
+
+	summary, err = FolderSummaryOf(context.Background(), folder, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Files != 6 || summary.Folders != 3 || summary.Newest == "main.go" {
+		t.Fatal("Expected the hidden files to be included")
+	}
+
+	_, err = FolderSummaryOf(context.Background(), filepath.Join(folder, "does-not-exist"), false)
+	if err == nil {
+		t.Fatal("Expected an error for a folder that doesn't exist")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = FolderSummaryOf(ctx, folder, false)
+	if err == nil {
+		t.Fatal("Expected an error when cancelled")
+	}
+}
+
+func TestGitBranch(t *testing.T) {
+	tests := map[string]string{
+		"ref: refs/heads/main\n":                     "main",
+		"ref: refs/heads/feature/summary\n":          "feature/summary",
+		"4f2a9c1e8b7d6a5f4e3d2c1b0a9f8e7d6c5b4a3f\n": "4f2a9c1 (detached)",
+	}
+
+	for head, expected := range tests {
+		repository := t.TempDir()
+		err := os.Mkdir(filepath.Join(repository, ".git"), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(repository, ".git", "HEAD"), []byte(head), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := GitBranch(repository); got != expected {
+			t.Fatal("Expected \"" + expected + "\" but got \"" + got + "\"")
+		}
+	}
+}
+
+func TestFolderSummaryLines(t *testing.T) {
+	now := time.Now()
+	summary := FolderSummary{
+		Size:       2500,
+		Files:      3,
+		Folders:    1,
+		Categories: map[FileCategory]int{FILE_CATEGORY_CODE: 1, FILE_CATEGORY_IMAGE: 2},
+		Newest:     "main.go",
+		NewestTime: now.Add(-5 * time.Minute),
+		Oldest:     "old.png",
+		OldestTime: now.Add(-48 * time.Hour),
+		GitBranch:  "main",
+		GitChanged: 2,
+	}
+
+	expected := []string{
+		"Size    2.5 kB in 3 files, 1 folder",
+		"Types   2 images, 1 code file",
+		"Newest  main.go, 5 min ago",
+		"Oldest  old.png, 2 d ago",
+		"Git     main, 2 changed files",
+	}
+
+	lines := FolderSummaryLines(summary, now)
+	var got []string
+	for _, line := range lines {
+		got = append(got, line.Text)
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatal("Expected:\n" + strings.Join(expected, "\n") + "\nbut got:\n" + strings.Join(got, "\n"))
+	}
+
+	// The label and each type are styled
+	if len(lines[1].Spans) != 3 || lines[1].Text[lines[1].Spans[1].Start:lines[1].Spans[1].End] != "2 images" || lines[1].Text[lines[1].Spans[2].Start:lines[1].Spans[2].End] != "1 code file" {
+		t.Fatal("Expected the types to be styled by their file category")
+	}
+}
+
+func TestFolderSummaryWorker(t *testing.T) {
+	folder := t.TempDir()
+	err := os.WriteFile(filepath.Join(folder, "file.txt"), []byte("hello"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool, 10)
+	worker := NewFolderSummaryWorker(func() { done <- true })
+
+	summary, loading := worker.Request(folder, false)
+	if summary != nil || !loading {
+		t.Fatal("Expected the summary to be loading")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the summary")
+	}
+
+	summary, loading = worker.Request(folder, false)
+	if summary == nil || loading || summary.Files != 1 {
+		t.Fatal("Expected the cached summary with 1 file")
+	}
+
+	// A stale summary is still shown while it's remade
+	err = os.WriteFile(filepath.Join(folder, "file2.txt"), []byte("hello"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker.Invalidate()
+	summary, loading = worker.Request(folder, false)
+	if summary == nil || loading || summary.Files != 1 {
+		t.Fatal("Expected the stale summary while it's remade")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the new summary")
+	}
+
+	summary, _ = worker.Request(folder, false)
+	if summary == nil || summary.Files != 2 {
+		t.Fatal("Expected the new summary with 2 files")
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Every folder entered is recorded in the frecency database, which is used for jumping to folders by typing parts of their path.
+// The scoring and aging works like zoxide, see https://github.com/ajeetdsouza/zoxide/wiki/Algorithm
+const frecencyFilename = "frecency.txt"
+
+// When the sum of all ranks exceeds this, all ranks are scaled down and low ranking folders are forgotten
+const frecencyMaxAge = 10000
+
+type FrecencyEntry struct {
+	Path       string
+	Rank       float64
+	LastAccess int64 // Unix time in seconds
+}
+
+type frecencyVisit struct {
+	path string
+	time int64
+}
+
+type FrecencyDatabase struct {
+	mutex   sync.Mutex
+	entries map[string]*FrecencyEntry
+
+	// Visits not yet written to disk, applied to the database file on fen.SaveFrecency().
+	// This way we don't overwrite visits recorded by other fen instances running at the same time
+	unsavedVisits []frecencyVisit
+}
+
+func NewFrecencyDatabase() *FrecencyDatabase {
+	return &FrecencyDatabase{entries: make(map[string]*FrecencyEntry)}
+}
+
+// Each line is "rank<TAB>last access<TAB>path", invalid lines are ignored.
+// Returns an empty database if the file doesn't exist
+func ReadFrecencyFile(path string) (*FrecencyDatabase, error) {
+	db := NewFrecencyDatabase()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return db, nil
+		}
+		return db, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		rank, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || rank <= 0 {
+			continue
+		}
+
+		lastAccess, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if !filepath.IsAbs(fields[2]) {
+			continue
+		}
+
+		db.entries[fields[2]] = &FrecencyEntry{Path: fields[2], Rank: rank, LastAccess: lastAccess}
+	}
+
+	return db, scanner.Err()
+}
+
+func (db *FrecencyDatabase) WriteFile(path string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var buffer bytes.Buffer
+	for _, entry := range db.entries {
+		buffer.WriteString(strconv.FormatFloat(entry.Rank, 'f', -1, 64) + "\t" + strconv.FormatInt(entry.LastAccess, 10) + "\t" + entry.Path + "\n")
+	}
+
+	return WriteFileAtomic(path, buffer.Bytes(), 0o664)
+}
+
+func (db *FrecencyDatabase) addVisit(path string, unixTime int64) {
+	entry, ok := db.entries[path]
+	if !ok {
+		entry = &FrecencyEntry{Path: path}
+		db.entries[path] = entry
+	}
+
+	entry.Rank++
+	entry.LastAccess = max(entry.LastAccess, unixTime)
+	db.age()
+}
+
+// Paths containing newlines or tabs are ignored, since they would break the database file
+func (db *FrecencyDatabase) AddVisit(path string, when time.Time) {
+	if strings.ContainsAny(path, "\n\r\t") || !filepath.IsAbs(path) {
+		return
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.addVisit(path, when.Unix())
+	db.unsavedVisits = append(db.unsavedVisits, frecencyVisit{path: path, time: when.Unix()})
+}
+
+// Adds rank to the folder, used when importing from other programs
+func (db *FrecencyDatabase) AddRank(path string, rank float64, lastAccess int64) {
+	if strings.ContainsAny(path, "\n\r\t") || !filepath.IsAbs(path) || rank <= 0 {
+		return
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	entry, ok := db.entries[path]
+	if !ok {
+		entry = &FrecencyEntry{Path: path}
+		db.entries[path] = entry
+	}
+
+	entry.Rank += rank
+	entry.LastAccess = max(entry.LastAccess, lastAccess)
+	db.age()
+}
+
+func (db *FrecencyDatabase) age() {
+	sum := 0.0
+	for _, entry := range db.entries {
+		sum += entry.Rank
+	}
+
+	if sum <= frecencyMaxAge {
+		return
+	}
+
+	factor := 0.9 * frecencyMaxAge / sum
+	for path, entry := range db.entries {
+		entry.Rank *= factor
+		if entry.Rank < 1 {
+			delete(db.entries, path)
+		}
+	}
+}
+
+func FrecencyScore(entry FrecencyEntry, now int64) float64 {
+	age := now - entry.LastAccess
+	switch {
+	case age < 60*60:
+		return entry.Rank * 4
+	case age < 60*60*24:
+		return entry.Rank * 2
+	case age < 60*60*24*7:
+		return entry.Rank / 2
+	default:
+		return entry.Rank / 4
+	}
+}
+
+const (
+	frecencyNoMatch = iota
+	frecencyFuzzyMatch
+	frecencyKeywordsMatch
+)
+
+// Like zoxide, the query is split into keywords which have to appear in the path in order, and the last keyword has to be in the last folder name.
+// Keywords are case-insensitive unless they contain an uppercase letter.
+// If the keywords don't match, it is a fuzzy match if the letters of the query (without spaces) appear in order with the last one in the last folder name
+func FrecencyMatch(path, query string) int {
+	keywords := strings.Fields(query)
+	if len(keywords) == 0 {
+		return frecencyKeywordsMatch
+	}
+
+	matchCase := func(keyword, text string) string {
+		if strings.IndexFunc(keyword, unicode.IsUpper) == -1 {
+			return strings.ToLower(text)
+		}
+		return text
+	}
+
+	lastSeparator := strings.LastIndexByte(path, os.PathSeparator)
+
+	// Keywords
+	index := 0
+	matched := true
+	for i, keyword := range keywords {
+		// Lowercasing can change the length of unusual text, so index might be out of bounds
+		text := matchCase(keyword, path)
+		found := strings.Index(text[min(index, len(text)):], keyword)
+		if found == -1 {
+			matched = false
+			break
+		}
+
+		index += found + len(keyword)
+		if i == len(keywords)-1 && index <= lastSeparator {
+			// The last keyword wasn't in the last folder name, try to find it again there
+			if !strings.Contains(matchCase(keyword, path[lastSeparator+1:]), keyword) {
+				matched = false
+			}
+		}
+	}
+
+	if matched {
+		return frecencyKeywordsMatch
+	}
+
+	// Fuzzy
+	letters := []rune(strings.Join(keywords, ""))
+	pathRunes := []rune(matchCase(string(letters), path))
+	lastSeparatorRunes := len([]rune(path[:max(0, lastSeparator)]))
+
+	// Match the last letter as far right as possible, the rest in order before it
+	letterIndex := len(letters) - 1
+	for i := len(pathRunes) - 1; i >= 0 && letterIndex >= 0; i-- {
+		if pathRunes[i] != letters[letterIndex] {
+			continue
+		}
+
+		if letterIndex == len(letters)-1 && i <= lastSeparatorRunes {
+			return frecencyNoMatch
+		}
+
+		letterIndex--
+	}
+
+	if letterIndex < 0 {
+		return frecencyFuzzyMatch
+	}
+
+	return frecencyNoMatch
+}
+
+// Returns up to maxResults existing folders matching query, best first. Folders in excludePaths are skipped.
+// Keyword matches always come before fuzzy matches, then they are sorted by frecency score
+func (db *FrecencyDatabase) Query(query string, maxResults int, excludePaths ...string) []string {
+	type scoredPath struct {
+		path  string
+		match int
+		score float64
+	}
+
+	now := time.Now().Unix()
+
+	db.mutex.Lock()
+	var candidates []scoredPath
+	for path, entry := range db.entries {
+		if slices.Contains(excludePaths, path) {
+			continue
+		}
+
+		match := FrecencyMatch(path, query)
+		if match == frecencyNoMatch {
+			continue
+		}
+
+		candidates = append(candidates, scoredPath{path: path, match: match, score: FrecencyScore(*entry, now)})
+	}
+	db.mutex.Unlock()
+
+	slices.SortFunc(candidates, func(a, b scoredPath) int {
+		if a.match != b.match {
+			return b.match - a.match
+		}
+
+		if a.score > b.score {
+			return -1
+		} else if a.score < b.score {
+			return 1
+		}
+
+		return strings.Compare(a.path, b.path)
+	})
+
+	var results []string
+	for _, candidate := range candidates {
+		if len(results) >= maxResults {
+			break
+		}
+
+		stat, err := os.Stat(candidate.path)
+		if err != nil || !stat.IsDir() {
+			continue
+		}
+
+		results = append(results, candidate.path)
+	}
+
+	return results
+}
+
+func frecencyFilePath() (string, error) {
+	dataFolder, err := FenDataFolder()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataFolder, frecencyFilename), nil
+}
+
+// Errors are ignored, the database will simply be empty
+func (fen *Fen) LoadFrecency() {
+	fen.frecency = NewFrecencyDatabase()
+
+	path, err := frecencyFilePath()
+	if err != nil {
+		return
+	}
+
+	db, err := ReadFrecencyFile(path)
+	if err != nil {
+		return
+	}
+
+	fen.frecency = db
+}
+
+// Applies the visits since startup to the database file on disk, so visits recorded by other fen instances are kept
+func (fen *Fen) SaveFrecency() error {
+	if fen.config.NoWrite {
+		return nil
+	}
+
+	fen.frecency.mutex.Lock()
+	unsavedVisits := fen.frecency.unsavedVisits
+	fen.frecency.unsavedVisits = nil
+	fen.frecency.mutex.Unlock()
+
+	if len(unsavedVisits) == 0 {
+		return nil
+	}
+
+	path, err := frecencyFilePath()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := ReadFrecencyFile(path)
+	if err != nil {
+		return errors.New("Unable to read frecency database: " + err.Error())
+	}
+
+	for _, visit := range unsavedVisits {
+		db.addVisit(visit.path, visit.time)
+	}
+
+	return db.WriteFile(path)
+}
+
+// Goes to the best matching folder in the frecency database, other than the current folder
+func (fen *Fen) GoFrecencyJump(query string) (string, error) {
+	results := fen.frecency.Query(query, 1, fen.wd)
+	if len(results) == 0 {
+		return "", errors.New("No folder found matching \"" + query + "\"")
+	}
+
+	return fen.GoPath(results[0])
+}
+
+var ValidFrecencyImportValues = [...]string{"zoxide", "autojump"}
+
+// Adds the folders from a zoxide or autojump database into fen's frecency database file, returns the amount of folders imported
+func ImportFrecency(program string) (int, error) {
+	path, err := frecencyFilePath()
+	if err != nil {
+		return 0, err
+	}
+
+	unlock, err := LockFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	db, err := ReadFrecencyFile(path)
+	if err != nil {
+		return 0, errors.New("Unable to read frecency database: " + err.Error())
+	}
+
+	var output []byte
+	switch program {
+	case "zoxide":
+		// Each line is the score, padded with spaces, followed by the path
+		output, err = exec.Command("zoxide", "query", "--list", "--score").Output()
+		if err != nil {
+			return 0, errors.New("Unable to run \"zoxide query --list --score\": " + err.Error())
+		}
+	case "autojump":
+		// Each line is the weight, a tab, then the path
+		autojumpPath, err := autojumpDatabasePath()
+		if err != nil {
+			return 0, err
+		}
+
+		output, err = os.ReadFile(autojumpPath)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errors.New("Invalid program to import from \"" + program + "\", valid values: " + strings.Join(ValidFrecencyImportValues[:], ", "))
+	}
+
+	now := time.Now().Unix()
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimLeft(line, " ")
+		separatorIndex := strings.IndexAny(line, " \t")
+		if separatorIndex == -1 {
+			continue
+		}
+
+		rank, err := strconv.ParseFloat(line[:separatorIndex], 64)
+		if err != nil {
+			continue
+		}
+
+		folder := strings.TrimRight(line[separatorIndex+1:], "\r")
+		if !filepath.IsAbs(folder) {
+			continue
+		}
+
+		db.AddRank(folder, rank, now)
+		count++
+	}
+
+	return count, db.WriteFile(path)
+}
+
+func autojumpDatabasePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "autojump", "autojump.txt"), nil
+	} else if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "autojump", "autojump.txt"), nil
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dataHome, "autojump", "autojump.txt"), nil
+}
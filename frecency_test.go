@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFrecencyMatch(t *testing.T) {
+	type pathAndQuery struct {
+		path  string
+		query string
+	}
+
+	expectedResults := map[pathAndQuery]int{
+		{"/home/user/projects/fen", ""}:              frecencyKeywordsMatch,
+		{"/home/user/projects/fen", "fen"}:           frecencyKeywordsMatch,
+		{"/home/user/projects/fen", "proj fen"}:      frecencyKeywordsMatch,
+		{"/home/user/projects/fen", "FEN"}:           frecencyNoMatch,
+		{"/home/user/projects/Fen", "Fen"}:           frecencyKeywordsMatch,
+		{"/home/user/projects/Fen", "fen"}:           frecencyKeywordsMatch,
+		{"/home/user/projects/fen", "fen proj"}:      frecencyNoMatch,
+		{"/home/user/projects/fen", "proj"}:          frecencyNoMatch,
+		{"/home/user/projects/fen", "pfn"}:           frecencyFuzzyMatch,
+		{"/home/user/projects/fen", "pjf"}:           frecencyFuzzyMatch,
+		{"/home/user/projects/fen", "prjs"}:          frecencyNoMatch,
+		{"/home/user/projects/fen", "xyz"}:           frecencyNoMatch,
+		{"/home/user/fen/projects", "fen projects"}:  frecencyKeywordsMatch,
+		{"/home/user/fen/projects/fen", "fen fen"}:   frecencyKeywordsMatch,
+		{"/home/user/fen/projects/fen", "fen   fen"}: frecencyKeywordsMatch,
+	}
+
+	for input, expected := range expectedResults {
+		got := FrecencyMatch(input.path, input.query)
+		if got != expected {
+			t.Fatal("Expected " + strconv.Itoa(expected) + " for path " + input.path + " and query \"" + input.query + "\", but got " + strconv.Itoa(got))
+		}
+	}
+}
+
+func TestFrecencyAging(t *testing.T) {
+	db := NewFrecencyDatabase()
+	db.AddRank("/old", 1, 0)
+	db.AddRank("/frequent", frecencyMaxAge, 0)
+
+	if len(db.entries) != 1 {
+		t.Fatal("Expected /old to be forgotten after the sum of ranks exceeded the max age")
+	}
+
+	if db.entries["/frequent"].Rank > frecencyMaxAge {
+		t.Fatal("Expected the rank of /frequent to be scaled down")
+	}
+
+	now := time.Now()
+	db.AddVisit("/recent", now)
+	if FrecencyScore(*db.entries["/recent"], now.Unix()) != 4 {
+		t.Fatal("Expected a folder visited once just now to have a score of 4")
+	}
+}
@@ -104,6 +104,33 @@ func (gsh *GitStatusHandler) PathIsUnstagedOrUntracked(path, repositoryPath stri
 	return pathUnstagedOrUntracked
 }
 
+// Returns "?" for an untracked path, "M" for an unstaged path, or an empty string otherwise.
+// Used for the git column in the detailed view
+func (gsh *GitStatusHandler) PathGitStatusText(path, repositoryPath string) string {
+	gsh.trackedLocalGitReposMutex.Lock()
+	defer gsh.trackedLocalGitReposMutex.Unlock()
+
+	repo, repoOk := gsh.trackedLocalGitRepos[repositoryPath]
+	if !repoOk {
+		return ""
+	}
+
+	relativePathToRepo, err := filepath.Rel(repositoryPath, path)
+	if err != nil {
+		return ""
+	}
+
+	changedFile, ok := repo.changedFiles[relativePathToRepo]
+	if !ok {
+		return ""
+	}
+
+	if changedFile.Untracked {
+		return "?"
+	}
+	return "M"
+}
+
 func (gsh *GitStatusHandler) Init() {
 	if gsh.app == nil {
 		panic("In GitStatusHandler Init(), app was nil")
@@ -239,7 +266,9 @@ func (gsh *GitStatusHandler) Init() {
 				gsh.trackedLocalGitReposMutex.Unlock()
 
 				gsh.fen.runningGitStatus = false // Can't defer this because it has to run before QueueUpdateDraw()
-				gsh.app.QueueUpdateDraw(func() {})
+				gsh.app.QueueUpdateDraw(func() {
+					gsh.fen.ResortGitStatusSortedPanes()
+				})
 			}()
 		}
 		gsh.wg.Done()
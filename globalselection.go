@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// When fen.config.GlobalSelection is enabled, the selected and yanked files are stored in this file in FenCacheFolder(),
+// and changes made by other fen instances are picked up with a file watcher.
+// This way you can yank files in one fen instance and paste them in another
+const globalSelectionFilename = "selection.json"
+
+type GlobalSelection struct {
+	Selected []string `json:"selected"`
+	Yanked   []string `json:"yanked"`
+	YankType string   `json:"yank_type"` // "", "copy", "cut"
+}
+
+func (a GlobalSelection) Equal(b GlobalSelection) bool {
+	return a.YankType == b.YankType && slices.Equal(a.Selected, b.Selected) && slices.Equal(a.Yanked, b.Yanked)
+}
+
+// Sorted so it can be compared with GlobalSelection.Equal()
+func globalSelectionFromFen(fen *Fen) GlobalSelection {
+	selected := MapStringBoolKeys(fen.selected)
+	yanked := MapStringBoolKeys(fen.yankSelected)
+	slices.Sort(selected)
+	slices.Sort(yanked)
+
+	return GlobalSelection{Selected: selected, Yanked: yanked, YankType: fen.yankType}
+}
+
+// Paths that no longer exist are left out, returns an empty selection if the file doesn't exist
+func ReadGlobalSelectionFile(path string) (GlobalSelection, error) {
+	var globalSelection GlobalSelection
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return globalSelection, nil
+		}
+		return globalSelection, err
+	}
+
+	err = json.Unmarshal(bytes, &globalSelection)
+	if err != nil {
+		return GlobalSelection{}, err
+	}
+
+	existing := func(paths []string) []string {
+		var result []string
+		for _, path := range paths {
+			if !filepath.IsAbs(path) {
+				continue
+			}
+
+			_, err := os.Lstat(path)
+			if err == nil {
+				result = append(result, path)
+			}
+		}
+
+		slices.Sort(result)
+		return slices.Compact(result)
+	}
+
+	globalSelection.Selected = existing(globalSelection.Selected)
+	globalSelection.Yanked = existing(globalSelection.Yanked)
+	if globalSelection.YankType != "copy" && globalSelection.YankType != "cut" {
+		globalSelection.YankType = ""
+		globalSelection.Yanked = nil
+	}
+
+	return globalSelection, nil
+}
+
+func WriteGlobalSelectionFile(path string, globalSelection GlobalSelection) error {
+	bytes, err := json.MarshalIndent(globalSelection, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(path, bytes, 0o664)
+}
+
+type GlobalSelectionHandler struct {
+	fen     *Fen
+	path    string
+	watcher *fsnotify.Watcher
+
+	last GlobalSelection // What was last written or read, only used in the main goroutine
+}
+
+// Loads the global selection into fen, adding what is already selected (from the --select flag), and starts watching for changes
+func (handler *GlobalSelectionHandler) Init(fen *Fen) error {
+	handler.fen = fen
+
+	cacheFolder, err := FenCacheFolder()
+	if err != nil {
+		return err
+	}
+	handler.path = filepath.Join(cacheFolder, globalSelectionFilename)
+
+	globalSelection, err := ReadGlobalSelectionFile(handler.path)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range globalSelection.Selected {
+		fen.selected[path] = true
+	}
+	handler.applyYanked(globalSelection)
+	handler.last = globalSelection
+	handler.Sync()
+
+	handler.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// The file is replaced by renaming a temporary file, so we have to watch the folder.
+	// With fen.no_write it isn't created, then the selection is only local to this fen instance
+	if !fen.config.NoWrite {
+		os.MkdirAll(cacheFolder, 0o775)
+	}
+	err = handler.watcher.Add(cacheFolder)
+	if err != nil {
+		handler.watcher.Close()
+		handler.watcher = nil
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-handler.watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(event.Name) != handler.path || !event.Op.Has(fsnotify.Create) && !event.Op.Has(fsnotify.Write) {
+					continue
+				}
+
+				globalSelection, err := ReadGlobalSelectionFile(handler.path)
+				if err != nil {
+					continue
+				}
+
+				fen.app.QueueUpdateDraw(func() {
+					// Ignore our own changes
+					if globalSelection.Equal(handler.last) {
+						return
+					}
+
+					handler.last = globalSelection
+					if fen.config.GlobalSelection {
+						handler.Apply()
+					}
+				})
+			case _, ok := <-handler.watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (handler *GlobalSelectionHandler) Close() {
+	if handler.watcher != nil {
+		handler.watcher.Close()
+	}
+}
+
+func (handler *GlobalSelectionHandler) applyYanked(globalSelection GlobalSelection) {
+	handler.fen.yankType = globalSelection.YankType
+	handler.fen.yankSelected = make(map[string]bool)
+	for _, path := range globalSelection.Yanked {
+		handler.fen.yankSelected[path] = true
+	}
+}
+
+// Overwrites the selection of the current tab and the yanked files with the last known global selection,
+// like when switching tabs or when another fen instance changed it
+func (handler *GlobalSelectionHandler) Apply() {
+	handler.fen.selected = make(map[string]bool)
+	for _, path := range handler.last.Selected {
+		handler.fen.selected[path] = true
+	}
+	handler.applyYanked(handler.last)
+}
+
+// Writes the selection to disk if it changed, call it after anything that may change the selection.
+// Errors are ignored, the selection is still usable in this fen instance
+func (handler *GlobalSelectionHandler) Sync() {
+	if handler.fen.config.NoWrite {
+		return
+	}
+
+	globalSelection := globalSelectionFromFen(handler.fen)
+	if globalSelection.Equal(handler.last) {
+		return
+	}
+
+	handler.last = globalSelection
+	WriteGlobalSelectionFile(handler.path, globalSelection)
+}
@@ -7,23 +7,27 @@ replace github.com/gdamore/tcell/v2 => github.com/kivattt/tcell-naively-faster/v
 replace github.com/rivo/tview => github.com/kivattt/tview v1.0.5
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gdamore/tcell/v2 v2.7.4
 	github.com/kivattt/getopt v0.0.0-20240907012637-674e0e42e04f
 	github.com/kivattt/gogitstatus v0.0.0-20241109231310-7362d587a6fd
+	github.com/mattn/go-runewidth v0.0.16
 	github.com/otiai10/copy v1.14.0
 	github.com/rivo/tview v0.0.0-20241030223020-e34b54cd4c27
+	github.com/ulikunitz/xz v0.5.15
 	github.com/yuin/gluamapper v0.0.0-20150323120927-d836955830e7
 	github.com/yuin/gopher-lua v1.1.1
+	golang.org/x/image v0.18.0
 	golang.org/x/sys v0.26.0
 	golang.org/x/term v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
 	layeh.com/gopher-luar v1.0.11
 )
 
 require (
 	github.com/gdamore/encoding v1.0.1 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06 // indirect
@@ -45,6 +45,7 @@ var helpScreenControlsList = []control{
 	{KeyBindings: []string{"b"}, Description: "Bulk-rename files in editor"},
 	{KeyBindings: []string{"Del", "x"}, Description: "Delete file"},
 	{KeyBindings: []string{"/", "^F"}, Description: "Search"},
+	{KeyBindings: []string{"f"}, Description: "Filter files in the current folder"},
 	{KeyBindings: []string{"c"}, Description: "Goto path"},
 
 	{KeyBindings: []string{"Home", "g"}, Description: "Go to the top"},
@@ -59,8 +60,25 @@ var helpScreenControlsList = []control{
 	{KeyBindings: []string{"A"}, Description: "Flip selection in folder (select all files)"},
 	{KeyBindings: []string{"V"}, Description: "Start selecting by moving"},
 	{KeyBindings: []string{"D"}, Description: "Deselect all, press again to un-yank"},
+	{KeyBindings: []string{"t"}, Description: "Open a new tab"},
+	{KeyBindings: []string{"T"}, Description: "Close the current tab"},
+	{KeyBindings: []string{"[", "]"}, Description: "Go to the previous/next tab"},
+	{KeyBindings: []string{"w"}, Description: "Toggle the dual-pane commander layout"},
+	{KeyBindings: []string{"Tab"}, Description: "Switch to the other panel in the commander layout"},
+	{KeyBindings: []string{"C", "X"}, Description: "Copy/move files to the other panel's folder"},
 	{KeyBindings: []string{"F5"}, Description: "Refresh files, sync screen"},
 	{KeyBindings: []string{"0-9"}, Description: "Go to a configured bookmark"},
+	{KeyBindings: []string{"m"}, Description: "Mark the current folder, followed by a letter"},
+	{KeyBindings: []string{"'"}, Description: "Marks picker, press a letter to go to its mark"},
+	{KeyBindings: []string{"J"}, Description: "Jump to a previously entered folder"},
+	{KeyBindings: []string{"s"}, Description: "Sort menu for the current folder"},
+	{KeyBindings: []string{"i"}, Description: "Toggle detailed view"},
+	{KeyBindings: []string{"P"}, Description: "Toggle fullscreen preview"},
+	{KeyBindings: []string{"F"}, Description: "Follow the selected file as it grows, like tail -f"},
+	{KeyBindings: []string{"v"}, Description: "View the selected file in a pager, like less"},
+	{KeyBindings: []string{"<", ">"}, Description: "Shrink/grow the preview pane"},
+	{KeyBindings: []string{"{", "}"}, Description: "Fewer/more parent folder columns"},
+	{KeyBindings: []string{"Shift+arrow keys"}, Description: "Scroll the file preview"},
 }
 
 func (helpScreen *HelpScreen) Draw(screen tcell.Screen) {
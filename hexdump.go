@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Binary files are shown as a hex dump like "xxd" in the built-in preview.
+// Only the lines around the visible ones are read, so scrolling through large files stays quick
+
+var (
+	hexDumpOffsetStyle      = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	hexDumpNullStyle        = tcell.StyleDefault.Foreground(tcell.ColorGray).Dim(true)
+	hexDumpNonPrintingStyle = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	hexDumpHighStyle        = tcell.StyleDefault.Foreground(tcell.ColorRed) // Bytes 0x80 and above
+)
+
+// The largest of 16, 8 or 4 bytes per line that fits in width
+func HexDumpBytesPerLine(width, offsetDigits int) int {
+	for _, bytesPerLine := range []int{16, 8} {
+		if hexDumpLineWidth(bytesPerLine, offsetDigits) <= width {
+			return bytesPerLine
+		}
+	}
+	return 4
+}
+
+// Like "00000010  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 00  |Hello, world!...|"
+func hexDumpLineWidth(bytesPerLine, offsetDigits int) int {
+	groupSpaces := bytesPerLine/8 - 1
+	return offsetDigits + 2 + bytesPerLine*3 + max(0, groupSpaces) + 1 + bytesPerLine + 2
+}
+
+// At least 8 digits, like xxd
+func HexDumpOffsetDigits(fileSize int64) int {
+	return max(8, len(strconv.FormatInt(fileSize, 16)))
+}
+
+func hexDumpByteStyle(b byte) tcell.Style {
+	switch {
+	case b == 0:
+		return hexDumpNullStyle
+	case b >= 0x80:
+		return hexDumpHighStyle
+	case b < 0x20 || b == 0x7f:
+		return hexDumpNonPrintingStyle
+	}
+	return tcell.StyleDefault
+}
+
+// Formats data, which starts at offset in the file
+func HexDumpLines(data []byte, offset int64, bytesPerLine, offsetDigits int) []StyledLine {
+	const hexDigits = "0123456789abcdef"
+
+	var lines []StyledLine
+	for start := 0; start < len(data); start += bytesPerLine {
+		chunk := data[start:min(len(data), start+bytesPerLine)]
+
+		var builder strings.Builder
+		var spans []StyledSpan
+		addStyled := func(text string, style tcell.Style) {
+			if style != tcell.StyleDefault {
+				spans = append(spans, StyledSpan{Start: builder.Len(), End: builder.Len() + len(text), Style: style})
+			}
+			builder.WriteString(text)
+		}
+
+		offsetText := strconv.FormatInt(offset+int64(start), 16)
+		addStyled(strings.Repeat("0", max(0, offsetDigits-len(offsetText)))+offsetText, hexDumpOffsetStyle)
+		builder.WriteString(" ")
+
+		for i := 0; i < bytesPerLine; i++ {
+			builder.WriteString(" ")
+			if i > 0 && i%8 == 0 {
+				builder.WriteString(" ")
+			}
+
+			if i >= len(chunk) {
+				builder.WriteString("  ")
+				continue
+			}
+			addStyled(string([]byte{hexDigits[chunk[i]>>4], hexDigits[chunk[i]&0xf]}), hexDumpByteStyle(chunk[i]))
+		}
+
+		builder.WriteString("  |")
+		for _, b := range chunk {
+			if b >= 0x20 && b < 0x7f {
+				addStyled(string(rune(b)), tcell.StyleDefault)
+			} else {
+				addStyled(".", hexDumpByteStyle(b))
+			}
+		}
+		builder.WriteString("|")
+
+		lines = append(lines, StyledLine{Text: builder.String(), Spans: spans})
+	}
+
+	return lines
+}
+
+// Reads the lines around the visible ones, a screen above and below so small scrolls don't need a new preview
+func (job PreviewJob) hexDumpPreview(file *os.File, fileSize int64) *PreviewResult {
+	offsetDigits := HexDumpOffsetDigits(fileSize)
+	bytesPerLine := HexDumpBytesPerLine(job.Width, offsetDigits)
+	totalLines := int((fileSize + int64(bytesPerLine) - 1) / int64(bytesPerLine))
+
+	firstLine := max(0, min(job.ScrollY, totalLines-job.Height)-job.Height)
+	lineCount := job.Height * 3
+
+	data := make([]byte, lineCount*bytesPerLine)
+	n, err := file.ReadAt(data, int64(firstLine)*int64(bytesPerLine))
+	if err != nil && err != io.EOF {
+		return &PreviewResult{err: err}
+	}
+
+	result := NewLinesPreviewResult(HexDumpLines(data[:n], int64(firstLine)*int64(bytesPerLine), bytesPerLine, offsetDigits), false)
+	result.linesOffset = firstLine
+	result.totalLines = totalLines
+	result.scrollDependent = true
+	return result
+}
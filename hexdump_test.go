@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHexDumpLines(t *testing.T) {
+	lines := HexDumpLines([]byte("Hello, world!\n\x00\xff!"), 0x10, 16, 8)
+	expected := []string{
+		"00000010  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 ff  |Hello, world!...|",
+		"00000020  21                                                |!|",
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatal("Expected " + strconv.Itoa(len(expected)) + " lines, but got " + strconv.Itoa(len(lines)))
+	}
+
+	for i, line := range lines {
+		if line.Text != expected[i] {
+			t.Fatal("Expected \"" + expected[i] + "\" but got \"" + line.Text + "\"")
+		}
+	}
+
+	if lines[0].Width() != hexDumpLineWidth(16, 8) {
+		t.Fatal("Expected a full line to be " + strconv.Itoa(hexDumpLineWidth(16, 8)) + " wide, but got " + strconv.Itoa(lines[0].Width()))
+	}
+
+	lines = HexDumpLines([]byte{0, 1, 2, 3, 4}, 0, 4, 8)
+	if len(lines) != 2 || lines[1].Text != "00000004  04           |.|" {
+		t.Fatal("Expected 2 lines of 4 bytes")
+	}
+}
+
+func TestHexDumpBytesPerLine(t *testing.T) {
+	tests := map[int]int{
+		200: 16,
+		78:  16,
+		77:  8,
+		45:  8,
+		44:  4,
+		0:   4,
+	}
+
+	for width, expected := range tests {
+		if got := HexDumpBytesPerLine(width, 8); got != expected {
+			t.Fatal("Expected " + strconv.Itoa(expected) + " bytes per line for width " + strconv.Itoa(width) + ", but got " + strconv.Itoa(got))
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 //lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
 
 import (
+	"bufio"
 	"errors"
 	"os"
 	"path/filepath"
@@ -12,6 +13,9 @@ import (
 	"sync"
 )
 
+// The history is saved to this file in FenDataFolder() on exit, so the selected entry in each folder is remembered between sessions
+const historyFilename = "history.txt"
+
 type History struct {
 	history      []string
 	historyMutex sync.Mutex
@@ -167,3 +171,145 @@ func (h *History) ClearHistory() {
 
 	h.history = []string{}
 }
+
+// Returns a copy of the history, most recent first
+func (h *History) Entries() []string {
+	h.historyMutex.Lock()
+	defer h.historyMutex.Unlock()
+
+	return slices.Clone(h.history)
+}
+
+// Adds the entries to the end of the history as the least recent ones, skipping paths already in it
+func (h *History) AppendEntries(entries []string) {
+	h.historyMutex.Lock()
+	defer h.historyMutex.Unlock()
+
+	for _, e := range entries {
+		if !slices.Contains(h.history, e) {
+			h.history = append(h.history, e)
+		}
+	}
+}
+
+// Combines the histories into one, in the order they are passed, removing duplicates and paths that no longer exist.
+// The result has at most maxSize entries
+func MergeHistoryEntries(maxSize int, histories ...[]string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+
+	for _, history := range histories {
+		for _, e := range history {
+			if len(merged) >= maxSize {
+				return merged
+			}
+
+			if seen[e] {
+				continue
+			}
+			seen[e] = true
+
+			if strings.ContainsAny(e, "\n\r") || !filepath.IsAbs(e) {
+				continue
+			}
+
+			_, err := os.Lstat(e)
+			if err != nil {
+				continue
+			}
+
+			merged = append(merged, e)
+		}
+	}
+
+	return merged
+}
+
+// One path per line, most recent first. Returns an empty history if the file doesn't exist
+func ReadHistoryFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return []string{}, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		entries = append(entries, scanner.Text())
+	}
+
+	return entries, scanner.Err()
+}
+
+func WriteHistoryFile(path string, entries []string) error {
+	return WriteFileAtomic(path, []byte(strings.Join(entries, "\n")+"\n"), 0o664)
+}
+
+func historyFilePath() (string, error) {
+	dataFolder, err := FenDataFolder()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataFolder, historyFilename), nil
+}
+
+// Adds the saved history to fen.history, errors are ignored
+func (fen *Fen) LoadHistory() {
+	if fen.config.HistorySize <= 0 {
+		return
+	}
+
+	path, err := historyFilePath()
+	if err != nil {
+		return
+	}
+
+	entries, err := ReadHistoryFile(path)
+	if err != nil {
+		return
+	}
+
+	fen.history.AppendEntries(MergeHistoryEntries(fen.config.HistorySize, entries))
+}
+
+// Saves the history of all tabs, the current tab's history first.
+// The history file is re-read first, so paths saved by other fen instances in the meantime are kept (as less recent)
+func (fen *Fen) SaveHistory() error {
+	if fen.config.NoWrite || fen.config.HistorySize <= 0 {
+		return nil
+	}
+
+	path, err := historyFilePath()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	savedEntries, err := ReadHistoryFile(path)
+	if err != nil {
+		return errors.New("Unable to read history file: " + err.Error())
+	}
+
+	histories := [][]string{fen.history.Entries()}
+	for i, tab := range fen.tabs {
+		if i != fen.currentTab {
+			histories = append(histories, tab.history.Entries())
+		}
+	}
+	histories = append(histories, savedEntries)
+
+	return WriteHistoryFile(path, MergeHistoryEntries(fen.config.HistorySize, histories...))
+}
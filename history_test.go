@@ -1,6 +1,12 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestGetHistoryEntryForPath(t *testing.T) {
 	var h History
@@ -59,3 +65,27 @@ func TestGetHistoryEntryForPath(t *testing.T) {
 	   	}
 	*/
 }
+
+func TestMergeHistoryEntries(t *testing.T) {
+	folder := t.TempDir()
+	a := filepath.Join(folder, "a")
+	b := filepath.Join(folder, "b")
+	c := filepath.Join(folder, "c")
+	for _, path := range []string{a, b, c} {
+		err := os.WriteFile(path, []byte{}, 0o664)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	doesNotExist := filepath.Join(folder, "does-not-exist")
+
+	merged := MergeHistoryEntries(10, []string{b, doesNotExist, a}, []string{a, c, "relative/path"})
+	if !slices.Equal(merged, []string{b, a, c}) {
+		t.Fatal("Expected " + strings.Join([]string{b, a, c}, ", ") + ", but got: " + strings.Join(merged, ", "))
+	}
+
+	merged = MergeHistoryEntries(2, []string{c, b, a})
+	if !slices.Equal(merged, []string{c, b}) {
+		t.Fatal("Expected the history to be capped to 2 entries, but got: " + strings.Join(merged, ", "))
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Images are shown in the built-in preview with "▀" characters, the foreground color being the top pixel and the background color the bottom pixel.
+// Terminals without truecolor support get the closest of the 256 xterm colors instead.
+// With fen.image_protocol, images can also be shown with real pixels, see terminalimage.go.
+// Photos get their EXIF metadata like the camera and the date below the image, see exif.go
+
+// Larger images aren't decoded, they would use too much memory.
+// Decoded they take up to 4 bytes per pixel, and the neighbouring files are prefetched at the same time
+const maxImagePreviewPixels = 40_000_000
+
+// The image formats we can decode, a subset of imageTypes
+var builtInImageTypes = []string{
+	".png",
+	".jpg",
+	".jpeg",
+	".jfif",
+	".gif",
+	".webp",
+	".bmp",
+	".tiff",
+	".tif",
+}
+
+var imageCaptionStyle = tcell.StyleDefault.Foreground(tcell.ColorGray)
+
+func IsBuiltInImage(path string) bool {
+	lowercasePath := strings.ToLower(path)
+	for _, extension := range builtInImageTypes {
+		if strings.HasSuffix(lowercasePath, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns false if it couldn't be decoded, so it can be previewed as a binary file instead
+func (job PreviewJob) imagePreview(ctx context.Context, file *os.File) (*PreviewResult, bool) {
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, false
+	}
+
+	caption := strconv.Itoa(config.Width) + "x" + strconv.Itoa(config.Height) + " " + strings.ToUpper(format)
+	if config.Width*config.Height > maxImagePreviewPixels {
+		return &PreviewResult{message: caption + ", too large to preview"}, true
+	}
+
+	// The caption and the EXIF metadata go below the image, taking at most half of the height
+	footer := []StyledLine{imageCaptionLine(caption)}
+	_, err = file.Seek(0, 0)
+	if err != nil {
+		return &PreviewResult{err: err}, true
+	}
+	if exif, ok := FindEXIF(file); ok {
+		details, err := EXIFDetails(exif)
+		if err == nil && len(details) > 0 {
+			footer = append(footer, StyledLine{})
+			footer = append(footer, DetailLines(details, "")...)
+		}
+	}
+	footer = footer[:max(1, min(len(footer), job.Height/2))]
+
+	_, err = file.Seek(0, 0)
+	if err != nil {
+		return &PreviewResult{err: err}, true
+	}
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, false
+	}
+
+	if ctx.Err() != nil {
+		return job.timedOutResult(ctx), true
+	}
+
+	if job.ImageProtocol == IMAGE_PROTOCOL_KITTY || job.ImageProtocol == IMAGE_PROTOCOL_SIXEL {
+		return job.terminalImagePreview(ctx, img, footer), true
+	}
+
+	lines := HalfBlockLines(ScaleImageToFit(img, job.Width, max(1, job.Height-len(footer))*2), job.TrueColor)
+	return NewLinesPreviewResult(append(lines, footer...), false), true
+}
+
+func imageCaptionLine(caption string) StyledLine {
+	return StyledLine{Text: caption, Spans: []StyledSpan{{Start: 0, End: len(caption), Style: imageCaptionStyle}}}
+}
+
+// Scales img to fit in width x height pixels, keeping the aspect ratio
+func ScaleImageToFit(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return img
+	}
+
+	scale := min(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	scaledWidth := max(1, min(width, int(float64(bounds.Dx())*scale+0.5)))
+	scaledHeight := max(1, min(height, int(float64(bounds.Dy())*scale+0.5)))
+
+	scaled := image.NewNRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	// Bilinear smooths out the detail we lose when shrinking, while small images (like icons) should stay sharp when enlarged
+	var scaler draw.Interpolator = draw.ApproxBiLinear
+	if scale > 1 {
+		scaler = draw.NearestNeighbor
+	}
+	scaler.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+	return scaled
+}
+
+// One line per 2 rows of pixels, pixels that are mostly transparent show the terminal background
+func HalfBlockLines(img image.Image, trueColor bool) []StyledLine {
+	bounds := img.Bounds()
+
+	pixelColor := func(x, y int) (tcell.Color, bool) {
+		if y >= bounds.Max.Y {
+			return tcell.ColorDefault, false
+		}
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		if c.A < 128 {
+			return tcell.ColorDefault, false
+		}
+		if trueColor {
+			return tcell.NewRGBColor(int32(c.R), int32(c.G), int32(c.B)), true
+		}
+		return RGBTo256Color(c.R, c.G, c.B), true
+	}
+
+	var lines []StyledLine
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		var builder strings.Builder
+		var spans []StyledSpan
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top, hasTop := pixelColor(x, y)
+			bottom, hasBottom := pixelColor(x, y+1)
+
+			start := builder.Len()
+			style := tcell.StyleDefault
+			switch {
+			case hasTop:
+				builder.WriteRune('▀')
+				style = style.Foreground(top).Background(bottom)
+			case hasBottom:
+				builder.WriteRune('▄')
+				style = style.Foreground(bottom)
+			default:
+				builder.WriteRune(' ')
+				continue
+			}
+
+			// Neighbouring cells with the same colors share a span
+			if len(spans) > 0 && spans[len(spans)-1].End == start && spans[len(spans)-1].Style == style {
+				spans[len(spans)-1].End = builder.Len()
+			} else {
+				spans = append(spans, StyledSpan{Start: start, End: builder.Len(), Style: style})
+			}
+		}
+		lines = append(lines, StyledLine{Text: builder.String(), Spans: spans})
+	}
+
+	return lines
+}
+
+// The closest color in the xterm 256 color palette, from the 6x6x6 color cube or the grayscale ramp
+func RGBTo256Color(r, g, b uint8) tcell.Color {
+	cubeLevels := [6]int{0, 95, 135, 175, 215, 255}
+
+	closestLevel := func(value uint8) int {
+		closest := 0
+		for i, level := range cubeLevels {
+			if abs(int(value)-level) < abs(int(value)-cubeLevels[closest]) {
+				closest = i
+			}
+		}
+		return closest
+	}
+
+	distance := func(r2, g2, b2 int) int {
+		dr, dg, db := int(r)-r2, int(g)-g2, int(b)-b2
+		return dr*dr + dg*dg + db*db
+	}
+
+	ri, gi, bi := closestLevel(r), closestLevel(g), closestLevel(b)
+	cubeIndex := 16 + 36*ri + 6*gi + bi
+	cubeDistance := distance(cubeLevels[ri], cubeLevels[gi], cubeLevels[bi])
+
+	// The grayscale ramp goes from 8 to 238 in steps of 10
+	average := (int(r) + int(g) + int(b)) / 3
+	grayStep := max(0, min(23, (average-3)/10))
+	grayLevel := 8 + grayStep*10
+	if distance(grayLevel, grayLevel, grayLevel) < cubeDistance {
+		return tcell.PaletteColor(232 + grayStep)
+	}
+
+	return tcell.PaletteColor(cubeIndex)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
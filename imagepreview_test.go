@@ -0,0 +1,83 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestRGBTo256Color(t *testing.T) {
+	tests := map[[3]uint8]tcell.Color{
+		{0, 0, 0}:       tcell.PaletteColor(16),
+		{255, 255, 255}: tcell.PaletteColor(231),
+		{255, 0, 0}:     tcell.PaletteColor(196),
+		{0, 95, 135}:    tcell.PaletteColor(24),
+		{128, 128, 128}: tcell.PaletteColor(244),
+		{8, 8, 8}:       tcell.PaletteColor(232),
+	}
+
+	for rgb, expected := range tests {
+		got := RGBTo256Color(rgb[0], rgb[1], rgb[2])
+		if got != expected {
+			t.Fatal("Expected " + strconv.Itoa(int(expected-tcell.ColorValid)) + " for " + strconv.Itoa(int(rgb[0])) + "," + strconv.Itoa(int(rgb[1])) + "," + strconv.Itoa(int(rgb[2])) + ", but got " + strconv.Itoa(int(got-tcell.ColorValid)))
+		}
+	}
+}
+
+func TestHalfBlockLines(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(0, 1, color.NRGBA{0, 0, 255, 255})
+	img.Set(1, 1, color.NRGBA{0, 255, 0, 255})
+	img.Set(0, 2, color.NRGBA{255, 255, 255, 255})
+	// The rest is transparent
+
+	lines := HalfBlockLines(img, true)
+	expected := []string{"▀▄ ", "▀  "}
+
+	if len(lines) != len(expected) {
+		t.Fatal("Expected " + strconv.Itoa(len(expected)) + " lines, but got " + strconv.Itoa(len(lines)))
+	}
+
+	for i, line := range lines {
+		if line.Text != expected[i] {
+			t.Fatal("Expected \"" + expected[i] + "\" but got \"" + line.Text + "\"")
+		}
+	}
+
+	red := tcell.NewRGBColor(255, 0, 0)
+	blue := tcell.NewRGBColor(0, 0, 255)
+	green := tcell.NewRGBColor(0, 255, 0)
+	if lines[0].Spans[0].Style != tcell.StyleDefault.Foreground(red).Background(blue) {
+		t.Fatal("Expected the top left cell to be red on blue")
+	}
+	if lines[0].Spans[1].Style != tcell.StyleDefault.Foreground(green) {
+		t.Fatal("Expected a green lower half block on the default background")
+	}
+	if lines[1].Spans[0].Style != tcell.StyleDefault.Foreground(tcell.NewRGBColor(255, 255, 255)).Background(tcell.ColorDefault) {
+		t.Fatal("Expected a white upper half block on the default background in the last line")
+	}
+
+	lines = HalfBlockLines(img, false)
+	if lines[0].Spans[0].Style != tcell.StyleDefault.Foreground(tcell.PaletteColor(196)).Background(tcell.PaletteColor(21)) {
+		t.Fatal("Expected 256 colors without truecolor")
+	}
+}
+
+func TestScaleImageToFit(t *testing.T) {
+	tests := map[[2]int][2]int{
+		{1920, 1080}: {80, 45},
+		{100, 400}:   {20, 80},
+		{10, 10}:     {80, 80},
+	}
+
+	for size, expected := range tests {
+		scaled := ScaleImageToFit(image.NewNRGBA(image.Rect(0, 0, size[0], size[1])), 80, 80)
+		if scaled.Bounds().Dx() != expected[0] || scaled.Bounds().Dy() != expected[1] {
+			t.Fatal("Expected " + strconv.Itoa(size[0]) + "x" + strconv.Itoa(size[1]) + " to be scaled to " + strconv.Itoa(expected[0]) + "x" + strconv.Itoa(expected[1]) + ", but got " + strconv.Itoa(scaled.Bounds().Dx()) + "x" + strconv.Itoa(scaled.Bounds().Dy()))
+		}
+	}
+}
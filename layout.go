@@ -0,0 +1,177 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// The miller layout shows fen.parent_columns parent folders (the closest one being fen.leftPane),
+// the current folder and the preview, sized by fen.pane_ratios.
+// Every parent column except fen.leftPane is in fen.parentPanes, furthest away last
+
+const maxParentColumns = 5
+const maxPaneRatio = 20
+
+var DefaultPaneRatios = []int{1, 3, 3}
+
+func ValidatePaneLayout(config *Config) error {
+	if len(config.PaneRatios) != len(DefaultPaneRatios) {
+		return errors.New("Invalid pane_ratios, it has to be 3 numbers like {1, 3, 3} (parent columns, current folder, preview)")
+	}
+
+	for _, ratio := range config.PaneRatios {
+		if ratio < 1 || ratio > maxPaneRatio {
+			return errors.New("Invalid pane_ratios value " + strconv.Itoa(ratio) + ", valid values are 1 to " + strconv.Itoa(maxPaneRatio))
+		}
+	}
+
+	if config.ParentColumns < 0 || config.ParentColumns > maxParentColumns {
+		return errors.New("Invalid parent_columns value " + strconv.Itoa(config.ParentColumns) + ", valid values are 0 to " + strconv.Itoa(maxParentColumns))
+	}
+
+	return nil
+}
+
+// Creates or closes parent filespanes of the current tab so there is one for every parent column after the first
+func (fen *Fen) ensureParentPanes() {
+	wanted := max(0, fen.config.ParentColumns-1)
+
+	for len(fen.parentPanes) > wanted {
+		fen.parentPanes[len(fen.parentPanes)-1].Close()
+		fen.parentPanes = fen.parentPanes[:len(fen.parentPanes)-1]
+	}
+
+	for len(fen.parentPanes) < wanted {
+		parentPane := NewFilesPane(fen, LeftPane)
+		parentPane.tab = fen.tabs[fen.currentTab]
+		parentPane.Init()
+		fen.parentPanes = append(fen.parentPanes, parentPane)
+	}
+}
+
+// Call it in fen.UpdatePanes() after fen.leftPane is updated
+func (fen *Fen) updateParentPanes(forceReadDir bool) {
+	child := filepath.Dir(fen.wd)
+	for _, parentPane := range fen.parentPanes {
+		folder := filepath.Dir(child)
+		parentPane.ChangeDir(folder, forceReadDir)
+
+		// Above the root folder
+		if folder == child {
+			parentPane.entries.Store([]os.DirEntry{})
+		} else {
+			parentPane.SetSelectedEntryFromString(filepath.Base(child))
+		}
+
+		child = folder
+	}
+}
+
+// Returns true when the preview has nothing to show, like a file without a matching fen.preview rule (with fen.built_in_previews disabled)
+func (fen *Fen) RightPaneIsEmpty() bool {
+	if len(fen.rightPane.entries.Load().([]os.DirEntry)) > 0 {
+		return false
+	}
+
+	stat, err := os.Stat(fen.sel)
+	if err != nil || !stat.Mode().IsRegular() {
+		return true
+	}
+
+	// Every non-empty file has a built-in preview
+	if fen.config.BuiltInPreviews && stat.Size() > 0 {
+		return false
+	}
+
+	filenameResolved, err := filepath.EvalSymlinks(fen.sel)
+	if err != nil {
+		filenameResolved = fen.sel
+	}
+
+	for _, previewWith := range fen.config.Preview {
+		if PathMatchesList(filenameResolved, previewWith.Match) && !PathMatchesList(filenameResolved, previewWith.DoNotMatch) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the filespanes to show in the miller layout, from left to right
+func (fen *Fen) visiblePanes() []*FilesPane {
+	if fen.fullscreenPreview {
+		return []*FilesPane{fen.rightPane}
+	}
+
+	var panes []*FilesPane
+	if fen.config.ParentColumns > 0 {
+		parentPanes := append([]*FilesPane{fen.leftPane}, fen.parentPanes...)
+		for i := len(parentPanes) - 1; i >= 0; i-- {
+			// Nothing above the root folder
+			if fen.config.CollapseEmptyPanes && parentPanes[i].folder == filepath.Dir(parentPanes[i].folder) && len(parentPanes[i].entries.Load().([]os.DirEntry)) <= 0 {
+				continue
+			}
+			panes = append(panes, parentPanes[i])
+		}
+	}
+
+	panes = append(panes, fen.middlePane)
+
+	if !fen.config.CollapseEmptyPanes || !fen.RightPaneIsEmpty() {
+		panes = append(panes, fen.rightPane)
+	}
+
+	return panes
+}
+
+// Re-does the layout if a pane should be collapsed or shown again, call it at the end of fen.UpdatePanes()
+func (fen *Fen) updateCollapsedPanes() {
+	if fen.config.Layout != LAYOUT_MILLER {
+		return
+	}
+
+	if !slices.Equal(fen.visiblePanes(), fen.shownPanes) {
+		fen.UpdateLayout()
+	}
+}
+
+func (fen *Fen) ToggleFullscreenPreview() error {
+	if fen.config.Layout != LAYOUT_MILLER {
+		return errors.New("The fullscreen preview is only available in the miller layout")
+	}
+
+	fen.fullscreenPreview = !fen.fullscreenPreview
+	fen.UpdateLayout()
+	return nil
+}
+
+// Changes the size of the preview relative to the other columns, also updates fen.config.PaneRatios
+func (fen *Fen) ResizePreviewPane(delta int) {
+	fen.config.PaneRatios[2] = max(1, min(maxPaneRatio, fen.config.PaneRatios[2]+delta))
+	fen.UpdateLayout()
+	fen.bottomBar.TemporarilyShowTextInstead("Pane ratios: " + PaneRatiosString(fen.config.PaneRatios))
+}
+
+func (fen *Fen) ChangeParentColumns(delta int) {
+	fen.config.ParentColumns = max(0, min(maxParentColumns, fen.config.ParentColumns+delta))
+	fen.UpdateLayout()
+	fen.UpdatePanes(false)
+	fen.bottomBar.TemporarilyShowTextInstead("Parent columns: " + strconv.Itoa(fen.config.ParentColumns))
+}
+
+// Like "1:3:3"
+func PaneRatiosString(ratios []int) string {
+	text := ""
+	for i, ratio := range ratios {
+		if i > 0 {
+			text += ":"
+		}
+		text += strconv.Itoa(ratio)
+	}
+	return text
+}
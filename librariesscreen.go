@@ -37,6 +37,11 @@ var librariesList = []library{
 	{name: "gopher-luar", url: "https://layeh.com/gopher-luar", version: "v1.0.11", license: "MPL 2.0", licenseURL: "https://github.com/layeh/gopher-luar/blob/master/LICENSE"},
 	{name: "rsc/getopt", url: "https://github.com/rsc/getopt", customRevisionURL: "https://github.com/kivattt/getopt", license: "BSD 3-Clause", licenseURL: "https://github.com/rsc/getopt/blob/master/LICENSE"},
 	{name: "kivattt/gogitstatus", url: "https://github.com/kivattt/gogitstatus", version: "commit 7362d58", license: "MIT", licenseURL: "https://github.com/kivattt/gogitstatus/blob/main/LICENSE"},
+	{name: "go-runewidth", url: "https://github.com/mattn/go-runewidth", version: "v0.0.16", license: "MIT", licenseURL: "https://github.com/mattn/go-runewidth/blob/master/LICENSE"},
+	{name: "golang.org/x/image", url: "https://github.com/golang/image", version: "v0.18.0", license: "BSD 3-Clause", licenseURL: "https://github.com/golang/image/blob/master/LICENSE"},
+	{name: "ulikunitz/xz", url: "https://github.com/ulikunitz/xz", version: "v0.5.15", license: "BSD 3-Clause", licenseURL: "https://github.com/ulikunitz/xz/blob/master/LICENSE"},
+	{name: "BurntSushi/toml", url: "https://github.com/BurntSushi/toml", version: "v1.4.0", license: "MIT", licenseURL: "https://github.com/BurntSushi/toml/blob/master/COPYING"},
+	{name: "go-yaml/yaml", url: "https://gopkg.in/yaml.v3", version: "v3.0.1", license: "MIT, Apache 2.0", licenseURL: "https://github.com/go-yaml/yaml/blob/v3/LICENSE"},
 }
 
 func (librariesScreen *LibrariesScreen) Draw(screen tcell.Screen) {
@@ -0,0 +1,341 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/yuin/gluamapper"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// When fen.local_config is enabled, a .fen.lua file in the current folder (or the closest parent folder that has one)
+// can override some options from config.lua while you are inside that folder.
+// Since it can run any code, you are asked before running it. Trusted files are remembered by their contents
+// in this file in FenDataFolder(), so you are asked again if the file changes
+const localConfigFilename = ".fen.lua"
+const trustedLocalConfigsFilename = "trusted_local_configs.json"
+
+var ValidLocalConfigKeys = []string{"sort_by", "sort_reverse", "hidden_files", "preview", "bookmarks"}
+
+// Set by fen before running the .fen.lua file
+var localConfigProvidedKeys = []string{"folder_path", "version", "runtime_os", "home_path"}
+
+// Nil fields were not set in the .fen.lua file
+type LocalConfig struct {
+	Folder string // The folder containing the .fen.lua file
+	Path   string
+	Hash   string // Hex-encoded SHA-256 of the file contents
+
+	SortBy      *string
+	SortReverse *bool
+	HiddenFiles *bool
+	Preview     []PreviewOrOpenEntry // Checked before fen.preview from config.lua
+	Bookmarks   [10]string           // Empty bookmarks use the ones from config.lua
+}
+
+// The fields are given pointer types so we know which ones were set
+type localConfigLua struct {
+	SortBy      *string
+	SortReverse *bool
+	HiddenFiles *bool
+	Preview     []PreviewOrOpenEntry
+	Bookmarks   [10]string
+}
+
+func HashLocalConfig(contents []byte) string {
+	hash := sha256.Sum256(contents)
+	return hex.EncodeToString(hash[:])
+}
+
+// Returns the path of the closest .fen.lua file in folder or its parents, or an empty string if there is none
+func FindLocalConfig(folder string) string {
+	for {
+		path := filepath.Join(folder, localConfigFilename)
+		stat, err := os.Stat(path)
+		if err == nil && stat.Mode().IsRegular() {
+			return path
+		}
+
+		parent := filepath.Dir(folder)
+		if parent == folder {
+			return ""
+		}
+		folder = parent
+	}
+}
+
+// Runs the Lua code in contents, which should be the contents of the file at path.
+// It is given the file contents instead of reading the file itself, so what runs is guaranteed to be what was trusted
+func RunLocalConfig(path string, contents []byte) (*LocalConfig, error) {
+	L := lua.NewState()
+	defer L.Close()
+
+	folder := filepath.Dir(path)
+
+	luaInitialConfigTable := L.NewTable()
+	luaInitialConfigTable.RawSetString("folder_path", lua.LString(PathWithEndSeparator(folder)))
+	luaInitialConfigTable.RawSetString("version", lua.LString(version))
+	luaInitialConfigTable.RawSetString("runtime_os", lua.LString(runtime.GOOS))
+	userHomeDir, err := os.UserHomeDir()
+	if err == nil {
+		luaInitialConfigTable.RawSetString("home_path", lua.LString(PathWithEndSeparator(userHomeDir)))
+	}
+	L.SetGlobal("fen", luaInitialConfigTable)
+
+	fn, err := L.Load(strings.NewReader(string(contents)), path)
+	if err != nil {
+		return nil, err
+	}
+	L.Push(fn)
+	err = L.PCall(0, lua.MultRet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fenGlobalAsTablePointer, ok := L.GetGlobal("fen").(*lua.LTable)
+	if !ok {
+		return nil, errors.New("The \"fen\" global has to be a table")
+	}
+
+	// Only a few options make sense per folder, don't silently ignore the others
+	var invalidKey string
+	fenGlobalAsTablePointer.ForEach(func(key, value lua.LValue) {
+		keyString := key.String()
+		if invalidKey == "" && !slices.Contains(ValidLocalConfigKeys, keyString) && !slices.Contains(localConfigProvidedKeys, keyString) {
+			invalidKey = keyString
+		}
+	})
+	if invalidKey != "" {
+		return nil, errors.New("Unsupported option fen." + invalidKey + " in " + localConfigFilename + ", valid options: " + strings.Join(ValidLocalConfigKeys, ", "))
+	}
+
+	for _, key := range localConfigProvidedKeys {
+		fenGlobalAsTablePointer.RawSetString(key, lua.LNil)
+	}
+
+	var values localConfigLua
+	err = gluamapper.Map(fenGlobalAsTablePointer, &values)
+	if err != nil {
+		return nil, err
+	}
+
+	if values.SortBy != nil && !slices.Contains(ValidSortByValues[:], *values.SortBy) {
+		return nil, errors.New("Invalid sort_by value \"" + *values.SortBy + "\" in " + localConfigFilename + "\nValid values: " + strings.Join(ValidSortByValues[:], ", "))
+	}
+
+	// Relative bookmarks are relative to the folder of the .fen.lua file
+	for i, bookmark := range values.Bookmarks {
+		if bookmark != "" && !filepath.IsAbs(bookmark) {
+			values.Bookmarks[i] = filepath.Join(folder, bookmark)
+		}
+	}
+
+	return &LocalConfig{
+		Folder:      folder,
+		Path:        path,
+		Hash:        HashLocalConfig(contents),
+		SortBy:      values.SortBy,
+		SortReverse: values.SortReverse,
+		HiddenFiles: values.HiddenFiles,
+		Preview:     values.Preview,
+		Bookmarks:   values.Bookmarks,
+	}, nil
+}
+
+// Returns whether folder is localConfig.Folder or inside it
+func (localConfig *LocalConfig) Contains(folder string) bool {
+	return folder == localConfig.Folder || strings.HasPrefix(folder, PathWithEndSeparator(localConfig.Folder))
+}
+
+// Returns a map of .fen.lua paths to their trusted hash, or an empty map if the file doesn't exist
+func ReadTrustedLocalConfigsFile(path string) (map[string]string, error) {
+	trusted := make(map[string]string)
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return trusted, nil
+		}
+		return trusted, err
+	}
+
+	err = json.Unmarshal(bytes, &trusted)
+	if err != nil {
+		return make(map[string]string), err
+	}
+
+	return trusted, nil
+}
+
+func WriteTrustedLocalConfigsFile(path string, trusted map[string]string) error {
+	bytes, err := json.MarshalIndent(trusted, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(path, bytes, 0o600)
+}
+
+func trustedLocalConfigsFilePath() (string, error) {
+	dataFolder, err := FenDataFolder()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataFolder, trustedLocalConfigsFilename), nil
+}
+
+// Errors are ignored, no .fen.lua files will be trusted
+func (fen *Fen) LoadTrustedLocalConfigs() {
+	fen.trustedLocalConfigs = make(map[string]string)
+	fen.distrustedLocalConfigs = make(map[string]string)
+
+	path, err := trustedLocalConfigsFilePath()
+	if err != nil {
+		return
+	}
+
+	trusted, err := ReadTrustedLocalConfigsFile(path)
+	if err != nil {
+		return
+	}
+
+	fen.trustedLocalConfigs = trusted
+}
+
+// Trusts the current contents of the .fen.lua file at path.
+// The file is re-read first so files trusted in other fen instances are kept. In no-write mode, it is only trusted for this session
+func (fen *Fen) TrustLocalConfig(path, hash string) error {
+	delete(fen.distrustedLocalConfigs, path)
+
+	if fen.config.NoWrite {
+		fen.trustedLocalConfigs[path] = hash
+		return nil
+	}
+
+	trustedPath, err := trustedLocalConfigsFilePath()
+	if err != nil {
+		fen.trustedLocalConfigs[path] = hash
+		return err
+	}
+
+	unlock, err := LockFile(trustedPath)
+	if err != nil {
+		fen.trustedLocalConfigs[path] = hash
+		return err
+	}
+	defer unlock()
+
+	trusted, err := ReadTrustedLocalConfigsFile(trustedPath)
+	if err != nil {
+		// Don't overwrite a file we can't understand
+		fen.trustedLocalConfigs[path] = hash
+		return errors.New("Unable to read trusted local configs file: " + err.Error())
+	}
+
+	trusted[path] = hash
+	fen.trustedLocalConfigs = trusted
+	return WriteTrustedLocalConfigsFile(trustedPath, trusted)
+}
+
+// Not trusting a .fen.lua file is only remembered for this session, until the file changes
+func (fen *Fen) DistrustLocalConfig(path, hash string) {
+	fen.distrustedLocalConfigs[path] = hash
+}
+
+// Finds and runs the .fen.lua file for fen.wd, then layers it on top of fen.config. Call it at the start of fen.UpdatePanes().
+// It only looks for the file again when fen.wd changed or recheck is true, and only re-runs it if the file changed.
+// Returns true if fen.config.HiddenFiles changed, since the panes then have to re-read their folders
+func (fen *Fen) UpdateLocalConfig(recheck bool) bool {
+	hiddenFilesBefore := fen.config.HiddenFiles
+
+	if !fen.config.LocalConfig {
+		fen.applyLocalConfig(nil)
+		return fen.config.HiddenFiles != hiddenFilesBefore
+	}
+
+	if fen.wd == fen.localConfigCheckedWD && !recheck {
+		return false
+	}
+	fen.localConfigCheckedWD = fen.wd
+
+	path := FindLocalConfig(fen.wd)
+	if path == "" {
+		fen.applyLocalConfig(nil)
+		return fen.config.HiddenFiles != hiddenFilesBefore
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		fen.applyLocalConfig(nil)
+		return fen.config.HiddenFiles != hiddenFilesBefore
+	}
+	hash := HashLocalConfig(contents)
+
+	// Already applied, re-applying would undo changes made to the overridden options while inside the folder
+	if fen.localConfig != nil && fen.localConfig.Path == path && fen.localConfig.Hash == hash {
+		return false
+	}
+
+	if fen.trustedLocalConfigs[path] != hash {
+		fen.applyLocalConfig(nil)
+
+		if fen.distrustedLocalConfigs[path] != hash {
+			// If it couldn't ask right now, we try again next time
+			if fen.askToTrustLocalConfig == nil || !fen.askToTrustLocalConfig(path, hash) {
+				fen.localConfigCheckedWD = ""
+			}
+		}
+		return fen.config.HiddenFiles != hiddenFilesBefore
+	}
+
+	localConfig, err := RunLocalConfig(path, contents)
+	if err != nil {
+		fen.applyLocalConfig(nil)
+		fen.bottomBar.TemporarilyShowTextInstead("Error in " + path + ": " + err.Error())
+		return fen.config.HiddenFiles != hiddenFilesBefore
+	}
+
+	fen.applyLocalConfig(localConfig)
+	return fen.config.HiddenFiles != hiddenFilesBefore
+}
+
+// Restores the options overridden by the last local config, then overrides them with localConfig (if not nil).
+// This means changing one of these options while inside a folder with a .fen.lua file only lasts until you leave it
+func (fen *Fen) applyLocalConfig(localConfig *LocalConfig) {
+	if fen.localConfig != nil {
+		fen.config.HiddenFiles = fen.configWithoutLocalConfig.HiddenFiles
+		fen.config.Preview = fen.configWithoutLocalConfig.Preview
+		fen.config.Bookmarks = fen.configWithoutLocalConfig.Bookmarks
+	}
+
+	fen.localConfig = localConfig
+	if localConfig == nil {
+		return
+	}
+
+	fen.configWithoutLocalConfig = fen.config
+
+	if localConfig.HiddenFiles != nil {
+		fen.config.HiddenFiles = *localConfig.HiddenFiles
+	}
+
+	if len(localConfig.Preview) > 0 {
+		fen.config.Preview = append(slices.Clone(localConfig.Preview), fen.config.Preview...)
+	}
+
+	for i, bookmark := range localConfig.Bookmarks {
+		if bookmark != "" {
+			fen.config.Bookmarks[i] = bookmark
+		}
+	}
+}
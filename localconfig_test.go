@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunLocalConfig(t *testing.T) {
+	folder := t.TempDir()
+	path := filepath.Join(folder, localConfigFilename)
+
+	localConfig, err := RunLocalConfig(path, []byte(`
+fen.sort_by = "modified"
+fen.hidden_files = true
+fen.bookmarks = {[1] = "Holidays", [10] = "/"}
+fen.preview = {{program = "cat", match = "*.txt"}}
+`))
+	if err != nil {
+		t.Fatal("Failed to run local config: " + err.Error())
+	}
+
+	if localConfig.Folder != folder || localConfig.Hash == "" {
+		t.Fatal("Expected folder " + folder + " and a hash, but got " + localConfig.Folder + " and " + localConfig.Hash)
+	}
+
+	if localConfig.SortBy == nil || *localConfig.SortBy != SORT_MODIFIED || localConfig.HiddenFiles == nil || !*localConfig.HiddenFiles {
+		t.Fatal("Expected sort_by and hidden_files to be set")
+	}
+
+	if localConfig.SortReverse != nil {
+		t.Fatal("Expected sort_reverse to not be set")
+	}
+
+	if localConfig.Bookmarks[0] != filepath.Join(folder, "Holidays") || localConfig.Bookmarks[9] != "/" || localConfig.Bookmarks[1] != "" {
+		t.Fatal("Expected relative bookmarks to be relative to the folder of the local config")
+	}
+
+	if len(localConfig.Preview) != 1 || localConfig.Preview[0].Program[0] != "cat" {
+		t.Fatal("Expected 1 preview rule")
+	}
+
+	invalidConfigs := []string{
+		`fen.mouse = false`,
+		`fen.sort_by = "invalid"`,
+		`fen = 1`,
+		`this is not Lua`,
+	}
+
+	for _, contents := range invalidConfigs {
+		_, err := RunLocalConfig(path, []byte(contents))
+		if err == nil {
+			t.Fatal("Expected an error for local config: " + contents)
+		}
+	}
+}
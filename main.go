@@ -82,6 +82,11 @@ func main() {
 	configFilename := flag.String("config", defaultConfigFilenamePath, "use configuration file")
 	sortBy := flag.String("sort-by", defaultConfigValues.SortBy, "sort files ("+strings.Join(ValidSortByValues[:], ", ")+")")
 	sortReverse := flag.Bool("sort-reverse", defaultConfigValues.SortReverse, "reverse sort")
+	layout := flag.String("layout", defaultConfigValues.Layout, "panes layout ("+strings.Join(ValidLayoutValues[:], ", ")+")")
+	globalSelection := flag.Bool("global-selection", defaultConfigValues.GlobalSelection, "share selected and yanked files with other fen instances")
+	frecency := flag.Bool("frecency", defaultConfigValues.Frecency, "record entered folders for jumping to them")
+	importFrecency := flag.String("import-frecency", "", "import the folders known by another program ("+strings.Join(ValidFrecencyImportValues[:], ", ")+") for jumping to them, and exit")
+	clearPreviewCache := flag.Bool("clear-preview-cache", false, "delete the cached file previews and exit")
 
 	getopt.CommandLine.SetOutput(os.Stdout)
 	getopt.CommandLine.Init("fen", flag.ExitOnError)
@@ -214,11 +219,53 @@ func main() {
 	if flagPassed("sort-reverse") {
 		fen.config.SortReverse = *sortReverse
 	}
+	if flagPassed("layout") {
+		fen.config.Layout = *layout
+	}
+	if flagPassed("frecency") {
+		fen.config.Frecency = *frecency
+	}
+	if flagPassed("global-selection") {
+		fen.config.GlobalSelection = *globalSelection
+	}
+
+	if flagPassed("import-frecency") {
+		if fen.config.NoWrite {
+			fmt.Fprintln(os.Stderr, "Can't import in no-write mode")
+			os.Exit(1)
+		}
+
+		count, err := ImportFrecency(*importFrecency)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Imported " + strconv.Itoa(count) + " folders from " + *importFrecency)
+		os.Exit(0)
+	}
+
+	if *clearPreviewCache {
+		if fen.config.NoWrite {
+			fmt.Fprintln(os.Stderr, "Can't clear the preview cache in no-write mode")
+			os.Exit(1)
+		}
+
+		err := ClearPreviewCache()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Cleared the preview cache")
+		os.Exit(0)
+	}
 
 	app := tview.NewApplication()
 
 	helpScreen := NewHelpScreen(&fen)
 	librariesScreen := NewLibrariesScreen()
+	pager := NewPager(&fen)
 
 	err = fen.Init(path, app, &helpScreen.visible, &librariesScreen.visible)
 	defer fen.Fini()
@@ -228,10 +275,7 @@ func main() {
 
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(fen.topBar, 1, 0, false).
-		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
-			AddItem(fen.leftPane, 0, 1, false).
-			AddItem(fen.middlePane, 0, 3, false).
-			AddItem(fen.rightPane, 0, 3, false), 0, 1, false).
+		AddItem(fen.panesFlex, 0, 1, false).
 		AddItem(fen.bottomBar, 1, 0, false)
 
 	pages := tview.NewPages().
@@ -287,9 +331,36 @@ func main() {
 		return event
 	})
 
+	pager.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if pager.HandleKey(event) {
+			pager.Close()
+			pages.RemovePage("popup")
+			fen.ShowFilepanes()
+		}
+		return nil
+	})
+
+	// The selection can change in a lot of places, so we check if it changed after every draw
+	app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		if fen.config.GlobalSelection && fen.initializedGlobalSelection {
+			fen.globalSelectionHandler.Sync()
+		}
+
+		fen.terminalImages.AfterDraw(screen, pages.HasPage("popup"))
+	})
+
 	lastWheelUpTime := time.Now()
 	lastWheelDownTime := time.Now()
 	app.SetMouseCapture(func(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+		if pager.visible && (action == tview.MouseScrollUp || action == tview.MouseScrollDown) {
+			delta := 3
+			if action == tview.MouseScrollUp {
+				delta = -3
+			}
+			pager.Scroll(delta)
+			return nil, action
+		}
+
 		if pages.HasPage("popup") {
 			// Since `return nil, action` redraws the screen for some reason,
 			// we have to manually pass through mouse movement events so the screen won't flicker when you move your mouse
@@ -356,12 +427,42 @@ func main() {
 			return event, action
 		}
 
+		// Scrolling over the preview scrolls the preview instead of moving the selection
+		if event.Buttons() == tcell.WheelUp || event.Buttons() == tcell.WheelDown || event.Buttons() == tcell.WheelLeft || event.Buttons() == tcell.WheelRight {
+			mouseX, mouseY := event.Position()
+			if fen.PreviewIsVisible() && fen.rightPane.InRect(mouseX, mouseY) {
+				deltaY, deltaX := 0, 0
+				switch event.Buttons() {
+				case tcell.WheelUp:
+					deltaY = -fen.config.ScrollSpeed
+				case tcell.WheelDown:
+					deltaY = fen.config.ScrollSpeed
+				case tcell.WheelLeft:
+					deltaX = -previewScrollStepX
+				case tcell.WheelRight:
+					deltaX = previewScrollStepX
+				}
+
+				if !fen.ScrollPreview(deltaY, deltaX) {
+					app.DontDrawOnThisEventMouse()
+				}
+				return nil, action
+			}
+		}
+
 		// Movement/navigation keys
 		switch event.Buttons() {
 		case tcell.Button1, tcell.Button2:
+			mouseX, mouseY := event.Position()
+
+			// Clicking the other panel in the commander layout switches to it
+			otherTab := fen.OtherTabIndex()
+			if fen.config.Layout == LAYOUT_COMMANDER && otherTab != -1 && fen.tabs[otherTab].middlePane.InRect(mouseX, mouseY) {
+				fen.SwitchCommanderSide()
+			}
+
 			// Small inconsistency with --ui-borders when clicking the left border of the middlepane, not important
 			x, y, w, h := fen.middlePane.GetInnerRect()
-			mouseX, mouseY := event.Position()
 
 			if mouseY < y || mouseY > h { // We don't check > y+h so clicking the bottom row of the screen is ignored
 				break
@@ -418,6 +519,229 @@ func main() {
 
 	enterWillSelectAutoCompleteInGotoPath := false
 
+	var showMarksPicker func()
+	showMarksPicker = func() {
+		letters := fen.MarkLettersSorted()
+		if len(letters) == 0 {
+			fen.bottomBar.TemporarilyShowTextInstead("No marks set, press m followed by a letter to mark the current folder")
+			return
+		}
+
+		list := tview.NewList().ShowSecondaryText(false)
+		list.SetBorder(true)
+		list.SetBorderStyle(tcell.StyleDefault.Background(tcell.ColorBlack))
+		list.SetTitle(" Marks (Delete to delete, F2 to rename) ")
+		list.SetTitleColor(tcell.ColorDefault)
+		list.SetBackgroundColor(tcell.ColorBlack)
+		list.SetShortcutColor(tcell.NewRGBColor(0, 255, 0)) // Green
+
+		for _, letter := range letters {
+			letter := letter
+			mark := fen.marks[letter]
+
+			text := tview.Escape(mark.Path)
+			if mark.Name != "" {
+				text = tview.Escape(mark.Name) + " [::d]" + text
+			}
+
+			list.AddItem(text, "", letter, func() {
+				pages.RemovePage("popup")
+				err := fen.GoMark(letter)
+				if err != nil {
+					fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+				}
+			})
+		}
+
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape {
+				pages.RemovePage("popup")
+				return nil
+			}
+
+			letter := letters[list.GetCurrentItem()]
+			if event.Key() == tcell.KeyDelete {
+				pages.RemovePage("popup")
+				err := fen.DeleteMark(letter)
+				showMarksPicker()
+				if err != nil {
+					fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+				}
+				return nil
+			} else if event.Key() == tcell.KeyF2 {
+				pages.RemovePage("popup")
+
+				inputField := tview.NewInputField().
+					SetLabel(" Rename mark " + string(letter) + ": ").
+					SetText(fen.marks[letter].Name).
+					SetPlaceholder("Empty to show the path").
+					SetFieldWidth(-1) // Special feature of my tview fork, github.com/kivattt/tview
+
+				inputField.SetBorder(true)
+				inputField.SetBorderStyle(tcell.StyleDefault.Background(tcell.ColorBlack))
+				inputField.SetTitleColor(tcell.ColorDefault)
+				inputField.SetFieldBackgroundColor(tcell.ColorGray)
+				inputField.SetFieldTextColor(tcell.ColorBlack)
+				inputField.SetBackgroundColor(tcell.ColorBlack)
+				inputField.SetPlaceholderStyle(tcell.StyleDefault.Background(tcell.ColorGray).Dim(true))
+
+				inputField.SetLabelStyle(tcell.StyleDefault.Background(tcell.ColorBlack)) // This has to be before the .SetLabelColor
+				inputField.SetLabelColor(tcell.NewRGBColor(0, 255, 0))                    // Green
+
+				inputField.SetDoneFunc(func(key tcell.Key) {
+					pages.RemovePage("popup")
+
+					var err error
+					if key != tcell.KeyEscape {
+						err = fen.RenameMark(letter, strings.TrimSpace(inputField.GetText()))
+					}
+
+					showMarksPicker()
+					if err != nil {
+						fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+					}
+				})
+
+				pages.AddPage("popup", centered(inputField, 3), true, true)
+				app.SetFocus(inputField)
+				return nil
+			}
+
+			return event
+		})
+
+		pages.AddPage("popup", centered(list, len(letters)+2), true, true)
+		app.SetFocus(list)
+	}
+
+	fen.askToTrustLocalConfig = func(path, hash string) bool {
+		if pages.HasPage("popup") {
+			return false
+		}
+
+		modal := tview.NewModal()
+
+		modal.SetInputCapture(func(e *tcell.EventKey) *tcell.EventKey {
+			switch e.Rune() {
+			case 'h':
+				return tcell.NewEventKey(tcell.KeyLeft, e.Rune(), e.Modifiers())
+			case 'l':
+				return tcell.NewEventKey(tcell.KeyRight, e.Rune(), e.Modifiers())
+			case 'j':
+				return tcell.NewEventKey(tcell.KeyDown, e.Rune(), e.Modifiers())
+			case 'k':
+				return tcell.NewEventKey(tcell.KeyUp, e.Rune(), e.Modifiers())
+			}
+
+			return e
+		})
+
+		modal.SetText("Found a local config file:\n" + path + "\n\nIt can run any code, only trust it if you wrote or reviewed it.\nYou will be asked again if it changes")
+		modal.
+			AddButtons([]string{"Trust", "Don't trust"}).
+			SetFocus(1). // Default is "Don't trust"
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				pages.RemovePage("popup")
+
+				if buttonIndex != 0 {
+					fen.DistrustLocalConfig(path, hash)
+					return
+				}
+
+				err := fen.TrustLocalConfig(path, hash)
+				fen.UpdatePanes(true)
+				if err != nil {
+					fen.bottomBar.TemporarilyShowTextInstead("Unable to remember trusting " + path + ": " + err.Error())
+				}
+			})
+
+		pages.AddPage("popup", modal, true, true)
+		app.SetFocus(modal)
+		return true
+	}
+
+	// Asks to trust the .fen.lua file in the starting folder, which fen.Init() couldn't do
+	fen.UpdatePanes(false)
+
+	sortPickerShortcuts := map[string]rune{
+		SORT_NONE:           '0',
+		SORT_ALPHABETICAL:   'a',
+		SORT_NATURAL:        'n',
+		SORT_MODIFIED:       'm',
+		SORT_CREATED:        'c',
+		SORT_ACCESSED:       't',
+		SORT_SIZE:           's',
+		SORT_FILE_EXTENSION: 'e',
+		SORT_FILE_TYPE:      'f',
+		SORT_GIT_STATUS:     'g',
+	}
+
+	showSortPicker := func() {
+		folder := fen.wd
+		sortBy, sortReverse := fen.SortForFolder(folder)
+		_, remembered := fen.folderSorts[folder]
+
+		setFolderSort := func(folderSort *FolderSort) {
+			pages.RemovePage("popup")
+			err := fen.SetFolderSort(folder, folderSort)
+			fen.UpdatePanes(true)
+			if err != nil {
+				fen.bottomBar.TemporarilyShowTextInstead("Unable to remember sort mode: " + err.Error())
+			}
+		}
+
+		list := tview.NewList().ShowSecondaryText(false)
+		list.SetBorder(true)
+		list.SetBorderStyle(tcell.StyleDefault.Background(tcell.ColorBlack))
+		list.SetTitle(" Sort " + tview.Escape(filepath.Base(folder)) + " by ")
+		list.SetTitleColor(tcell.ColorDefault)
+		list.SetBackgroundColor(tcell.ColorBlack)
+		list.SetShortcutColor(tcell.NewRGBColor(0, 255, 0)) // Green
+
+		for _, value := range ValidSortByValues {
+			value := value
+			text := value
+			if value == sortBy {
+				text += " [::d](current)"
+			}
+
+			list.AddItem(text, "", sortPickerShortcuts[value], func() {
+				setFolderSort(&FolderSort{SortBy: value, SortReverse: sortReverse})
+			})
+		}
+
+		reverseText := "Reverse"
+		if sortReverse {
+			reverseText += " [::d](on)"
+		}
+		list.AddItem(reverseText, "", 'r', func() {
+			setFolderSort(&FolderSort{SortBy: sortBy, SortReverse: !sortReverse})
+		})
+
+		resetText := "Reset to default"
+		if !remembered {
+			resetText += " [::d](already default)"
+		}
+		list.AddItem(resetText, "", 'd', func() {
+			setFolderSort(nil)
+		})
+
+		list.SetCurrentItem(slices.Index(ValidSortByValues[:], sortBy))
+
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape {
+				pages.RemovePage("popup")
+				return nil
+			}
+			return event
+		})
+
+		pages.AddPage("popup", centered(list, len(ValidSortByValues)+2+2), true, true)
+		app.SetFocus(list)
+	}
+
+	waitingForMarkLetter := false
+
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if pages.HasPage("popup") {
 			return event
@@ -425,6 +749,23 @@ func main() {
 
 		fen.bottomBar.alternateText = ""
 
+		// The key after pressing m
+		if waitingForMarkLetter {
+			waitingForMarkLetter = false
+			if event.Key() != tcell.KeyRune {
+				return nil
+			}
+
+			err := fen.SetMark(event.Rune())
+			if err != nil {
+				fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+				return nil
+			}
+
+			fen.bottomBar.TemporarilyShowTextInstead("Marked \"" + fen.wd + "\" as " + string(event.Rune()))
+			return nil
+		}
+
 		if event.Rune() == 'q' || (fen.config.CloseOnEscape && event.Key() == tcell.KeyEscape) {
 			fen.fileOperationsHandler.workCountMutex.Lock()
 			if fen.fileOperationsHandler.workCount <= 0 {
@@ -477,6 +818,26 @@ func main() {
 			return nil
 		}
 
+		// Shift+arrow keys scroll the preview
+		if event.Modifiers()&tcell.ModShift != 0 && (event.Key() == tcell.KeyUp || event.Key() == tcell.KeyDown || event.Key() == tcell.KeyLeft || event.Key() == tcell.KeyRight) {
+			deltaY, deltaX := 0, 0
+			switch event.Key() {
+			case tcell.KeyUp:
+				deltaY = -1
+			case tcell.KeyDown:
+				deltaY = 1
+			case tcell.KeyLeft:
+				deltaX = -previewScrollStepX
+			case tcell.KeyRight:
+				deltaX = previewScrollStepX
+			}
+
+			if !fen.ScrollPreview(deltaY, deltaX) {
+				app.DontDrawOnThisEventKey()
+			}
+			return nil
+		}
+
 		// Movement/navigation keys
 		wasMovementKey := true
 		if (event.Modifiers()&tcell.ModCtrl == 0 && event.Key() == tcell.KeyLeft) || event.Rune() == 'h' {
@@ -582,6 +943,48 @@ func main() {
 
 			pages.AddPage("popup", centered(inputField, 3), true, true)
 			return nil
+		} else if event.Rune() == 'f' {
+			filterBefore := fen.filter
+			if fen.filterFolder != fen.wd {
+				filterBefore = ""
+			}
+
+			inputField := tview.NewInputField().
+				SetLabel(" Filter: ").
+				SetPlaceholder("case-insensitive, glob or substring, empty to clear").
+				SetText(filterBefore).
+				SetFieldWidth(-1) // Special feature of my tview fork, github.com/kivattt/tview
+
+			// Live filtering, the entries update as you type
+			inputField.SetChangedFunc(func(text string) {
+				fen.SetFilter(text)
+			})
+
+			inputField.SetDoneFunc(func(key tcell.Key) {
+				pages.RemovePage("popup")
+
+				if key == tcell.KeyEscape {
+					fen.SetFilter(filterBefore)
+					return
+				}
+
+				if inputField.GetText() == "" {
+					fen.bottomBar.TemporarilyShowTextInstead("Filter cleared")
+				}
+			})
+
+			inputField.SetBorder(true)
+			inputField.SetBorderStyle(tcell.StyleDefault.Background(tcell.ColorBlack))
+			inputField.SetTitleColor(tcell.ColorDefault)
+			inputField.SetFieldBackgroundColor(tcell.ColorGray)
+			inputField.SetFieldTextColor(tcell.ColorBlack)
+			inputField.SetLabelStyle(tcell.StyleDefault.Background(tcell.ColorBlack))
+			inputField.SetLabelColor(tcell.NewRGBColor(0, 255, 0)) // Green
+			inputField.SetPlaceholderStyle(tcell.StyleDefault.Background(tcell.ColorGray).Dim(true))
+
+			pages.AddPage("popup", centered(inputField, 3), true, true)
+			app.SetFocus(inputField)
+			return nil
 		} else if event.Rune() == 'A' {
 			for _, e := range fen.middlePane.entries.Load().([]os.DirEntry) {
 				fen.ToggleSelection(filepath.Join(fen.wd, e.Name()))
@@ -1012,6 +1415,109 @@ func main() {
 
 			enterWillSelectAutoCompleteInGotoPath = false
 
+			pages.AddPage("popup", centered(inputField, 3), true, true)
+			app.SetFocus(inputField)
+			return nil
+		} else if event.Rune() == 's' {
+			showSortPicker()
+			return nil
+		} else if event.Rune() == 'i' {
+			fen.ToggleDetailedView()
+			return nil
+		} else if event.Rune() == 'P' {
+			err := fen.ToggleFullscreenPreview()
+			if err != nil {
+				fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+			}
+			return nil
+		} else if event.Rune() == 'F' {
+			err := fen.TogglePreviewFollow()
+			if err != nil {
+				fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+			}
+			return nil
+		} else if event.Rune() == 'v' {
+			err := pager.Open(fen.sel)
+			if err != nil {
+				fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+				return nil
+			}
+			pages.AddPage("popup", pager, true, true)
+			fen.HideFilepanes()
+			return nil
+		} else if event.Rune() == '<' || event.Rune() == '>' {
+			delta := 1
+			if event.Rune() == '<' {
+				delta = -1
+			}
+			fen.ResizePreviewPane(delta)
+			return nil
+		} else if event.Rune() == '{' || event.Rune() == '}' {
+			delta := 1
+			if event.Rune() == '{' {
+				delta = -1
+			}
+			fen.ChangeParentColumns(delta)
+			return nil
+		} else if event.Rune() == 'J' {
+			inputField := tview.NewInputField().
+				SetLabel(" Jump to: ").
+				SetPlaceholder("Parts of the path of a previously entered folder").
+				SetFieldWidth(-1) // Special feature of my tview fork, github.com/kivattt/tview
+
+			showJumpResult := func(path string, err error) {
+				if err != nil {
+					fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+					return
+				}
+
+				fen.bottomBar.TemporarilyShowTextInstead("Jumped to: \"" + path + "\"")
+			}
+
+			inputField.SetDoneFunc(func(key tcell.Key) {
+				pages.RemovePage("popup")
+				if key == tcell.KeyEscape || inputField.GetText() == "" {
+					return
+				}
+
+				showJumpResult(fen.GoFrecencyJump(inputField.GetText()))
+			})
+
+			inputField.SetAutocompleteFunc(func(currentText string) (entries []string) {
+				if strings.TrimSpace(currentText) == "" {
+					return []string{}
+				}
+
+				return fen.frecency.Query(currentText, 10, fen.wd)
+			})
+			inputField.SetAutocompletedFunc(func(text string, index int, source int) bool {
+				if source == tview.AutocompletedNavigate {
+					return false
+				}
+
+				if source == tview.AutocompletedTab {
+					inputField.SetText(text)
+					return true
+				}
+
+				// Enter on a suggestion
+				pages.RemovePage("popup")
+				showJumpResult(fen.GoPath(text))
+				return true
+			})
+
+			inputField.SetAutocompleteStyles(tcell.ColorBlack, tcell.StyleDefault.Foreground(tcell.ColorBlue).Bold(true).Background(tcell.ColorBlack), tcell.StyleDefault.Foreground(tcell.ColorBlue).Bold(true).Background(tcell.ColorWhite))
+
+			inputField.SetTitleColor(tcell.ColorDefault)
+			inputField.SetFieldBackgroundColor(tcell.ColorGray)
+			inputField.SetFieldTextColor(tcell.ColorBlack)
+			inputField.SetBackgroundColor(tcell.ColorBlack)
+			inputField.SetLabelStyle(tcell.StyleDefault.Background(tcell.ColorBlack)) // This has to be before the .SetLabelColor
+			inputField.SetLabelColor(tcell.NewRGBColor(0, 255, 0))                    // Green
+			inputField.SetPlaceholderStyle(tcell.StyleDefault.Background(tcell.ColorGray).Dim(true))
+			inputField.SetBorder(true)
+			inputField.SetBorderStyle(tcell.StyleDefault.Background(tcell.ColorBlack))
+
 			pages.AddPage("popup", centered(inputField, 3), true, true)
 			app.SetFocus(inputField)
 			return nil
@@ -1019,6 +1525,8 @@ func main() {
 			fen.InvalidateFolderFileCountCache()
 			fen.UpdatePanes(true)
 			app.Sync()
+			// Syncing clears the screen, so the image preview has to be written again after it
+			app.QueueUpdateDraw(fen.terminalImages.Hide)
 			fen.TriggerGitStatus()
 			return nil
 		} else if event.Rune() >= '0' && event.Rune() <= '9' {
@@ -1027,6 +1535,78 @@ func main() {
 				fen.bottomBar.TemporarilyShowTextInstead(err.Error())
 			}
 			return nil
+		} else if event.Rune() == 't' {
+			fen.NewTab()
+			return nil
+		} else if event.Rune() == 'T' {
+			err := fen.CloseTab()
+			if err != nil {
+				fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+			}
+			return nil
+		} else if event.Rune() == ']' {
+			fen.NextTab()
+			return nil
+		} else if event.Rune() == '[' {
+			fen.PreviousTab()
+			return nil
+		} else if event.Rune() == 'm' {
+			waitingForMarkLetter = true
+			fen.bottomBar.TemporarilyShowTextInstead("Press a letter to mark the current folder")
+			return nil
+		} else if event.Rune() == '\'' {
+			showMarksPicker()
+			return nil
+		} else if event.Rune() == 'w' {
+			fen.ToggleCommanderLayout()
+			return nil
+		} else if event.Key() == tcell.KeyTab {
+			if fen.config.Layout == LAYOUT_COMMANDER {
+				fen.SwitchCommanderSide()
+			}
+			return nil
+		} else if event.Rune() == 'C' || event.Rune() == 'X' {
+			move := event.Rune() == 'X'
+
+			label := " Copy to: "
+			if move {
+				label = " Move to: "
+			}
+
+			inputField := tview.NewInputField().
+				SetLabel(label).
+				SetText(PathWithEndSeparator(fen.DefaultDestinationFolder())).
+				SetFieldWidth(-1) // Special feature of my tview fork, github.com/kivattt/tview
+
+			inputField.SetBorder(true)
+			inputField.SetBorderStyle(tcell.StyleDefault.Background(tcell.ColorBlack))
+			inputField.SetTitleColor(tcell.ColorDefault)
+			inputField.SetFieldBackgroundColor(tcell.ColorGray)
+			inputField.SetFieldTextColor(tcell.ColorBlack)
+			inputField.SetBackgroundColor(tcell.ColorBlack)
+
+			inputField.SetLabelStyle(tcell.StyleDefault.Background(tcell.ColorBlack)) // This has to be before the .SetLabelColor
+			inputField.SetLabelColor(tcell.NewRGBColor(0, 255, 0))                    // Green
+
+			inputField.SetDoneFunc(func(key tcell.Key) {
+				pages.RemovePage("popup")
+				if key == tcell.KeyEscape {
+					return
+				}
+
+				count, err := fen.CopyOrMoveToFolder(inputField.GetText(), move)
+				if err != nil {
+					fen.bottomBar.TemporarilyShowTextInstead(err.Error())
+					return
+				}
+
+				fen.bottomBar.TemporarilyShowTextInstead(CopyOrMoveDoneText(count, move))
+				fen.UpdatePanes(false)
+			})
+
+			pages.AddPage("popup", centered(inputField, 3), true, true)
+			app.SetFocus(inputField)
+			return nil
 		} else if event.Modifiers()&tcell.ModCtrl != 0 && event.Key() == tcell.KeyRight { // Ctrl+Right
 			stat, err := os.Lstat(fen.sel)
 			if err == nil && stat.Mode()&os.ModeSymlink != 0 {
@@ -1155,6 +1735,7 @@ func main() {
 				command := inputField.GetText()
 				var err error
 				var exitCode int
+				fen.terminalImages.Hide()
 				app.Suspend(func() {
 					err = InvokeShell(command, fen.wd)
 
@@ -1269,6 +1850,15 @@ func main() {
 							fen.InvalidateFolderFileCountCache()
 							fen.UpdatePanes(true)
 						}
+					} else if fieldName == "global_selection" {
+						f = func(checked bool) {
+							*fieldPtr.(*bool) = checked
+							if checked {
+								fen.InitGlobalSelection()
+								fen.globalSelectionHandler.Apply()
+							}
+							fen.UpdatePanes(true)
+						}
 					} else if fieldName == "git_status" {
 						// Don't show the git_status option if it was disabled on startup, to prevent crashes
 						if !fen.initializedGitStatus {
@@ -1281,15 +1871,24 @@ func main() {
 
 					optionsForm.AddCheckbox(fieldName, fieldValue, f)
 				case reflect.String:
-					if fieldName != "sort_by" {
-						panic("Options menu expected the only config string to be sort_by")
-					}
-
 					fieldValue := value.String()
-					optionsForm.AddDropDown(fieldName, ValidSortByValues[:], slices.Index(ValidSortByValues[:], fieldValue), func(option string, optionIndex int) {
-						*fieldPtr.(*string) = option
-						fen.UpdatePanes(true)
-					})
+
+					if fieldName == "sort_by" {
+						optionsForm.AddDropDown(fieldName, ValidSortByValues[:], slices.Index(ValidSortByValues[:], fieldValue), func(option string, optionIndex int) {
+							*fieldPtr.(*string) = option
+							fen.UpdatePanes(true)
+						})
+					} else if fieldName == "layout" {
+						optionsForm.AddDropDown(fieldName, ValidLayoutValues[:], slices.Index(ValidLayoutValues[:], fieldValue), func(option string, optionIndex int) {
+							// The dropdown calls this when it is created, don't open a new tab for nothing
+							if option == fen.config.Layout {
+								return
+							}
+							fen.SetLayout(option)
+						})
+					} else {
+						panic("Options menu got an unexpected config string " + fieldName)
+					}
 				default:
 					continue
 				}
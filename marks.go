@@ -0,0 +1,197 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Marks are like the bookmarks in config.lua, but set while fen is running with m<letter> and persisted in FenDataFolder()
+const marksFilename = "marks.json"
+
+type Mark struct {
+	Path string `json:"path"`
+	Name string `json:"name,omitempty"` // Optional, set in the marks picker
+}
+
+func IsValidMarkLetter(letter rune) bool {
+	return (letter >= 'a' && letter <= 'z') || (letter >= 'A' && letter <= 'Z')
+}
+
+// Returns an empty map if the file doesn't exist
+func ReadMarksFile(path string) (map[rune]Mark, error) {
+	marks := make(map[rune]Mark)
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return marks, nil
+		}
+		return marks, err
+	}
+
+	// JSON object keys have to be strings
+	var marksByString map[string]Mark
+	err = json.Unmarshal(bytes, &marksByString)
+	if err != nil {
+		return marks, err
+	}
+
+	for letterString, mark := range marksByString {
+		letters := []rune(letterString)
+		if len(letters) != 1 || !IsValidMarkLetter(letters[0]) || mark.Path == "" {
+			continue
+		}
+
+		marks[letters[0]] = mark
+	}
+
+	return marks, nil
+}
+
+func WriteMarksFile(path string, marks map[rune]Mark) error {
+	marksByString := make(map[string]Mark)
+	for letter, mark := range marks {
+		marksByString[string(letter)] = mark
+	}
+
+	bytes, err := json.MarshalIndent(marksByString, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(path, bytes, 0o664)
+}
+
+func marksFilePath() (string, error) {
+	dataFolder, err := FenDataFolder()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataFolder, marksFilename), nil
+}
+
+// Errors are ignored, the marks will simply be empty
+func (fen *Fen) LoadMarks() {
+	fen.marks = make(map[rune]Mark)
+
+	path, err := marksFilePath()
+	if err != nil {
+		return
+	}
+
+	marks, err := ReadMarksFile(path)
+	if err != nil {
+		return
+	}
+
+	fen.marks = marks
+}
+
+// Re-reads the marks file before calling change, so marks set in other fen instances aren't overwritten.
+// In no-write mode, only fen.marks is changed
+func (fen *Fen) changeMarks(change func(marks map[rune]Mark)) error {
+	if fen.config.NoWrite {
+		change(fen.marks)
+		return nil
+	}
+
+	path, err := marksFilePath()
+	if err != nil {
+		change(fen.marks)
+		return err
+	}
+
+	unlock, err := LockFile(path)
+	if err != nil {
+		change(fen.marks)
+		return err
+	}
+	defer unlock()
+
+	marks, err := ReadMarksFile(path)
+	if err != nil {
+		// Don't overwrite a marks file we can't understand
+		change(fen.marks)
+		return errors.New("Unable to read marks file: " + err.Error())
+	}
+
+	change(marks)
+	fen.marks = marks
+	return WriteMarksFile(path, marks)
+}
+
+// Marks the current folder
+func (fen *Fen) SetMark(letter rune) error {
+	if !IsValidMarkLetter(letter) {
+		return errors.New("Invalid mark \"" + string(letter) + "\", only a-z and A-Z are allowed")
+	}
+
+	path := fen.wd
+	return fen.changeMarks(func(marks map[rune]Mark) {
+		marks[letter] = Mark{Path: path}
+	})
+}
+
+func (fen *Fen) DeleteMark(letter rune) error {
+	return fen.changeMarks(func(marks map[rune]Mark) {
+		delete(marks, letter)
+	})
+}
+
+// An empty name shows the path instead
+func (fen *Fen) RenameMark(letter rune, name string) error {
+	return fen.changeMarks(func(marks map[rune]Mark) {
+		mark, ok := marks[letter]
+		if !ok {
+			return
+		}
+
+		mark.Name = name
+		marks[letter] = mark
+	})
+}
+
+func (fen *Fen) GoMark(letter rune) error {
+	mark, ok := fen.marks[letter]
+	if !ok {
+		return errors.New("No mark set for \"" + string(letter) + "\"")
+	}
+
+	pathMovedTo, err := fen.GoPath(mark.Path)
+	if err != nil {
+		return err
+	}
+
+	fen.DisableSelectingWithV()
+
+	fen.bottomBar.TemporarilyShowTextInstead("Moved to mark " + string(letter) + ": \"" + pathMovedTo + "\"")
+	return nil
+}
+
+// Lowercase letters first, then uppercase
+func (fen *Fen) MarkLettersSorted() []rune {
+	letters := make([]rune, 0, len(fen.marks))
+	for letter := range fen.marks {
+		letters = append(letters, letter)
+	}
+
+	slices.SortFunc(letters, func(a, b rune) int {
+		aIsUpper, bIsUpper := a <= 'Z', b <= 'Z'
+		if aIsUpper != bIsUpper {
+			if aIsUpper {
+				return 1
+			}
+			return -1
+		}
+
+		return int(a) - int(b)
+	})
+
+	return letters
+}
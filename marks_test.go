@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarksFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marks.json")
+
+	marks, err := ReadMarksFile(path)
+	if err != nil || len(marks) != 0 {
+		t.Fatal("Expected no marks and no error for a file that doesn't exist")
+	}
+
+	marks['a'] = Mark{Path: "/home/user"}
+	marks['Z'] = Mark{Path: "/mnt/something", Name: "Something"}
+	err = WriteMarksFile(path, marks)
+	if err != nil {
+		t.Fatal("Failed to write marks file: " + err.Error())
+	}
+
+	readMarks, err := ReadMarksFile(path)
+	if err != nil {
+		t.Fatal("Failed to read marks file: " + err.Error())
+	}
+
+	if len(readMarks) != 2 || readMarks['a'] != marks['a'] || readMarks['Z'] != marks['Z'] {
+		t.Fatal("Marks read from file did not match the marks written")
+	}
+
+	// Invalid letters and empty paths should be ignored
+	err = os.WriteFile(path, []byte(`{"a": {"path": "/home"}, "1": {"path": "/tmp"}, "ab": {"path": "/tmp"}, "b": {"path": ""}}`), 0o664)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readMarks, err = ReadMarksFile(path)
+	if err != nil {
+		t.Fatal("Failed to read marks file: " + err.Error())
+	}
+
+	if len(readMarks) != 1 || readMarks['a'].Path != "/home" {
+		t.Fatal("Expected only the mark a to be read")
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matroska (MKV) and WebM files are made of EBML elements: an ID, a size and the data, which can be more elements.
+// The duration and the tracks are in the Segment element, usually before the first Cluster of media data
+
+// The element IDs we read, https://www.matroska.org/technical/elements.html
+const (
+	MATROSKA_EBML               = 0x1a45dfa3
+	MATROSKA_DOC_TYPE           = 0x4282
+	MATROSKA_SEGMENT            = 0x18538067
+	MATROSKA_INFO               = 0x1549a966
+	MATROSKA_TIMESTAMP_SCALE    = 0x2ad7b1
+	MATROSKA_DURATION           = 0x4489
+	MATROSKA_TITLE              = 0x7ba9
+	MATROSKA_DATE_UTC           = 0x4461
+	MATROSKA_TRACKS             = 0x1654ae6b
+	MATROSKA_TRACK_ENTRY        = 0xae
+	MATROSKA_TRACK_TYPE         = 0x83
+	MATROSKA_CODEC_ID           = 0x86
+	MATROSKA_LANGUAGE           = 0x22b59c
+	MATROSKA_NAME               = 0x536e
+	MATROSKA_DEFAULT_DURATION   = 0x23e383
+	MATROSKA_VIDEO              = 0xe0
+	MATROSKA_PIXEL_WIDTH        = 0xb0
+	MATROSKA_PIXEL_HEIGHT       = 0xba
+	MATROSKA_AUDIO              = 0xe1
+	MATROSKA_SAMPLING_FREQUENCY = 0xb5
+	MATROSKA_CHANNELS           = 0x9f
+	MATROSKA_CLUSTER            = 0x1f43b675 // Media data, skipped over
+)
+
+// Larger Info and Tracks elements aren't read
+const maxMatroskaElementBytes = 16 * 1024 * 1024
+
+// The size of an element that lasts until the end of its parent, used by files that are still being written
+const matroskaUnknownSize = math.MaxUint64
+
+var matroskaCodecNames = map[string]string{
+	"V_MPEG4/ISO/AVC":  "H.264",
+	"V_MPEGH/ISO/HEVC": "H.265",
+	"V_AV1":            "AV1",
+	"V_VP8":            "VP8",
+	"V_VP9":            "VP9",
+	"V_THEORA":         "Theora",
+	"A_AAC":            "AAC",
+	"A_OPUS":           "Opus",
+	"A_VORBIS":         "Vorbis",
+	"A_FLAC":           "FLAC",
+	"A_AC3":            "AC-3",
+	"A_EAC3":           "E-AC-3",
+	"A_DTS":            "DTS",
+	"A_TRUEHD":         "TrueHD",
+	"A_MPEG/L3":        "MP3",
+	"A_PCM/INT/LIT":    "PCM",
+	"S_TEXT/UTF8":      "SRT",
+	"S_TEXT/ASS":       "ASS",
+	"S_TEXT/SSA":       "SSA",
+	"S_TEXT/WEBVTT":    "WebVTT",
+	"S_HDMV/PGS":       "PGS",
+	"S_VOBSUB":         "VobSub",
+}
+
+// Where Matroska dates count from
+var matroskaEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type ebmlElement struct {
+	id   uint32
+	data []byte
+}
+
+// Returns a variable length integer, and how many bytes it was. The length marker bits are kept for IDs
+func parseEBMLVint(data []byte, isID bool) (uint64, int, error) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, errors.New("Invalid EBML variable length integer")
+	}
+
+	length := 1
+	for data[0]&(0x80>>(length-1)) == 0 {
+		length++
+	}
+	if length > len(data) || (isID && length > 4) {
+		return 0, 0, errors.New("Invalid EBML variable length integer")
+	}
+
+	value := uint64(data[0])
+	if !isID {
+		value &= 0xff >> length
+	}
+	allOnes := value == 0xff>>length
+	for _, b := range data[1:length] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xff
+	}
+
+	if !isID && allOnes {
+		return matroskaUnknownSize, length, nil
+	}
+	return value, length, nil
+}
+
+// Reads the ID and size of the next element in file
+func readEBMLHeader(file io.Reader) (uint32, uint64, error) {
+	var values [2]uint64
+	for i := range values {
+		first := make([]byte, 1)
+		_, err := io.ReadFull(file, first)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		data := make([]byte, 8)
+		data[0] = first[0]
+		length := 1
+		for length <= 8 && first[0]&(0x80>>(length-1)) == 0 {
+			length++
+		}
+		if length > 8 {
+			return 0, 0, errors.New("Invalid EBML variable length integer")
+		}
+		_, err = io.ReadFull(file, data[1:length])
+		if err != nil {
+			return 0, 0, err
+		}
+
+		values[i], _, err = parseEBMLVint(data[:length], i == 0)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return uint32(values[0]), values[1], nil
+}
+
+// Splits data into the elements in it
+func ebmlElements(data []byte) []ebmlElement {
+	var elements []ebmlElement
+	for len(data) > 0 {
+		id, idLength, err := parseEBMLVint(data, true)
+		if err != nil {
+			return elements
+		}
+		size, sizeLength, err := parseEBMLVint(data[idLength:], false)
+		if err != nil {
+			return elements
+		}
+
+		start := uint64(idLength + sizeLength)
+		if size == matroskaUnknownSize || size > uint64(len(data))-start {
+			size = uint64(len(data)) - start
+		}
+		elements = append(elements, ebmlElement{id: uint32(id), data: data[start : start+size]})
+		data = data[start+size:]
+	}
+	return elements
+}
+
+func ebmlUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+func ebmlString(data []byte) string {
+	return strings.ToValidUTF8(strings.TrimRight(string(data), "\x00"), "")
+}
+
+func MatroskaInfo(file io.ReadSeeker, formatName string) (MediaInfo, error) {
+	id, size, err := readEBMLHeader(file)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	if id != MATROSKA_EBML || size > 4096 {
+		return MediaInfo{}, errors.New("Invalid EBML header")
+	}
+	header := make([]byte, size)
+	_, err = io.ReadFull(file, header)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	for _, element := range ebmlElements(header) {
+		if element.id == MATROSKA_DOC_TYPE && ebmlString(element.data) == "webm" {
+			formatName = "WebM"
+		}
+	}
+
+	id, _, err = readEBMLHeader(file)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	if id != MATROSKA_SEGMENT {
+		return MediaInfo{}, errors.New("No Matroska Segment element")
+	}
+
+	// The elements in the Segment, skipping over the ones we don't need
+	var info, tracks []ebmlElement
+	for i := 0; i < 1000 && (info == nil || tracks == nil); i++ {
+		id, size, err = readEBMLHeader(file)
+		if err != nil || size == matroskaUnknownSize {
+			break
+		}
+
+		switch id {
+		case MATROSKA_INFO, MATROSKA_TRACKS:
+			if size > maxMatroskaElementBytes {
+				return MediaInfo{}, errors.New("Matroska element too large")
+			}
+			data := make([]byte, size)
+			_, err = io.ReadFull(file, data)
+			if err != nil {
+				return MediaInfo{}, err
+			}
+			if id == MATROSKA_INFO {
+				info = ebmlElements(data)
+			} else {
+				tracks = ebmlElements(data)
+			}
+		default:
+			_, err = file.Seek(int64(size), io.SeekCurrent)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if info == nil && tracks == nil {
+		return MediaInfo{}, errors.New("No Matroska Info or Tracks element")
+	}
+
+	var details [][2]string
+	timestampScale := uint64(1_000_000) // In nanoseconds
+	var duration float64
+	for _, element := range info {
+		switch element.id {
+		case MATROSKA_TIMESTAMP_SCALE:
+			timestampScale = ebmlUint(element.data)
+		case MATROSKA_DURATION:
+			duration = ebmlFloat(element.data)
+		case MATROSKA_TITLE:
+			details = append(details, [2]string{"Title", ebmlString(element.data)})
+		case MATROSKA_DATE_UTC:
+			if len(element.data) == 8 {
+				date := matroskaEpoch.Add(time.Duration(int64(binary.BigEndian.Uint64(element.data))))
+				details = append(details, [2]string{"Created", date.Format(time.DateTime)})
+			}
+		}
+	}
+
+	var streams []string
+	hasVideo := false
+	for _, track := range tracks {
+		if track.id != MATROSKA_TRACK_ENTRY {
+			continue
+		}
+
+		stream, isVideo := matroskaStream(ebmlElements(track.data))
+		if stream != "" {
+			streams = append(streams, stream)
+		}
+		hasVideo = hasVideo || isVideo
+	}
+
+	kind := "audio"
+	if hasVideo {
+		kind = "video"
+	}
+	durationText := ""
+	if duration > 0 {
+		durationText = MediaDurationString(duration * float64(timestampScale) / 1e9)
+	}
+
+	return MediaInfo{
+		Summary: joinNonEmpty(formatName+" "+kind, durationText),
+		Details: details,
+		Streams: streams,
+	}, nil
+}
+
+// Like "Video: H.264, 1920x1080, 23.976 fps", and whether it's a video track
+func matroskaStream(entry []ebmlElement) (string, bool) {
+	var trackType uint64
+	var codec, name, size, frameRate, sampleRate, channels string
+	language := "eng" // The default when there is none
+	for _, element := range entry {
+		switch element.id {
+		case MATROSKA_TRACK_TYPE:
+			trackType = ebmlUint(element.data)
+		case MATROSKA_CODEC_ID:
+			codec = ebmlString(element.data)
+			if codecName, ok := matroskaCodecNames[codec]; ok {
+				codec = codecName
+			}
+		case MATROSKA_LANGUAGE:
+			language = ebmlString(element.data)
+		case MATROSKA_NAME:
+			name = ebmlString(element.data)
+		case MATROSKA_DEFAULT_DURATION:
+			if nanoseconds := ebmlUint(element.data); nanoseconds > 0 {
+				frameRate = FrameRateString(1e9 / float64(nanoseconds))
+			}
+		case MATROSKA_VIDEO:
+			var width, height uint64
+			for _, videoElement := range ebmlElements(element.data) {
+				switch videoElement.id {
+				case MATROSKA_PIXEL_WIDTH:
+					width = ebmlUint(videoElement.data)
+				case MATROSKA_PIXEL_HEIGHT:
+					height = ebmlUint(videoElement.data)
+				}
+			}
+			if width > 0 && height > 0 {
+				size = strconv.FormatUint(width, 10) + "x" + strconv.FormatUint(height, 10)
+			}
+		case MATROSKA_AUDIO:
+			for _, audioElement := range ebmlElements(element.data) {
+				switch audioElement.id {
+				case MATROSKA_SAMPLING_FREQUENCY:
+					sampleRate = SampleRateString(int(ebmlFloat(audioElement.data)))
+				case MATROSKA_CHANNELS:
+					channels = ChannelsString(int(ebmlUint(audioElement.data)))
+				}
+			}
+		}
+	}
+
+	if language == "und" {
+		language = ""
+	}
+
+	switch trackType {
+	case 1:
+		return "Video: " + joinNonEmpty(codec, size, frameRate, language, name), true
+	case 2:
+		return "Audio: " + joinNonEmpty(codec, sampleRate, channels, language, name), false
+	case 17:
+		return "Subtitles: " + joinNonEmpty(codec, language, name), false
+	}
+	return "", false
+}
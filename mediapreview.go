@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Audio and video files in audioTypes and videoTypes are shown in the built-in preview with their duration, tags and streams.
+// Everything is parsed by fen, see audiometadata.go, mp4.go and matroska.go. Photos get their EXIF metadata below the image instead, see exif.go
+
+// What the preview of an audio or video file shows
+type MediaInfo struct {
+	Summary string // Like "MP3, 3:45, 320 kbps, 44.1 kHz, stereo"
+	Details [][2]string
+	Streams []string // Like "Video: H.264, 1920x1080, 30 fps"
+}
+
+// The tags shown for audio and video files, in this order
+var mediaTagLabels = []string{"Title", "Artist", "Album", "Album artist", "Track", "Date", "Genre"}
+
+func IsMediaFile(path string) bool {
+	lowercasePath := strings.ToLower(path)
+	for _, types := range [][]string{audioTypes, videoTypes} {
+		for _, extension := range types {
+			if strings.HasSuffix(lowercasePath, extension) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns false if the file couldn't be read, so it can be previewed as a binary file instead
+func (job PreviewJob) mediaPreview(file *os.File, fileSize int64) (*PreviewResult, bool) {
+	info, err := MediaInfoOf(file, fileSize, job.Path)
+	if err != nil {
+		return nil, false
+	}
+	return NewLinesPreviewResult(InfoLines(info.Summary, info.Details, "Streams", info.Streams), false), true
+}
+
+// Recognizes the format by the start of file, path is only used for the name of the format
+func MediaInfoOf(file io.ReadSeeker, fileSize int64, path string) (MediaInfo, error) {
+	head := make([]byte, 12)
+	_, err := io.ReadFull(file, head)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	formatName := strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), "."))
+
+	switch {
+	case string(head[:3]) == "ID3" || (head[0] == 0xff && head[1]&0xe0 == 0xe0):
+		return MP3Info(file, fileSize)
+	case string(head[:4]) == "fLaC":
+		return FLACInfo(file)
+	case string(head[:4]) == "OggS":
+		return OggInfo(file, fileSize)
+	case string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return WAVInfo(file)
+	case string(head[4:8]) == "ftyp":
+		return MP4Info(file, fileSize, formatName)
+	case string(head[:4]) == "\x1a\x45\xdf\xa3":
+		return MatroskaInfo(file, formatName)
+	}
+
+	return MediaInfo{}, errors.New("Unknown media format")
+}
+
+// Like "3:45" or "1:02:03"
+func MediaDurationString(seconds float64) string {
+	total := int(math.Round(seconds))
+	hours, minutes, secs := total/3600, total/60%60, total%60
+
+	text := strconv.Itoa(minutes) + ":" + twoDigits(secs)
+	if hours > 0 {
+		text = strconv.Itoa(hours) + ":" + twoDigits(minutes) + ":" + twoDigits(secs)
+	}
+	return text
+}
+
+func twoDigits(number int) string {
+	if number < 10 {
+		return "0" + strconv.Itoa(number)
+	}
+	return strconv.Itoa(number)
+}
+
+// Like "44.1 kHz"
+func SampleRateString(hertz int) string {
+	return strconv.FormatFloat(float64(hertz)/1000, 'f', -1, 64) + " kHz"
+}
+
+// Like "stereo" or "6 channels"
+func ChannelsString(channels int) string {
+	switch channels {
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	}
+	return strconv.Itoa(channels) + " channels"
+}
+
+// Like "23.976 fps"
+func FrameRateString(framesPerSecond float64) string {
+	return strconv.FormatFloat(math.Round(framesPerSecond*1000)/1000, 'f', -1, 64) + " fps"
+}
+
+// Joins the parts that aren't empty with ", "
+func joinNonEmpty(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+// Tags by their label in mediaTagLabels, as details in the order of mediaTagLabels
+func mediaTagDetails(tags map[string]string) [][2]string {
+	var details [][2]string
+	for _, label := range mediaTagLabels {
+		if value := strings.TrimSpace(tags[label]); value != "" {
+			details = append(details, [2]string{label, value})
+		}
+	}
+	return details
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMediaDurationString(t *testing.T) {
+	tests := map[float64]string{
+		0:       "0:00",
+		9.6:     "0:10",
+		225:     "3:45",
+		3723:    "1:02:03",
+		36000.4: "10:00:00",
+	}
+
+	for seconds, expected := range tests {
+		if got := MediaDurationString(seconds); got != expected {
+			t.Fatal("Expected \"" + expected + "\" but got \"" + got + "\" for " + strconv.FormatFloat(seconds, 'f', -1, 64) + " seconds")
+		}
+	}
+}
+
+func checkMediaInfo(t *testing.T, info MediaInfo, summary string, details [][2]string, streams []string) {
+	if info.Summary != summary {
+		t.Fatal("Expected summary \"" + summary + "\" but got \"" + info.Summary + "\"")
+	}
+	if len(info.Details) != len(details) {
+		t.Fatal("Expected " + strconv.Itoa(len(details)) + " details but got " + strconv.Itoa(len(info.Details)))
+	}
+	for i, detail := range details {
+		if info.Details[i] != detail {
+			t.Fatal("Expected detail \"" + detail[0] + ": " + detail[1] + "\" but got \"" + info.Details[i][0] + ": " + info.Details[i][1] + "\"")
+		}
+	}
+	if strings.Join(info.Streams, "\n") != strings.Join(streams, "\n") {
+		t.Fatal("Expected streams \"" + strings.Join(streams, "; ") + "\" but got \"" + strings.Join(info.Streams, "; ") + "\"")
+	}
+}
+
+func TestMediaInfoOfWAV(t *testing.T) {
+	le := binary.LittleEndian
+	var wav []byte
+	chunk := func(name string, data []byte) {
+		wav = append(wav, name...)
+		wav = le.AppendUint32(wav, uint32(len(data)))
+		wav = append(wav, data...)
+		if len(data)%2 == 1 {
+			wav = append(wav, 0)
+		}
+	}
+
+	fmtChunk := le.AppendUint16(nil, 1)             // PCM
+	fmtChunk = le.AppendUint16(fmtChunk, 2)         // Channels
+	fmtChunk = le.AppendUint32(fmtChunk, 44100)     // Sample rate
+	fmtChunk = le.AppendUint32(fmtChunk, 44100*2*2) // Byte rate
+	fmtChunk = le.AppendUint16(fmtChunk, 4)         // Block align
+	fmtChunk = le.AppendUint16(fmtChunk, 16)        // Bits per sample
+	chunk("fmt ", fmtChunk)
+
+	info := []byte("INFO")
+	info = append(info, "INAM"...)
+	info = le.AppendUint32(info, 5)
+	info = append(info, "Song\x00\x00"...)
+	chunk("LIST", info)
+	chunk("data", make([]byte, 44100*2*2*3))
+
+	wav = append([]byte("RIFF\x00\x00\x00\x00WAVE"), wav...)
+
+	got, err := MediaInfoOf(bytes.NewReader(wav), int64(len(wav)), "song.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMediaInfo(t, got, "WAV, 0:03, 44.1 kHz, 16-bit, stereo", [][2]string{{"Title", "Song"}}, nil)
+
+	// A fmt chunk claiming to be 4 GB, detected by its first bytes without a .wav extension
+	huge := []byte("RIFF\x14\x00\x00\x00WAVEfmt \xff\xff\xff\xff\x01\x00\x02\x00\x44\xac\x00\x00")
+	if len(huge) != 28 {
+		t.Fatal("Expected the test file to be 28 bytes, but got " + strconv.Itoa(len(huge)))
+	}
+	_, err = MediaInfoOf(bytes.NewReader(huge), int64(len(huge)), "file")
+	if err == nil {
+		t.Fatal("Expected an error for a WAV file with a huge fmt chunk")
+	}
+}
+
+// An EBML element with a 1-byte size, or an 8-byte one for larger data
+func ebmlTestElement(id uint32, data ...[]byte) []byte {
+	element := binary.BigEndian.AppendUint32(nil, id)
+	element = bytes.TrimLeft(element, "\x00")
+
+	content := bytes.Join(data, nil)
+	if len(content) < 0x7f {
+		element = append(element, 0x80|byte(len(content)))
+	} else {
+		element = append(element, 0x01)
+		element = append(element, binary.BigEndian.AppendUint64(nil, uint64(len(content)))[1:]...)
+	}
+	return append(element, content...)
+}
+
+func TestMatroskaInfo(t *testing.T) {
+	uint8Data := func(value byte) []byte { return []byte{value} }
+	float64Data := func(value float64) []byte { return binary.BigEndian.AppendUint64(nil, math.Float64bits(value)) }
+
+	mkv := bytes.Join([][]byte{
+		ebmlTestElement(MATROSKA_EBML, ebmlTestElement(MATROSKA_DOC_TYPE, []byte("webm"))),
+		ebmlTestElement(MATROSKA_SEGMENT,
+			ebmlTestElement(MATROSKA_CLUSTER, make([]byte, 200)),
+			ebmlTestElement(MATROSKA_INFO,
+				ebmlTestElement(MATROSKA_DURATION, float64Data(125_500)),
+				ebmlTestElement(MATROSKA_TITLE, []byte("A video")),
+			),
+			ebmlTestElement(MATROSKA_TRACKS,
+				ebmlTestElement(MATROSKA_TRACK_ENTRY,
+					ebmlTestElement(MATROSKA_TRACK_TYPE, uint8Data(1)),
+					ebmlTestElement(MATROSKA_CODEC_ID, []byte("V_VP9")),
+					ebmlTestElement(MATROSKA_DEFAULT_DURATION, binary.BigEndian.AppendUint32(nil, 40_000_000)),
+					ebmlTestElement(MATROSKA_VIDEO,
+						ebmlTestElement(MATROSKA_PIXEL_WIDTH, binary.BigEndian.AppendUint16(nil, 1280)),
+						ebmlTestElement(MATROSKA_PIXEL_HEIGHT, binary.BigEndian.AppendUint16(nil, 720)),
+					),
+				),
+				ebmlTestElement(MATROSKA_TRACK_ENTRY,
+					ebmlTestElement(MATROSKA_TRACK_TYPE, uint8Data(2)),
+					ebmlTestElement(MATROSKA_CODEC_ID, []byte("A_OPUS")),
+					ebmlTestElement(MATROSKA_LANGUAGE, []byte("nor")),
+					ebmlTestElement(MATROSKA_AUDIO,
+						ebmlTestElement(MATROSKA_SAMPLING_FREQUENCY, float64Data(48000)),
+						ebmlTestElement(MATROSKA_CHANNELS, uint8Data(2)),
+					),
+				),
+				ebmlTestElement(MATROSKA_TRACK_ENTRY,
+					ebmlTestElement(MATROSKA_TRACK_TYPE, uint8Data(17)),
+					ebmlTestElement(MATROSKA_CODEC_ID, []byte("S_TEXT/WEBVTT")),
+					ebmlTestElement(MATROSKA_LANGUAGE, []byte("und")),
+					ebmlTestElement(MATROSKA_NAME, []byte("Commentary")),
+				),
+			),
+		),
+	}, nil)
+
+	got, err := MediaInfoOf(bytes.NewReader(mkv), int64(len(mkv)), "video.webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMediaInfo(t, got, "WebM video, 2:06", [][2]string{{"Title", "A video"}}, []string{
+		"Video: VP9, 1280x720, 25 fps, eng",
+		"Audio: Opus, 48 kHz, stereo, nor",
+		"Subtitles: WebVTT, Commentary",
+	})
+}
+
+func TestMP4Info(t *testing.T) {
+	be := binary.BigEndian
+	box := func(name string, data ...[]byte) []byte {
+		content := bytes.Join(data, nil)
+		return append(be.AppendUint32([]byte(nil), uint32(8+len(content))), append([]byte(name), content...)...)
+	}
+
+	// Version 0 time headers: version and flags, creation time, modification time, timescale and duration
+	timeHeader := func(timescale, duration uint32, language uint16) []byte {
+		data := make([]byte, 12)
+		data = be.AppendUint32(data, timescale)
+		data = be.AppendUint32(data, duration)
+		return be.AppendUint16(data, language)
+	}
+	handler := func(kind string) []byte { return append(make([]byte, 8), kind+"\x00\x00\x00\x00"...) }
+
+	// Sample entries start with 6 reserved bytes and a data reference index
+	videoEntry := make([]byte, 24)
+	videoEntry = be.AppendUint16(videoEntry, 1920)
+	videoEntry = be.AppendUint16(videoEntry, 1080)
+	audioEntry := make([]byte, 16)
+	audioEntry = be.AppendUint16(audioEntry, 2)
+	audioEntry = append(audioEntry, make([]byte, 6)...)
+	audioEntry = be.AppendUint32(audioEntry, 44100<<16)
+
+	// Version, flags and the amount of entries before the entry
+	sampleDescription := func(codec string, entry []byte) []byte {
+		return append(be.AppendUint32(make([]byte, 4), 1), box(codec, entry)...)
+	}
+
+	// 300 frames over 10 seconds
+	stts := be.AppendUint32(make([]byte, 4), 1)
+	stts = be.AppendUint32(stts, 300)
+	stts = be.AppendUint32(stts, 1000)
+
+	// "eng" as three 5-bit letters
+	eng := uint16('e'-0x60)<<10 | uint16('n'-0x60)<<5 | uint16('g'-0x60)
+
+	titleData := append(make([]byte, 8), "A movie"...)
+
+	mp4 := bytes.Join([][]byte{
+		box("ftyp", []byte("isom\x00\x00\x02\x00")),
+		box("mdat", make([]byte, 100)),
+		box("moov",
+			box("mvhd", timeHeader(1000, 10_000, 0)),
+			box("trak",
+				box("mdia",
+					box("mdhd", timeHeader(30_000, 300_000, eng)),
+					box("hdlr", handler("vide")),
+					box("minf", box("stbl", box("stsd", sampleDescription("avc1", videoEntry)), box("stts", stts))),
+				),
+			),
+			box("trak",
+				box("mdia",
+					box("mdhd", timeHeader(44100, 441_000, 0)),
+					box("hdlr", handler("soun")),
+					box("minf", box("stbl", box("stsd", sampleDescription("mp4a", audioEntry)))),
+				),
+			),
+			box("udta", box("meta", make([]byte, 4), box("ilst", box("\xa9nam", box("data", titleData))))),
+		),
+	}, nil)
+
+	got, err := MediaInfoOf(bytes.NewReader(mp4), int64(len(mp4)), "movie.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMediaInfo(t, got, "MP4 video, 0:10", [][2]string{{"Title", "A movie"}}, []string{
+		"Video: H.264, 1920x1080, 30 fps, eng",
+		"Audio: AAC, 44.1 kHz, stereo",
+	})
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MP4, MOV and M4A files are made of boxes with a size and a name, some of which contain more boxes.
+// Everything we show is in the "moov" box, which can be before or after the media data ("mdat")
+
+// Larger moov boxes aren't read, they are usually a few megabytes for hours of video
+const maxMP4MoovBytes = 64 * 1024 * 1024
+
+// The boxes in moov we look inside of
+var mp4ContainerBoxes = []string{"moov", "trak", "mdia", "minf", "stbl", "udta", "meta", "ilst"}
+
+// iTunes-style tags in udta/meta/ilst, the "©" is the byte 0xa9
+var mp4TagLabels = map[string]string{
+	"\xa9nam": "Title",
+	"\xa9ART": "Artist",
+	"\xa9alb": "Album",
+	"aART":    "Album artist",
+	"trkn":    "Track",
+	"\xa9day": "Date",
+	"\xa9gen": "Genre",
+}
+
+// By the name of the first entry in the stsd box
+var mp4CodecNames = map[string]string{
+	"avc1": "H.264",
+	"avc3": "H.264",
+	"hvc1": "H.265",
+	"hev1": "H.265",
+	"av01": "AV1",
+	"vp09": "VP9",
+	"mp4v": "MPEG-4",
+	"apcn": "ProRes",
+	"apch": "ProRes",
+	"mp4a": "AAC",
+	"ac-3": "AC-3",
+	"ec-3": "E-AC-3",
+	"Opus": "Opus",
+	"fLaC": "FLAC",
+	"alac": "ALAC",
+	".mp3": "MP3",
+	"tx3g": "Timed text",
+	"wvtt": "WebVTT",
+	"c608": "CEA-608",
+}
+
+// Where MP4 times count from
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type mp4Box struct {
+	name string
+	data []byte // Without the size and name
+}
+
+type mp4Track struct {
+	handler    string // Like "vide" or "soun"
+	codec      string
+	language   string
+	width      int
+	height     int
+	channels   int
+	sampleRate int
+	timescale  uint32
+	duration   uint64
+	samples    uint64
+}
+
+// Splits data into the boxes in it
+func mp4Boxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		name := string(data[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0: // Until the end
+			size = uint64(len(data))
+		case 1: // A 64-bit size after the name
+			if len(data) < 16 {
+				return boxes
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return boxes
+		}
+
+		boxes = append(boxes, mp4Box{name: name, data: data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+// Skips over the boxes before the moov box, like the media data
+func readMP4Moov(file io.ReadSeeker, fileSize int64) ([]byte, error) {
+	header := make([]byte, 16)
+	var position int64
+	for position+8 <= fileSize {
+		_, err := file.Seek(position, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.ReadFull(file, header[:8])
+		if err != nil {
+			return nil, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		name := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = fileSize - position
+		case 1:
+			_, err = io.ReadFull(file, header[8:16])
+			if err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return nil, errors.New("Invalid MP4 box size")
+		}
+
+		if name == "moov" {
+			if size-headerSize > maxMP4MoovBytes {
+				return nil, errors.New("MP4 moov box too large")
+			}
+			moov := make([]byte, size-headerSize)
+			_, err = io.ReadFull(file, moov)
+			return moov, err
+		}
+
+		position += size
+	}
+	return nil, errors.New("No MP4 moov box")
+}
+
+func MP4Info(file io.ReadSeeker, fileSize int64, formatName string) (MediaInfo, error) {
+	moov, err := readMP4Moov(file, fileSize)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	var timescale uint32
+	var duration uint64
+	var created time.Time
+	var tracks []mp4Track
+	tags := make(map[string]string)
+
+	var walk func(boxes []mp4Box, track *mp4Track)
+	walk = func(boxes []mp4Box, track *mp4Track) {
+		for _, box := range boxes {
+			switch box.name {
+			case "mvhd":
+				var creationTime uint64
+				creationTime, timescale, duration = mp4TimeHeader(box.data)
+				if creationTime > 0 {
+					created = mp4Epoch.Add(time.Duration(creationTime) * time.Second)
+				}
+			case "trak":
+				tracks = append(tracks, mp4Track{})
+				walk(mp4Boxes(box.data), &tracks[len(tracks)-1])
+			case "tkhd":
+				// The width and height are 16.16 fixed point numbers at the end
+				if track != nil && len(box.data) >= 8 {
+					track.width = int(binary.BigEndian.Uint32(box.data[len(box.data)-8:]) >> 16)
+					track.height = int(binary.BigEndian.Uint32(box.data[len(box.data)-4:]) >> 16)
+				}
+			case "mdhd":
+				if track != nil {
+					_, track.timescale, track.duration = mp4TimeHeader(box.data)
+					track.language = mp4Language(box.data)
+				}
+			case "hdlr":
+				if track != nil && len(box.data) >= 12 {
+					track.handler = string(box.data[8:12])
+				}
+			case "stsd":
+				if track != nil {
+					readMP4SampleDescription(box.data, track)
+				}
+			case "stts":
+				if track != nil {
+					track.samples = mp4SampleCount(box.data)
+				}
+			case "meta":
+				// A full box with a version and flags before the boxes in it, except in some QuickTime files
+				data := box.data
+				if len(data) >= 8 && string(data[4:8]) != "hdlr" {
+					data = data[4:]
+				}
+				walk(mp4Boxes(data), track)
+			default:
+				if label, ok := mp4TagLabels[box.name]; ok {
+					tags[label] = mp4TagValue(box.name, box.data)
+				} else if slices.Contains(mp4ContainerBoxes, box.name) {
+					walk(mp4Boxes(box.data), track)
+				}
+			}
+		}
+	}
+	walk(mp4Boxes(moov), nil)
+
+	var streams []string
+	hasVideo := false
+	for _, track := range tracks {
+		codec, ok := mp4CodecNames[track.codec]
+		if !ok {
+			codec = strings.TrimSpace(track.codec)
+		}
+
+		language := track.language
+		if language == "und" {
+			language = ""
+		}
+
+		switch track.handler {
+		case "vide":
+			hasVideo = true
+			size := ""
+			if track.width > 0 && track.height > 0 {
+				size = strconv.Itoa(track.width) + "x" + strconv.Itoa(track.height)
+			}
+			frameRate := ""
+			if track.samples > 0 && track.duration > 0 && track.timescale > 0 {
+				frameRate = FrameRateString(float64(track.samples) * float64(track.timescale) / float64(track.duration))
+			}
+			streams = append(streams, "Video: "+joinNonEmpty(codec, size, frameRate, language))
+		case "soun":
+			sampleRate := ""
+			if track.sampleRate > 0 {
+				sampleRate = SampleRateString(track.sampleRate)
+			}
+			channels := ""
+			if track.channels > 0 {
+				channels = ChannelsString(track.channels)
+			}
+			streams = append(streams, "Audio: "+joinNonEmpty(codec, sampleRate, channels, language))
+		case "subt", "sbtl", "text", "clcp":
+			streams = append(streams, "Subtitles: "+joinNonEmpty(codec, language))
+		}
+	}
+
+	kind := "audio"
+	if hasVideo {
+		kind = "video"
+	}
+	durationText := ""
+	if timescale > 0 && duration > 0 {
+		durationText = MediaDurationString(float64(duration) / float64(timescale))
+	}
+
+	details := mediaTagDetails(tags)
+	if !created.IsZero() && tags["Date"] == "" {
+		details = append(details, [2]string{"Created", created.Format(time.DateTime)})
+	}
+
+	return MediaInfo{
+		Summary: joinNonEmpty(formatName+" "+kind, durationText),
+		Details: details,
+		Streams: streams,
+	}, nil
+}
+
+// Returns the creation time, timescale and duration of an mvhd or mdhd box, which are 64-bit in version 1
+func mp4TimeHeader(data []byte) (uint64, uint32, uint64) {
+	if len(data) >= 32 && data[0] == 1 {
+		return binary.BigEndian.Uint64(data[4:]), binary.BigEndian.Uint32(data[20:]), binary.BigEndian.Uint64(data[24:])
+	}
+	if len(data) >= 20 {
+		return uint64(binary.BigEndian.Uint32(data[4:])), binary.BigEndian.Uint32(data[12:]), uint64(binary.BigEndian.Uint32(data[16:]))
+	}
+	return 0, 0, 0
+}
+
+// Three letters of 5 bits each after the duration of an mdhd box, like "eng"
+func mp4Language(data []byte) string {
+	offset := 20
+	if len(data) > 0 && data[0] == 1 {
+		offset = 32
+	}
+	if len(data) < offset+2 {
+		return ""
+	}
+
+	packed := binary.BigEndian.Uint16(data[offset:])
+	if packed == 0 {
+		return ""
+	}
+	return string([]byte{byte(packed>>10&0x1f) + 0x60, byte(packed>>5&0x1f) + 0x60, byte(packed&0x1f) + 0x60})
+}
+
+// The codec, and the size or audio format of the first sample entry
+func readMP4SampleDescription(data []byte, track *mp4Track) {
+	// Version, flags and the amount of entries
+	entries := mp4Boxes(data[min(len(data), 8):])
+	if len(entries) == 0 {
+		return
+	}
+
+	entry := entries[0]
+	track.codec = entry.name
+	// Sample entries start with 6 reserved bytes and a data reference index
+	switch track.handler {
+	case "vide":
+		// The size in tkhd is the one it's shown at, which can be different
+		if len(entry.data) >= 28 && track.width == 0 {
+			track.width = int(binary.BigEndian.Uint16(entry.data[24:]))
+			track.height = int(binary.BigEndian.Uint16(entry.data[26:]))
+		}
+	case "soun":
+		if len(entry.data) >= 28 {
+			track.channels = int(binary.BigEndian.Uint16(entry.data[16:]))
+			track.sampleRate = int(binary.BigEndian.Uint32(entry.data[24:]) >> 16)
+		}
+	}
+}
+
+// The total of the sample counts in an stts box
+func mp4SampleCount(data []byte) uint64 {
+	if len(data) < 8 {
+		return 0
+	}
+
+	var samples uint64
+	count := int(binary.BigEndian.Uint32(data[4:]))
+	for i := 0; i < count && 8+i*8+8 <= len(data); i++ {
+		samples += uint64(binary.BigEndian.Uint32(data[8+i*8:]))
+	}
+	return samples
+}
+
+// The value is in a "data" box, after its type and locale
+func mp4TagValue(name string, data []byte) string {
+	for _, box := range mp4Boxes(data) {
+		if box.name != "data" || len(box.data) < 8 {
+			continue
+		}
+
+		value := box.data[8:]
+		// The track number and the amount of tracks as 16-bit numbers, after 2 bytes of padding
+		if name == "trkn" {
+			if len(value) < 6 {
+				return ""
+			}
+			track := strconv.Itoa(int(binary.BigEndian.Uint16(value[2:])))
+			if total := binary.BigEndian.Uint16(value[4:]); total > 0 {
+				track += "/" + strconv.Itoa(int(total))
+			}
+			return track
+		}
+		return strings.ToValidUTF8(string(value), "")
+	}
+	return ""
+}
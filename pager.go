@@ -0,0 +1,540 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// Pressing v opens the selected file in a full-screen pager, like "less" but without leaving fen.
+// Text files are shown with syntax highlighting like the built-in text preview, and binary files as a hex dump.
+// It searches with / (case-insensitive unless the search has an uppercase letter), wraps lines with w and goes to a line with :
+
+// Larger files are only partly shown
+const (
+	maxPagerBytes        = 32 * 1024 * 1024
+	maxPagerHexDumpBytes = 4 * 1024 * 1024
+)
+
+const pagerHexDumpBytesPerLine = 16
+
+const pagerHorizontalScrollStep = 8
+
+// A row on screen, part of a line when wrapping
+type pagerRow struct {
+	line  int
+	start int // Byte offsets into the line
+	end   int
+}
+
+type Pager struct {
+	*tview.Box
+	fen     *Fen
+	visible bool
+
+	path      string
+	content   *PreviewResult // Nil while loading
+	truncated bool
+	loadID    int // Incremented for every opened file, so a file which finishes loading after being closed is ignored
+
+	wrap      bool
+	rows      []pagerRow // The wrapped lines, nil when not wrapping
+	rowsWidth int
+
+	scrollY int // In rows
+	scrollX int
+	width   int // Of the text, from the last draw
+	height  int
+
+	search      *regexp.Regexp
+	searchQuery string
+	searchLine  int // The line of the last match, -1 if there is none
+
+	prompt  string // "/" or ":" while typing a search or a line number
+	input   string
+	message string // Shown in the status line until the next key press
+}
+
+func NewPager(fen *Fen) *Pager {
+	return &Pager{Box: tview.NewBox().SetBackgroundColor(tcell.ColorDefault), fen: fen, searchLine: -1}
+}
+
+// The file at path as lines for the pager, and whether only the start of it was read since it's too large
+func PagerContent(path string) (*PreviewResult, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return &PreviewResult{err: err}, false
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return &PreviewResult{err: err}, false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxPagerBytes))
+	if err != nil {
+		return &PreviewResult{err: err}, false
+	}
+
+	if LooksBinary(data[:min(len(data), binaryDetectionBytes)]) {
+		data = data[:min(len(data), maxPagerHexDumpBytes)]
+		lines := HexDumpLines(data, 0, pagerHexDumpBytesPerLine, HexDumpOffsetDigits(stat.Size()))
+		return NewLinesPreviewResult(lines, false), stat.Size() > int64(len(data))
+	}
+
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolvedPath = path
+	}
+	return NewTextPreviewResult(resolvedPath, string(data)), stat.Size() > int64(len(data))
+}
+
+// Returns the byte offsets where the rows of text start when wrapped to width columns
+func WrapOffsets(text string, width int) []int {
+	offsets := []int{0}
+	column := 0
+	for i, r := range text {
+		runeWidth := runewidth.RuneWidth(r)
+		if column+runeWidth > width && i > offsets[len(offsets)-1] {
+			offsets = append(offsets, i)
+			column = 0
+		}
+		column += runeWidth
+	}
+	return offsets
+}
+
+// The part of line from byte offset start to end
+func sliceStyledLine(line StyledLine, start, end int) StyledLine {
+	result := StyledLine{Text: line.Text[start:end]}
+	for _, span := range line.Spans {
+		spanStart, spanEnd := max(span.Start, start), min(span.End, end)
+		if spanStart < spanEnd {
+			result.Spans = append(result.Spans, StyledSpan{Start: spanStart - start, End: spanEnd - start, Style: span.Style})
+		}
+	}
+	return result
+}
+
+// Shows the byte ranges of matches in reverse, on top of the syntax highlighting
+func highlightMatches(line StyledLine, matches [][]int) StyledLine {
+	if len(matches) == 0 {
+		return line
+	}
+
+	styles := make([]tcell.Style, len(line.Text))
+	for i := range styles {
+		styles[i] = tcell.StyleDefault
+	}
+	for _, span := range line.Spans {
+		for i := span.Start; i < span.End; i++ {
+			styles[i] = span.Style
+		}
+	}
+	for _, match := range matches {
+		for i := match[0]; i < match[1]; i++ {
+			styles[i] = styles[i].Reverse(true)
+		}
+	}
+
+	result := StyledLine{Text: line.Text}
+	for start := 0; start < len(styles); {
+		end := start + 1
+		for end < len(styles) && styles[end] == styles[start] {
+			end++
+		}
+		if styles[start] != tcell.StyleDefault {
+			result.Spans = append(result.Spans, StyledSpan{Start: start, End: end, Style: styles[start]})
+		}
+		start = end
+	}
+	return result
+}
+
+// Opens path in the pager, it's loaded in the background
+func (pager *Pager) Open(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return errors.New("Only files can be viewed")
+	}
+
+	pager.Close()
+	pager.visible = true
+	pager.path = path
+
+	loadID := pager.loadID
+	go func() {
+		content, truncated := PagerContent(path)
+		pager.fen.app.QueueUpdateDraw(func() {
+			if pager.loadID != loadID {
+				return
+			}
+			pager.content = content
+			pager.truncated = truncated
+		})
+	}()
+	return nil
+}
+
+// Line wrapping is kept for the next file
+func (pager *Pager) Close() {
+	pager.visible = false
+	pager.loadID++
+	pager.path = ""
+	pager.content = nil
+	pager.truncated = false
+	pager.rows = nil
+	pager.scrollY = 0
+	pager.scrollX = 0
+	pager.searchLine = -1
+	pager.prompt = ""
+	pager.input = ""
+	pager.message = ""
+}
+
+func (pager *Pager) lineCount() int {
+	if pager.content == nil {
+		return 0
+	}
+	return len(pager.content.lines)
+}
+
+func (pager *Pager) rowCount() int {
+	if pager.rows != nil {
+		return len(pager.rows)
+	}
+	return pager.lineCount()
+}
+
+func (pager *Pager) rowAt(index int) pagerRow {
+	if pager.rows != nil {
+		return pager.rows[index]
+	}
+	return pagerRow{line: index, start: 0, end: len(pager.content.lines[index].Text)}
+}
+
+// The first row of line
+func (pager *Pager) rowOf(line int) int {
+	if pager.rows == nil {
+		return line
+	}
+	return sort.Search(len(pager.rows), func(i int) bool { return pager.rows[i].line >= line })
+}
+
+// The line at the top of the screen
+func (pager *Pager) topLine() int {
+	if pager.rowCount() == 0 {
+		return 0
+	}
+	return pager.rowAt(min(pager.scrollY, pager.rowCount()-1)).line
+}
+
+// Wraps the lines to pager.width if wrapping is enabled, keeping the same line at the top of the screen
+func (pager *Pager) updateRows() {
+	if pager.content == nil || pager.width <= 0 {
+		return
+	}
+
+	if !pager.wrap {
+		if pager.rows != nil {
+			pager.scrollY = pager.topLine()
+			pager.rows = nil
+		}
+		return
+	}
+
+	if pager.rows != nil && pager.rowsWidth == pager.width {
+		return
+	}
+
+	topLine := pager.topLine()
+	rows := make([]pagerRow, 0, len(pager.content.lines))
+	for i, line := range pager.content.lines {
+		offsets := WrapOffsets(line.Text, pager.width)
+		for j, offset := range offsets {
+			end := len(line.Text)
+			if j+1 < len(offsets) {
+				end = offsets[j+1]
+			}
+			rows = append(rows, pagerRow{line: i, start: offset, end: end})
+		}
+	}
+
+	pager.rows = rows
+	pager.rowsWidth = pager.width
+	pager.scrollY = pager.rowOf(topLine)
+}
+
+func (pager *Pager) clampScroll() {
+	pager.scrollY = max(0, min(pager.scrollY, pager.rowCount()-pager.height))
+
+	linesWidth := 0
+	if pager.content != nil && !pager.wrap {
+		linesWidth = pager.content.linesWidth
+	}
+	pager.scrollX = max(0, min(pager.scrollX, linesWidth-pager.width))
+}
+
+// Scrolls down by delta rows, or up if it's negative
+func (pager *Pager) Scroll(delta int) {
+	pager.updateRows()
+	pager.scrollY += delta
+	pager.clampScroll()
+}
+
+func (pager *Pager) scrollToLine(line int) {
+	pager.updateRows()
+	pager.scrollY = pager.rowOf(line)
+	pager.clampScroll()
+}
+
+// Goes to the line number in text, counting from 1
+func (pager *Pager) GoToLine(text string) {
+	lineNumber, err := strconv.Atoi(text)
+	if err != nil || pager.lineCount() == 0 {
+		return
+	}
+
+	pager.scrollToLine(max(0, min(lineNumber, pager.lineCount())-1))
+}
+
+// Searches for query from the top of the screen. Case-insensitive unless query has an uppercase letter, an empty query searches for the last one again
+func (pager *Pager) Search(query string) {
+	if query != "" {
+		pattern := regexp.QuoteMeta(query)
+		if strings.IndexFunc(query, unicode.IsUpper) == -1 {
+			pattern = "(?i)" + pattern
+		}
+		pager.search = regexp.MustCompile(pattern)
+		pager.searchQuery = query
+	}
+
+	pager.searchLine = pager.topLine() - 1
+	pager.NextMatch(1)
+}
+
+// Scrolls to the next line with a match, or the previous one if direction is -1. Continues from the other end of the file after the last match
+func (pager *Pager) NextMatch(direction int) {
+	if pager.search == nil {
+		pager.message = "Nothing searched for yet, press / to search"
+		return
+	}
+
+	lines := pager.lineCount()
+	start := pager.searchLine
+	if start < 0 && direction < 0 {
+		start = 0
+	}
+
+	for i := 1; i <= lines; i++ {
+		index := ((start+direction*i)%lines + lines) % lines
+		text := pager.content.lines[index].Text
+		location := pager.search.FindStringIndex(text)
+		if location == nil {
+			continue
+		}
+
+		if (direction > 0 && index <= start) || (direction < 0 && index >= start) {
+			pager.message = "Search wrapped around"
+		}
+		pager.searchLine = index
+		pager.scrollToLine(index)
+
+		// Scrolls sideways to the match if it's off screen
+		if !pager.wrap {
+			column := runewidth.StringWidth(text[:location[0]])
+			if column < pager.scrollX || column >= pager.scrollX+pager.width {
+				pager.scrollX = max(0, column-pager.width/2)
+				pager.clampScroll()
+			}
+		}
+		return
+	}
+
+	pager.message = "Not found: " + pager.searchQuery
+}
+
+// Handles a key press, returns true if the pager should be closed
+func (pager *Pager) HandleKey(event *tcell.EventKey) bool {
+	pager.message = ""
+
+	if pager.prompt != "" {
+		pager.handlePromptKey(event)
+		return false
+	}
+
+	page := max(1, pager.height)
+	if event.Key() == tcell.KeyEscape || event.Rune() == 'q' || event.Rune() == 'v' {
+		return true
+	} else if event.Key() == tcell.KeyDown || event.Key() == tcell.KeyEnter || event.Rune() == 'j' {
+		pager.Scroll(1)
+	} else if event.Key() == tcell.KeyUp || event.Rune() == 'k' {
+		pager.Scroll(-1)
+	} else if event.Key() == tcell.KeyPgDn || event.Key() == tcell.KeyCtrlF || event.Rune() == ' ' || event.Rune() == 'f' {
+		pager.Scroll(page)
+	} else if event.Key() == tcell.KeyPgUp || event.Key() == tcell.KeyCtrlB || event.Rune() == 'b' {
+		pager.Scroll(-page)
+	} else if event.Key() == tcell.KeyCtrlD || event.Rune() == 'd' {
+		pager.Scroll(max(1, page/2))
+	} else if event.Key() == tcell.KeyCtrlU || event.Rune() == 'u' {
+		pager.Scroll(-max(1, page/2))
+	} else if event.Key() == tcell.KeyHome || event.Rune() == 'g' {
+		pager.scrollY = 0
+	} else if event.Key() == tcell.KeyEnd || event.Rune() == 'G' {
+		pager.Scroll(pager.rowCount())
+	} else if event.Key() == tcell.KeyLeft || event.Rune() == 'h' {
+		pager.scrollX -= pagerHorizontalScrollStep
+		pager.clampScroll()
+	} else if event.Key() == tcell.KeyRight || event.Rune() == 'l' {
+		pager.scrollX += pagerHorizontalScrollStep
+		pager.clampScroll()
+	} else if event.Rune() == 'w' {
+		pager.wrap = !pager.wrap
+		pager.scrollX = 0
+		pager.updateRows()
+		if pager.wrap {
+			pager.message = "Wrapping lines"
+		} else {
+			pager.message = "Not wrapping lines"
+		}
+	} else if event.Rune() == '/' || event.Rune() == ':' {
+		pager.prompt = string(event.Rune())
+	} else if event.Rune() == 'n' {
+		pager.NextMatch(1)
+	} else if event.Rune() == 'N' {
+		pager.NextMatch(-1)
+	}
+	return false
+}
+
+func (pager *Pager) handlePromptKey(event *tcell.EventKey) {
+	switch event.Key() {
+	case tcell.KeyEscape:
+		pager.prompt = ""
+		pager.input = ""
+	case tcell.KeyEnter:
+		prompt, input := pager.prompt, pager.input
+		pager.prompt = ""
+		pager.input = ""
+		if prompt == "/" {
+			pager.Search(input)
+		} else {
+			pager.GoToLine(input)
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if pager.input == "" {
+			pager.prompt = ""
+			return
+		}
+		runes := []rune(pager.input)
+		pager.input = string(runes[:len(runes)-1])
+	case tcell.KeyRune:
+		if pager.prompt == ":" && !unicode.IsDigit(event.Rune()) {
+			return
+		}
+		pager.input += string(event.Rune())
+	}
+}
+
+func (pager *Pager) Draw(screen tcell.Screen) {
+	if !pager.visible {
+		return
+	}
+
+	pager.Box.DrawForSubclass(screen, pager)
+	x, y, w, h := pager.GetInnerRect()
+	if w <= 0 || h < 3 {
+		return
+	}
+
+	tview.Print(screen, "[::r] "+tview.Escape(pager.path)+" [::-]", x, y, w, tview.AlignCenter, tcell.ColorDefault)
+
+	if pager.content == nil {
+		tview.Print(screen, "[::d]loading…", x, y+1, w, tview.AlignLeft, tcell.ColorDefault)
+		return
+	}
+
+	if pager.content.err != nil {
+		tview.Print(screen, "[red:]"+tview.Escape(pager.content.err.Error()), x, y+1, w, tview.AlignLeft, tcell.ColorDefault)
+		pager.drawStatusLine(screen, x, y+h-1, w)
+		return
+	}
+
+	gutterWidth := 0
+	if pager.content.lineNumbers && pager.fen.config.PreviewLineNumbers {
+		gutterWidth = len(strconv.Itoa(pager.lineCount())) + 1
+	}
+
+	pager.width = w - gutterWidth
+	pager.height = h - 2
+	pager.updateRows()
+	pager.clampScroll()
+
+	scrollX := pager.scrollX
+	if pager.wrap {
+		scrollX = 0
+	}
+
+	for i := 0; i < pager.height; i++ {
+		index := pager.scrollY + i
+		if index >= pager.rowCount() {
+			break
+		}
+
+		row := pager.rowAt(index)
+		if gutterWidth > 0 && row.start == 0 {
+			lineNumber := strconv.Itoa(row.line + 1)
+			tview.Print(screen, lineNumber, x+gutterWidth-1-len(lineNumber), y+1+i, len(lineNumber), tview.AlignLeft, tcell.ColorGray)
+		}
+
+		line := pager.content.lines[row.line]
+		if pager.search != nil {
+			line = highlightMatches(line, pager.search.FindAllStringIndex(line.Text, -1))
+		}
+		sliceStyledLine(line, row.start, row.end).Draw(screen, x+gutterWidth, y+1+i, pager.width, scrollX)
+	}
+
+	pager.drawStatusLine(screen, x, y+h-1, w)
+}
+
+// The search or line number being typed, a message or the controls on the left, and the position in the file on the right
+func (pager *Pager) drawStatusLine(screen tcell.Screen, x, y, w int) {
+	position := ""
+	if lines := pager.lineCount(); lines > 0 {
+		lastLine := pager.topLine() + 1
+		if pager.rowCount() > 0 {
+			lastLine = pager.rowAt(min(pager.scrollY+pager.height, pager.rowCount())-1).line + 1
+		}
+
+		total := strconv.Itoa(lines)
+		if pager.truncated {
+			total = "the first " + total
+		}
+		position = "Lines " + strconv.Itoa(pager.topLine()+1) + "-" + strconv.Itoa(lastLine) + " of " + total + " " + strconv.Itoa(lastLine*100/lines) + "%"
+	}
+	_, positionWidth := tview.Print(screen, position, x, y, w, tview.AlignRight, tcell.ColorDefault)
+
+	left := "[::d]q close, / search, n/N next/previous match, : go to line, w wrap lines"
+	if pager.prompt != "" {
+		left = tview.Escape(pager.prompt+pager.input) + "[::r] [::-]"
+	} else if pager.message != "" {
+		left = tview.Escape(pager.message)
+	}
+	tview.Print(screen, left, x, y, max(0, w-positionWidth-1), tview.AlignLeft, tcell.ColorDefault)
+}
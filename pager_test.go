@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestWrapOffsets(t *testing.T) {
+	tests := []struct {
+		text     string
+		width    int
+		expected []int
+	}{
+		{"", 10, []int{0}},
+		{"hello", 10, []int{0}},
+		{"hello world", 5, []int{0, 5, 10}},
+		{"日本語です", 4, []int{0, 6, 12}}, // Two columns per character
+	}
+
+	for _, test := range tests {
+		offsets := WrapOffsets(test.text, test.width)
+		if !reflect.DeepEqual(offsets, test.expected) {
+			t.Fatal("Expected WrapOffsets(\"" + test.text + "\", " + strconv.Itoa(test.width) + ") to be " + intsString(test.expected) + ", but got " + intsString(offsets))
+		}
+	}
+}
+
+func intsString(values []int) string {
+	text := "["
+	for i, value := range values {
+		if i > 0 {
+			text += " "
+		}
+		text += strconv.Itoa(value)
+	}
+	return text + "]"
+}
+
+func TestHighlightMatches(t *testing.T) {
+	bold := tcell.StyleDefault.Bold(true)
+	line := StyledLine{Text: "abcdef", Spans: []StyledSpan{{Start: 0, End: 3, Style: bold}}}
+
+	highlighted := highlightMatches(line, [][]int{{2, 4}})
+	expected := []StyledSpan{
+		{Start: 0, End: 2, Style: bold},
+		{Start: 2, End: 3, Style: bold.Reverse(true)},
+		{Start: 3, End: 4, Style: tcell.StyleDefault.Reverse(true)},
+	}
+	if !reflect.DeepEqual(highlighted.Spans, expected) {
+		t.Fatal("Expected the match to be reversed on top of the existing style")
+	}
+
+	sliced := sliceStyledLine(highlighted, 1, 3)
+	if sliced.Text != "bc" || len(sliced.Spans) != 2 || sliced.Spans[0].Start != 0 || sliced.Spans[1].End != 2 {
+		t.Fatal("Expected the spans to be cut to the slice")
+	}
+}
+
+func TestPagerContent(t *testing.T) {
+	folder := t.TempDir()
+
+	textPath := filepath.Join(folder, "file.txt")
+	err := os.WriteFile(textPath, []byte("first\nsecond\n"), 0o644)
+	if err != nil {
+		t.Fatal("Failed to write " + textPath + ": " + err.Error())
+	}
+
+	content, truncated := PagerContent(textPath)
+	if content.err != nil || truncated || len(content.lines) != 2 || content.lines[1].Text != "second" || !content.lineNumbers {
+		t.Fatal("Expected the 2 lines of the text file")
+	}
+
+	binaryPath := filepath.Join(folder, "file.bin")
+	err = os.WriteFile(binaryPath, make([]byte, 40), 0o644)
+	if err != nil {
+		t.Fatal("Failed to write " + binaryPath + ": " + err.Error())
+	}
+
+	content, _ = PagerContent(binaryPath)
+	if content.err != nil || len(content.lines) != 3 || content.lineNumbers {
+		t.Fatal("Expected a hex dump of 3 lines, but got " + strconv.Itoa(len(content.lines)) + " lines")
+	}
+
+	content, _ = PagerContent(filepath.Join(folder, "does-not-exist"))
+	if content.err == nil {
+		t.Fatal("Expected an error for a file which doesn't exist")
+	}
+}
+
+func TestPagerSearch(t *testing.T) {
+	pager := NewPager(&Fen{})
+	pager.content = NewTextPreviewResult("file.txt", "one\nTwo\nthree\ntwo\nfive\n")
+	pager.width = 80
+	pager.height = 2
+
+	pager.Search("two")
+	if pager.searchLine != 1 || pager.scrollY != 1 {
+		t.Fatal("Expected a case-insensitive search to find line 2, but got line " + strconv.Itoa(pager.searchLine+1))
+	}
+
+	pager.NextMatch(1)
+	if pager.searchLine != 3 || pager.scrollY != 3 {
+		t.Fatal("Expected the next match to be on line 4, but got line " + strconv.Itoa(pager.searchLine+1))
+	}
+
+	pager.NextMatch(1)
+	if pager.searchLine != 1 || pager.message != "Search wrapped around" {
+		t.Fatal("Expected the search to wrap around to line 2")
+	}
+
+	pager.NextMatch(-1)
+	if pager.searchLine != 3 {
+		t.Fatal("Expected the previous match to wrap around to line 4, but got line " + strconv.Itoa(pager.searchLine+1))
+	}
+
+	pager.scrollY = 0
+	pager.Search("Two")
+	pager.NextMatch(1)
+	if pager.searchLine != 1 || pager.message != "Search wrapped around" {
+		t.Fatal("Expected a search with an uppercase letter to be case-sensitive")
+	}
+
+	pager.Search("six")
+	if pager.message != "Not found: six" {
+		t.Fatal("Expected \"Not found: six\", but got \"" + pager.message + "\"")
+	}
+
+	pager.GoToLine("3")
+	if pager.scrollY != 2 {
+		t.Fatal("Expected to go to line 3, but the top line is " + strconv.Itoa(pager.scrollY+1))
+	}
+
+	// Scrolling is limited so the last line stays at the bottom
+	pager.GoToLine("100")
+	if pager.scrollY != 3 {
+		t.Fatal("Expected the top line to be 4, but got " + strconv.Itoa(pager.scrollY+1))
+	}
+}
+
+func TestPagerWrap(t *testing.T) {
+	pager := NewPager(&Fen{})
+	pager.content = NewTextPreviewResult("file.txt", "short\n"+"0123456789abcdefghij\n"+"last\n")
+	pager.width = 10
+	pager.height = 2
+	pager.wrap = true
+
+	pager.GoToLine("3")
+	if pager.rowCount() != 4 || pager.scrollY != 2 {
+		t.Fatal("Expected the long line to be wrapped into 2 rows")
+	}
+
+	row := pager.rowAt(2)
+	if row.line != 1 || row.start != 10 || row.end != 20 {
+		t.Fatal("Expected the second row of line 2 at the top")
+	}
+
+	pager.wrap = false
+	pager.updateRows()
+	if pager.rows != nil || pager.scrollY != 1 {
+		t.Fatal("Expected line 2 to stay at the top after turning off wrapping")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// The file preview can be scrolled with Shift+arrow keys or the mouse wheel over the preview.
+// The offset is reset when the selected file changes, and is given to Lua preview scripts as fen.ScrollY and fen.ScrollX,
+// and to preview programs as the FEN_PREVIEW_SCROLL_Y and FEN_PREVIEW_SCROLL_X environment variables
+
+const previewScrollStepX = 4
+
+// Call it in fen.UpdatePanes() after fen.sel is set
+func (fen *Fen) resetPreviewScrollIfSelectionChanged() {
+	if fen.sel == fen.previewScrollPath {
+		return
+	}
+
+	fen.previewScrollPath = fen.sel
+	fen.previewScrollY = 0
+	fen.previewScrollX = 0
+	fen.previewScrollLimitY = -1
+	fen.stopPreviewFollowIfMovedAway()
+}
+
+// Returns false if the scroll offset didn't change, like when trying to scroll past the top.
+// It can't scroll further down than the last line of program output, Lua scripts are not limited.
+// Scrolling up stops following the file
+func (fen *Fen) ScrollPreview(deltaY, deltaX int) bool {
+	if deltaY < 0 && fen.FollowingPreview() {
+		fen.stopPreviewFollow()
+		fen.bottomBar.TemporarilyShowTextInstead("Stopped following " + filepath.Base(fen.sel))
+	}
+
+	scrollY := max(0, fen.previewScrollY+deltaY)
+	if fen.previewScrollLimitY >= 0 {
+		scrollY = min(scrollY, fen.previewScrollLimitY)
+	}
+	scrollX := max(0, fen.previewScrollX+deltaX)
+
+	if scrollY == fen.previewScrollY && scrollX == fen.previewScrollX {
+		return false
+	}
+
+	fen.previewScrollY = scrollY
+	fen.previewScrollX = scrollX
+	return true
+}
+
+// Whether the preview is on screen, for mouse wheel scrolling
+func (fen *Fen) PreviewIsVisible() bool {
+	if fen.config.Layout != LAYOUT_MILLER {
+		return false
+	}
+
+	for _, pane := range fen.shownPanes {
+		if pane == fen.rightPane {
+			return true
+		}
+	}
+	return false
+}
+
+// The environment preview programs are run with
+func (fen *Fen) PreviewProgramEnvironment(width, height int) []string {
+	return append(os.Environ(),
+		"FEN_PREVIEW_SCROLL_Y="+strconv.Itoa(fen.previewScrollY),
+		"FEN_PREVIEW_SCROLL_X="+strconv.Itoa(fen.previewScrollX),
+		"FEN_PREVIEW_WIDTH="+strconv.Itoa(width),
+		"FEN_PREVIEW_HEIGHT="+strconv.Itoa(height),
+	)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// When fen.preview_cache is enabled, the output of file preview programs is also cached in this folder in FenCacheFolder(),
+// so previews from expensive programs (like pdftotext) show up instantly when coming back to a folder.
+// Each file is named by PreviewCacheKey(), and its modified time is when it was last used.
+// When the folder is larger than fen.preview_cache_size_mb, the least recently used files are deleted
+const previewCacheFolderName = "previews"
+
+// Only one fen instance at a time is usually writing, this is just so our own jobs don't evict at the same time
+var previewCacheEvictMutex sync.Mutex
+
+func PreviewCacheFolder() (string, error) {
+	cacheFolder, err := FenCacheFolder()
+	if err != nil {
+		return "", err
+	}
+
+	folder := filepath.Join(cacheFolder, previewCacheFolderName)
+	err = os.MkdirAll(folder, 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	return folder, nil
+}
+
+// The rule identifies the fen.preview entries used, since changing them should give a different preview.
+// The size of the preview area is not included, so resizing the terminal doesn't make everything previewed again
+func PreviewCacheKey(resolvedPath string, size int64, modTime time.Time, rule string) string {
+	hash := sha256.New()
+	hash.Write([]byte(strings.Join([]string{
+		resolvedPath,
+		strconv.FormatInt(size, 10),
+		strconv.FormatInt(modTime.UnixNano(), 10),
+		rule,
+	}, "\x00")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns false if it isn't cached. Marks it as recently used
+func ReadPreviewCache(folder, key string) ([]byte, bool) {
+	path := filepath.Join(folder, key)
+	output, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return output, true
+}
+
+// Output larger than maxBytes is not cached
+func WritePreviewCache(folder, key string, output []byte, maxBytes int64) error {
+	if int64(len(output)) > maxBytes {
+		return nil
+	}
+
+	err := WriteFileAtomic(filepath.Join(folder, key), output, 0o600)
+	if err != nil {
+		return err
+	}
+
+	return EvictPreviewCache(folder, maxBytes)
+}
+
+// Deletes the least recently used files until the folder is no larger than maxBytes
+func EvictPreviewCache(folder string, maxBytes int64) error {
+	previewCacheEvictMutex.Lock()
+	defer previewCacheEvictMutex.Unlock()
+
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+
+	var files []os.FileInfo
+	var totalBytes int64
+	for _, entry := range entries {
+		// Temporary files from WriteFileAtomic() start with a dot
+		if strings.HasPrefix(entry.Name(), ".") || !entry.Type().IsRegular() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, info)
+		totalBytes += info.Size()
+	}
+
+	if totalBytes <= maxBytes {
+		return nil
+	}
+
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+
+	for _, file := range files {
+		if totalBytes <= maxBytes {
+			break
+		}
+
+		err := os.Remove(filepath.Join(folder, file.Name()))
+		if err == nil || os.IsNotExist(err) {
+			totalBytes -= file.Size()
+		}
+	}
+
+	return nil
+}
+
+// Deletes every cached preview, for "fen --clear-preview-cache"
+func ClearPreviewCache() error {
+	cacheFolder, err := FenCacheFolder()
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(cacheFolder, previewCacheFolderName))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvictPreviewCache(t *testing.T) {
+	folder := t.TempDir()
+
+	now := time.Now()
+	// Oldest first
+	for i, key := range []string{"a", "b", "c"} {
+		path := filepath.Join(folder, key)
+		err := os.WriteFile(path, make([]byte, 100), 0o600)
+		if err != nil {
+			t.Fatal("Failed to write " + path + ": " + err.Error())
+		}
+
+		modTime := now.Add(time.Duration(i-3) * time.Minute)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	// Using "a" makes "b" the least recently used
+	_, ok := ReadPreviewCache(folder, "a")
+	if !ok {
+		t.Fatal("Expected \"a\" to be cached")
+	}
+
+	err := EvictPreviewCache(folder, 200)
+	if err != nil {
+		t.Fatal("Failed to evict: " + err.Error())
+	}
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		_, err := os.Stat(filepath.Join(folder, key))
+		if (err == nil) != expected {
+			t.Fatal("Expected \"" + key + "\" to be kept: " + strconv.FormatBool(expected))
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Pressing F follows the previewed file like "tail -f": the preview is kept scrolled to the end, and made again whenever the file changes.
+// The file itself is watched, so it also works where the folder watcher doesn't report writes (like on macOS).
+// When the file is truncated the preview just gets shorter, and when it's replaced by a new file (log rotation) the new one is selected and watched.
+// Following stops when selecting another file or scrolling the preview up
+
+// Watches a single file, calling onChange at most once every interval
+type PreviewFollower struct {
+	mutex    sync.Mutex
+	watcher  *fsnotify.Watcher
+	path     string
+	stat     os.FileInfo // The watched file, to notice when path becomes another file. Nil if path isn't watched
+	interval time.Duration
+
+	lastChange    time.Time
+	pendingChange bool
+	onChange      func() // Called from another goroutine
+}
+
+func NewPreviewFollower(onChange func()) *PreviewFollower {
+	watcher, _ := fsnotify.NewWatcher()
+	follower := &PreviewFollower{watcher: watcher, onChange: onChange}
+	if watcher == nil {
+		return follower
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				follower.mutex.Lock()
+				isFollowed := event.Name == follower.path
+				follower.mutex.Unlock()
+
+				if isFollowed {
+					follower.changed()
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return follower
+}
+
+// Starts watching path, or watches it again if it has been replaced by another file since the last call.
+// Call it every time the preview is drawn while following
+func (follower *PreviewFollower) Follow(path string, interval time.Duration) {
+	follower.mutex.Lock()
+	defer follower.mutex.Unlock()
+
+	follower.interval = interval
+	if follower.watcher == nil {
+		return
+	}
+
+	stat, err := os.Stat(path)
+	if path == follower.path && err == nil && follower.stat != nil && os.SameFile(stat, follower.stat) {
+		return
+	}
+
+	if follower.path != "" {
+		follower.watcher.Remove(follower.path)
+	}
+	follower.path = path
+	follower.stat = nil
+
+	// It was moved away or deleted, the folder watcher redraws the screen when it's back
+	if err != nil {
+		return
+	}
+
+	if follower.watcher.Add(path) == nil {
+		follower.stat = stat
+	}
+}
+
+func (follower *PreviewFollower) Stop() {
+	follower.mutex.Lock()
+	defer follower.mutex.Unlock()
+
+	if follower.watcher != nil && follower.path != "" {
+		follower.watcher.Remove(follower.path)
+	}
+	follower.path = ""
+	follower.stat = nil
+}
+
+// The follower should not be used afterwards
+func (follower *PreviewFollower) Close() {
+	if follower.watcher != nil {
+		follower.watcher.Close()
+	}
+}
+
+// Calls onChange now, or after the rest of the interval if it was called recently
+func (follower *PreviewFollower) changed() {
+	follower.mutex.Lock()
+	if follower.pendingChange {
+		follower.mutex.Unlock()
+		return
+	}
+
+	wait := follower.interval - time.Since(follower.lastChange)
+	if wait <= 0 {
+		follower.lastChange = time.Now()
+		follower.mutex.Unlock()
+		follower.onChange()
+		return
+	}
+
+	follower.pendingChange = true
+	follower.mutex.Unlock()
+
+	time.AfterFunc(wait, func() {
+		follower.mutex.Lock()
+		follower.pendingChange = false
+		follower.lastChange = time.Now()
+		follower.mutex.Unlock()
+		follower.onChange()
+	})
+}
+
+// Whether the selected file is followed
+func (fen *Fen) FollowingPreview() bool {
+	return fen.previewFollowPath != "" && fen.previewFollowPath == fen.sel
+}
+
+// Starts following the selected file, or stops if it's already followed
+func (fen *Fen) TogglePreviewFollow() error {
+	if fen.FollowingPreview() {
+		fen.stopPreviewFollow()
+		fen.bottomBar.TemporarilyShowTextInstead("Stopped following " + filepath.Base(fen.sel))
+		return nil
+	}
+
+	stat, err := os.Stat(fen.sel)
+	if err != nil || !stat.Mode().IsRegular() {
+		return errors.New("Only files can be followed")
+	}
+
+	fen.stopPreviewFollow()
+	fen.previewFollowPath = fen.sel
+	if !fen.PreviewIsVisible() {
+		fen.bottomBar.TemporarilyShowTextInstead("Following " + stat.Name() + ", but the preview is hidden")
+	} else {
+		fen.bottomBar.TemporarilyShowTextInstead("Following " + stat.Name() + ", press F to stop")
+	}
+	return nil
+}
+
+func (fen *Fen) stopPreviewFollow() {
+	fen.previewFollowPath = ""
+	fen.previewFollowRotated = false
+	if fen.previewFollower != nil {
+		fen.previewFollower.Stop()
+	}
+}
+
+// Call it when the selection changes. If the followed file is gone, it was probably rotated (renamed, and created again),
+// so we keep following it until it's back
+func (fen *Fen) stopPreviewFollowIfMovedAway() {
+	if fen.previewFollowPath == "" || fen.sel == fen.previewFollowPath {
+		return
+	}
+
+	_, err := os.Lstat(fen.previewFollowPath)
+	if err != nil {
+		fen.previewFollowRotated = true
+		return
+	}
+
+	fen.stopPreviewFollow()
+}
+
+// Selects the followed file again when it's back after being rotated, call it in fen.UpdatePanes() before the middlePane selection is set from fen.sel
+func (fen *Fen) selectRotatedFollowedFile() {
+	if !fen.previewFollowRotated || filepath.Dir(fen.previewFollowPath) != fen.wd {
+		return
+	}
+
+	_, err := os.Lstat(fen.previewFollowPath)
+	if err == nil {
+		fen.sel = fen.previewFollowPath
+		fen.previewFollowRotated = false
+	}
+}
+
+// The end of a text file too large to be read entirely, for following it. Line numbers are not shown since we don't know them
+func (job PreviewJob) tailPreview(ctx context.Context, file *os.File, fileSize int64) *PreviewResult {
+	data := make([]byte, min(fileSize, int64(job.textPreviewBytes())))
+	n, err := file.ReadAt(data, fileSize-int64(len(data)))
+	if err != nil && n < len(data) {
+		return &PreviewResult{err: err}
+	}
+
+	if ctx.Err() != nil {
+		return job.timedOutResult(ctx)
+	}
+
+	// The first line is most likely cut off
+	if i := bytes.IndexByte(data, '\n'); i != -1 {
+		data = data[i+1:]
+	}
+
+	lines := TextPreviewLines(string(data))
+	return NewLinesPreviewResult(HighlightLines(lines, SyntaxLanguageFor(job.ResolvedPath, "")), false)
+}
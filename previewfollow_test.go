@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTailPreview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.log")
+	err := os.WriteFile(path, []byte("first line\nsecond line\nthird line\n"), 0o644)
+	if err != nil {
+		t.Fatal("Failed to write " + path + ": " + err.Error())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal("Failed to open " + path + ": " + err.Error())
+	}
+	defer file.Close()
+
+	stat, _ := file.Stat()
+	job := PreviewJob{Path: path, ResolvedPath: path, MaxOutputBytes: 20}
+	result := job.tailPreview(context.Background(), file, stat.Size())
+	if result.err != nil {
+		t.Fatal("Expected no error, but got: " + result.err.Error())
+	}
+	if result.lineNumbers {
+		t.Fatal("Expected no line numbers")
+	}
+
+	// The last 20 bytes are "line\nthird line\n", where "line" is cut off
+	if len(result.lines) == 0 || result.lines[0].Text != "third line" {
+		t.Fatal("Expected the cut off first line to be left out, but got " + strconv.Itoa(len(result.lines)) + " lines")
+	}
+}
+
+func TestPreviewFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.log")
+	err := os.WriteFile(path, []byte("hello\n"), 0o644)
+	if err != nil {
+		t.Fatal("Failed to write " + path + ": " + err.Error())
+	}
+
+	changes := make(chan struct{}, 10)
+	follower := NewPreviewFollower(func() { changes <- struct{}{} })
+	defer follower.Close()
+	if follower.watcher == nil {
+		t.Skip("Could not create a file watcher")
+	}
+
+	follower.Follow(path, 10*time.Millisecond)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal("Failed to open " + path + ": " + err.Error())
+	}
+	file.WriteString("world\n")
+	file.Close()
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected onChange to be called when the file was written to")
+	}
+
+	follower.Stop()
+	if follower.path != "" || follower.stat != nil {
+		t.Fatal("Expected Stop() to stop watching the file")
+	}
+}
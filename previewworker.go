@@ -0,0 +1,588 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	lua "github.com/yuin/gopher-lua"
+	luar "layeh.com/gopher-luar"
+)
+
+// File previews are made in the background so slow preview programs never block fen.
+// FilesPane.Draw() asks for the preview of the selected file (and its neighbours, so they are ready when you move to them).
+// Jobs for files that are no longer wanted are cancelled, and finished previews are kept in a small in-memory cache
+
+const maxCachedPreviews = 32
+
+// Everything needed to make a preview, so the job doesn't have to touch fen from another goroutine
+type PreviewJob struct {
+	Path         string               // The selected file
+	ResolvedPath string               // Path with symlinks resolved, what Lua scripts get as fen.SelectedFile
+	Rules        []PreviewOrOpenEntry // The matching fen.preview entries, a built-in preview is used if empty
+	Width        int
+	Height       int
+	ScrollY      int
+	ScrollX      int
+	Env          []string
+	TrueColor    bool
+
+	// See terminalimage.go
+	ImageProtocol string
+	CellWidth     int // In pixels
+	CellHeight    int
+
+	Timeout        time.Duration // No timeout if 0
+	MaxOutputBytes int           // No limit if 0, except for built-in text previews, see textPreviewBytes()
+
+	Follow bool // Shows the end of large text files, see previewfollow.go
+
+	// See previewcache.go, not cached on disk if CacheFolder is empty
+	CacheFolder   string
+	CacheMaxBytes int64
+
+	key      previewKey
+	cacheKey string
+}
+
+// Previews are cached by this, ScrollY and ScrollX are checked separately since most previews don't depend on them
+type previewKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+	rule    string // Identifies the matching rules, in case fen.preview changes (like from a .fen.lua file)
+	width   int
+	height  int
+	follow  bool
+}
+
+// A finished preview. If nothing could preview the file, all fields are unset
+type PreviewResult struct {
+	textView   *tview.TextView // Program output
+	fenScrolls bool            // Whether fen scrolls textView, false for programs with scrolls_itself = true
+
+	luaScreen tcell.SimulationScreen // What a Lua script drew
+
+	terminalImage *TerminalImage // Drawn on top of lines
+
+	// Built-in previews, see builtinpreview.go
+	lines       []StyledLine
+	linesWidth  int  // The width of the widest line
+	lineNumbers bool // Whether line numbers are shown with fen.preview_line_numbers
+	linesOffset int  // The line number of lines[0], when only some of the lines were made (like in a hex dump)
+	totalLines  int  // Only set with linesOffset
+	message     string
+
+	err error // Lua errors and timeouts
+
+	// The scroll offset it was made with, only matters when scrollDependent is true
+	scrollY         int
+	scrollX         int
+	scrollDependent bool
+}
+
+// Whether the result can be shown for the scroll offset of job, or a new preview has to be made
+func (result *PreviewResult) validFor(job PreviewJob) bool {
+	if !result.scrollDependent {
+		return true
+	}
+
+	// It has every visible line
+	if result.totalLines > 0 {
+		return job.ScrollY >= result.linesOffset && min(job.ScrollY+job.Height, result.totalLines) <= result.linesOffset+len(result.lines)
+	}
+
+	return result.scrollY == job.ScrollY && result.scrollX == job.ScrollX
+}
+
+type previewCacheEntry struct {
+	key    previewKey
+	result *PreviewResult
+}
+
+type runningPreview struct {
+	cancel  context.CancelFunc
+	scrollY int
+	scrollX int
+}
+
+type PreviewWorker struct {
+	mutex   sync.Mutex
+	cache   []previewCacheEntry // Least recently used first
+	running map[previewKey]*runningPreview
+	onDone  func() // Called from the job goroutine when a preview is finished
+}
+
+func NewPreviewWorker(onDone func()) *PreviewWorker {
+	return &PreviewWorker{
+		running: make(map[previewKey]*runningPreview),
+		onDone:  onDone,
+	}
+}
+
+// Returns false if it is not a regular file, or no rule in fen.preview matches path and fen.built_in_previews is disabled
+func (fen *Fen) NewPreviewJob(path string, width, height, scrollY, scrollX int) (PreviewJob, bool) {
+	stat, err := os.Stat(path)
+	if err != nil || !stat.Mode().IsRegular() {
+		return PreviewJob{}, false
+	}
+
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolvedPath = path
+	}
+
+	var rules []PreviewOrOpenEntry
+	var ruleIdentity strings.Builder
+	for _, previewWith := range fen.config.Preview {
+		if PathMatchesList(resolvedPath, previewWith.Match) && !PathMatchesList(resolvedPath, previewWith.DoNotMatch) {
+			rules = append(rules, previewWith)
+			ruleIdentity.WriteString(previewWith.Script + "\x00" + strings.Join(previewWith.Program, "\x00") + "\x00" + strconv.FormatBool(previewWith.ScrollsItself) + "\x01")
+		}
+	}
+
+	if len(rules) == 0 && !fen.config.BuiltInPreviews {
+		return PreviewJob{}, false
+	}
+
+	cacheFolder := ""
+	// Built-in previews are quick enough to not need caching
+	if len(rules) > 0 && fen.config.PreviewCache && !fen.config.NoWrite {
+		// Errors are ignored, previews will only be cached in memory
+		if fen.previewCacheFolder == "" {
+			fen.previewCacheFolder, _ = PreviewCacheFolder()
+		}
+		cacheFolder = fen.previewCacheFolder
+	}
+
+	imageProtocol := fen.config.ImageProtocol
+	if imageProtocol == IMAGE_PROTOCOL_AUTO {
+		imageProtocol = fen.detectedImageProtocol
+	}
+
+	cellWidth, cellHeight := 0, 0
+	if imageProtocol != IMAGE_PROTOCOL_NONE {
+		cellWidth, cellHeight = TerminalCellSize()
+	}
+
+	// Only the selected file is followed, not the prefetched ones
+	follow := fen.FollowingPreview() && path == fen.sel
+
+	return PreviewJob{
+		Path:           path,
+		ResolvedPath:   resolvedPath,
+		Rules:          rules,
+		Width:          width,
+		Height:         height,
+		ScrollY:        scrollY,
+		ScrollX:        scrollX,
+		Env:            fen.PreviewProgramEnvironment(width, height),
+		TrueColor:      fen.previewTrueColor,
+		ImageProtocol:  imageProtocol,
+		CellWidth:      cellWidth,
+		CellHeight:     cellHeight,
+		Timeout:        time.Duration(fen.config.PreviewTimeoutMs) * time.Millisecond,
+		MaxOutputBytes: fen.config.PreviewMaxOutputBytes,
+		Follow:         follow,
+		CacheFolder:    cacheFolder,
+		CacheMaxBytes:  int64(fen.config.PreviewCacheSizeMb) * 1000 * 1000,
+		cacheKey:       PreviewCacheKey(resolvedPath, stat.Size(), stat.ModTime(), ruleIdentity.String()),
+		key: previewKey{
+			path:    path,
+			size:    stat.Size(),
+			modTime: stat.ModTime(),
+			rule:    ruleIdentity.String(),
+			width:   width,
+			height:  height,
+			follow:  follow,
+		},
+	}, true
+}
+
+// Starts the jobs which aren't cached or already running, and cancels running jobs not in current or prefetch.
+// Returns the cached result for current, which may be for another scroll offset while a new one is made, or nil if it's still loading
+func (worker *PreviewWorker) Request(current PreviewJob, prefetch []PreviewJob) *PreviewResult {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	jobs := append([]PreviewJob{current}, prefetch...)
+
+	for key, running := range worker.running {
+		if !slices.ContainsFunc(jobs, func(job PreviewJob) bool { return job.key == key }) {
+			running.cancel()
+			delete(worker.running, key)
+		}
+	}
+
+	for _, job := range jobs {
+		result := worker.cached(job.key)
+		if result != nil && result.validFor(job) {
+			continue
+		}
+
+		// Reading it from the disk is quick enough to not need a job
+		if result == nil && job.CacheFolder != "" {
+			if output, ok := ReadPreviewCache(job.CacheFolder, job.cacheKey); ok {
+				worker.store(job.key, NewProgramOutputResult(output, true, job))
+				continue
+			}
+		}
+
+		if running, ok := worker.running[job.key]; ok {
+			if running.scrollY == job.ScrollY && running.scrollX == job.ScrollX {
+				continue
+			}
+			running.cancel()
+		}
+
+		worker.start(job)
+	}
+
+	return worker.cached(current.key)
+}
+
+// Cancels all running jobs
+func (worker *PreviewWorker) CancelAll() {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	for key, running := range worker.running {
+		running.cancel()
+		delete(worker.running, key)
+	}
+}
+
+// Has to be called with worker.mutex locked, marks the entry as recently used
+func (worker *PreviewWorker) cached(key previewKey) *PreviewResult {
+	index := slices.IndexFunc(worker.cache, func(entry previewCacheEntry) bool { return entry.key == key })
+	if index == -1 {
+		return nil
+	}
+
+	entry := worker.cache[index]
+	worker.cache = append(slices.Delete(worker.cache, index, index+1), entry)
+	return entry.result
+}
+
+// Returns the most recently made preview of path, even if the file has changed since. Nil if there is none.
+// Shown while following a file, instead of "loading…" every time it changes
+func (worker *PreviewWorker) LatestForPath(path string) *PreviewResult {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	for i := len(worker.cache) - 1; i >= 0; i-- {
+		if worker.cache[i].key.path == path {
+			return worker.cache[i].result
+		}
+	}
+	return nil
+}
+
+// Has to be called with worker.mutex locked. Previews of an older version of the file are removed, they won't be shown again
+func (worker *PreviewWorker) store(key previewKey, result *PreviewResult) {
+	worker.cache = slices.DeleteFunc(worker.cache, func(entry previewCacheEntry) bool {
+		return entry.key == key || (entry.key.path == key.path && (entry.key.size != key.size || !entry.key.modTime.Equal(key.modTime)))
+	})
+	worker.cache = append(worker.cache, previewCacheEntry{key: key, result: result})
+	if len(worker.cache) > maxCachedPreviews {
+		worker.cache = slices.Delete(worker.cache, 0, len(worker.cache)-maxCachedPreviews)
+	}
+}
+
+// Has to be called with worker.mutex locked
+func (worker *PreviewWorker) start(job PreviewJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &runningPreview{cancel: cancel, scrollY: job.ScrollY, scrollX: job.ScrollX}
+	worker.running[job.key] = running
+
+	go func() {
+		defer cancel()
+		result := job.Run(ctx)
+
+		worker.mutex.Lock()
+		// Cancelled, or replaced by a job for another scroll offset
+		if ctx.Err() == context.Canceled || worker.running[job.key] != running {
+			worker.mutex.Unlock()
+			return
+		}
+		delete(worker.running, job.key)
+		worker.store(job.key, result)
+		worker.mutex.Unlock()
+
+		if worker.onDone != nil {
+			worker.onDone()
+		}
+	}()
+}
+
+// Stops accepting writes after limit bytes, so a preview program can't use up all our memory
+type limitedBuffer struct {
+	buffer    bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+var errPreviewOutputLimit = errors.New("Preview output limit reached")
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.buffer.Len()+len(p) > b.limit {
+		b.buffer.Write(p[:b.limit-b.buffer.Len()])
+		b.truncated = true
+		return 0, errPreviewOutputLimit
+	}
+	return b.buffer.Write(p)
+}
+
+// Tries the rules in order like the synchronous previews did: a Lua script is always used,
+// and if every program of a rule fails, the next rule is tried
+func (job PreviewJob) Run(ctx context.Context) *PreviewResult {
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	if len(job.Rules) == 0 {
+		return job.runBuiltIn(ctx)
+	}
+
+	for _, previewWith := range job.Rules {
+		if previewWith.Script != "" {
+			return job.runLuaScript(ctx, previewWith.Script)
+		}
+
+		for _, program := range previewWith.Program {
+			result, ok := job.runProgram(ctx, program, previewWith.ScrollsItself)
+			if ok {
+				return result
+			}
+
+			if ctx.Err() != nil {
+				return job.timedOutResult(ctx)
+			}
+		}
+	}
+
+	return &PreviewResult{}
+}
+
+func (job PreviewJob) timedOutResult(ctx context.Context) *PreviewResult {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &PreviewResult{err: errors.New("File preview timed out after " + job.Timeout.String())}
+	}
+	return &PreviewResult{}
+}
+
+func (job PreviewJob) runLuaScript(ctx context.Context, script string) *PreviewResult {
+	screen := tcell.NewSimulationScreen("UTF-8")
+	err := screen.Init()
+	if err != nil {
+		return &PreviewResult{err: err}
+	}
+	screen.SetSize(job.Width, job.Height)
+
+	L := lua.NewState()
+	defer L.Close()
+	L.SetContext(ctx)
+
+	fenLuaGlobal := &FenLuaGlobal{
+		SelectedFile: job.ResolvedPath,
+		Width:        job.Width,
+		Height:       job.Height,
+		ScrollY:      job.ScrollY,
+		ScrollX:      job.ScrollX,
+		screen:       screen,
+	}
+
+	L.SetGlobal("fen", luar.New(L, fenLuaGlobal))
+	err = L.DoFile(script)
+	if err != nil {
+		if ctx.Err() != nil {
+			return job.timedOutResult(ctx)
+		}
+		return &PreviewResult{err: err, scrollDependent: true, scrollY: job.ScrollY, scrollX: job.ScrollX}
+	}
+
+	return &PreviewResult{luaScreen: screen, scrollDependent: true, scrollY: job.ScrollY, scrollX: job.ScrollX}
+}
+
+// Returns false if the program failed, so the next one can be tried
+func (job PreviewJob) runProgram(ctx context.Context, program string, scrollsItself bool) (*PreviewResult, bool) {
+	programSplitSpace := strings.Split(program, " ")
+
+	programName := programSplitSpace[0]
+	programArguments := []string{}
+	if len(programSplitSpace) > 1 {
+		programArguments = programSplitSpace[1:]
+	}
+
+	cmd := exec.CommandContext(ctx, programName, append(programArguments, job.Path)...)
+	cmd.Env = job.Env
+	cmd.WaitDelay = time.Second // In case the program started other programs which keep its output open
+
+	output := &limitedBuffer{limit: job.MaxOutputBytes}
+	cmd.Stdout = output
+
+	err := cmd.Run()
+	// The program is stopped when it outputs too much, but we still show what it output
+	if err != nil && !output.truncated {
+		return nil, false
+	}
+
+	if !scrollsItself && job.CacheFolder != "" {
+		WritePreviewCache(job.CacheFolder, job.cacheKey, output.buffer.Bytes(), job.CacheMaxBytes)
+	}
+
+	return NewProgramOutputResult(output.buffer.Bytes(), !scrollsItself, job), true
+}
+
+func NewProgramOutputResult(output []byte, fenScrolls bool, job PreviewJob) *PreviewResult {
+	textView := tview.NewTextView()
+	textView.SetBackgroundColor(tcell.ColorDefault)
+	textView.SetTextColor(tcell.ColorDefault)
+	tview.ANSIWriter(textView).Write(output)
+
+	result := &PreviewResult{textView: textView, fenScrolls: fenScrolls}
+	if !fenScrolls {
+		result.scrollDependent = true
+		result.scrollY = job.ScrollY
+		result.scrollX = job.ScrollX
+	}
+	return result
+}
+
+// Draws a finished preview in the given area.
+// Sets fen.previewScrollLimitY and clamps fen.previewScrollX when fen scrolls the output
+func (fp *FilesPane) DrawPreviewResult(screen tcell.Screen, result *PreviewResult, x, y, w, h int) {
+	if result.err != nil {
+		fp.Box.DrawForSubclass(screen, fp)
+		tview.Print(screen, "File preview error:", x, y, w, tview.AlignLeft, tcell.ColorRed)
+		lines := tview.WordWrap(result.err.Error(), w)
+		for i, line := range lines {
+			tview.Print(screen, line, x, y+1+i, w, tview.AlignLeft, tcell.ColorDefault)
+		}
+		return
+	}
+
+	if result.luaScreen != nil {
+		luaWidth, luaHeight := result.luaScreen.Size()
+		for row := 0; row < min(h, luaHeight); row++ {
+			for column := 0; column < min(w, luaWidth); column++ {
+				mainc, combc, style, _ := result.luaScreen.GetContent(column, row)
+				screen.SetContent(x+column, y+row, mainc, combc, style)
+			}
+		}
+		return
+	}
+
+	if result.message != "" {
+		tview.Print(screen, "[::d]"+tview.Escape(result.message), x, y+h/2, w, tview.AlignCenter, tcell.ColorDefault)
+		return
+	}
+
+	if result.lines != nil {
+		fp.drawPreviewLines(screen, result, x, y, w, h)
+		if result.terminalImage != nil && fp.fen.previewScrollY == 0 {
+			fp.fen.terminalImages.Show(result.terminalImage, x, y)
+		}
+		return
+	}
+
+	if result.textView == nil {
+		return
+	}
+
+	textView := result.textView
+	textView.SetRect(x, y, w, h)
+	if result.fenScrolls {
+		// Lines would move around when scrolling horizontally with wrapping
+		textView.SetWrap(fp.fen.previewScrollX == 0)
+		fp.fen.previewScrollLimitY = max(0, textView.GetOriginalLineCount()-h)
+		if fp.fen.FollowingPreview() {
+			fp.fen.previewScrollY = fp.fen.previewScrollLimitY
+		}
+		textView.ScrollTo(fp.fen.previewScrollY, fp.fen.previewScrollX)
+	} else {
+		textView.ScrollTo(0, 0)
+	}
+	textView.Draw(screen)
+
+	// The textView doesn't scroll further right than the widest line, so we don't either
+	if result.fenScrolls {
+		_, fp.fen.previewScrollX = textView.GetScrollOffset()
+	}
+}
+
+func (fp *FilesPane) drawPreviewLines(screen tcell.Screen, result *PreviewResult, x, y, w, h int) {
+	gutterWidth := 0
+	if result.lineNumbers && fp.fen.config.PreviewLineNumbers {
+		gutterWidth = len(strconv.Itoa(len(result.lines))) + 1
+	}
+
+	totalLines := len(result.lines)
+	if result.totalLines > 0 {
+		totalLines = result.totalLines
+	}
+
+	fp.fen.previewScrollLimitY = max(0, totalLines-h)
+	fp.fen.previewScrollY = min(fp.fen.previewScrollY, fp.fen.previewScrollLimitY)
+	if fp.fen.FollowingPreview() {
+		fp.fen.previewScrollY = fp.fen.previewScrollLimitY
+	}
+	fp.fen.previewScrollX = min(fp.fen.previewScrollX, max(0, result.linesWidth-(w-gutterWidth)))
+
+	for row := 0; row < h; row++ {
+		index := fp.fen.previewScrollY + row
+		if index >= totalLines {
+			break
+		}
+
+		if gutterWidth > 0 {
+			lineNumber := strconv.Itoa(index + 1)
+			tview.Print(screen, lineNumber, x+gutterWidth-1-len(lineNumber), y+row, len(lineNumber), tview.AlignLeft, tcell.ColorGray)
+		}
+
+		// Not made yet, while scrolling a hex dump
+		if index < result.linesOffset || index-result.linesOffset >= len(result.lines) {
+			continue
+		}
+		result.lines[index-result.linesOffset].Draw(screen, x+gutterWidth, y+row, w-gutterWidth, fp.fen.previewScrollX)
+	}
+}
+
+// Previews of the entries next to the selected one in the middlePane, so they are ready when you move to them
+func (fen *Fen) PrefetchPreviewJobs(width, height int) []PreviewJob {
+	var jobs []PreviewJob
+	entries := fen.middlePane.entries.Load().([]os.DirEntry)
+	for _, offset := range []int{1, -1} {
+		index := fen.middlePane.selectedEntryIndex + offset
+		if index < 0 || index >= len(entries) {
+			continue
+		}
+
+		job, ok := fen.NewPreviewJob(filepath.Join(fen.wd, entries[index].Name()), width, height, 0, 0)
+		if !ok {
+			continue
+		}
+
+		if fen.config.PreviewSafetyBlocklist && PathMatchesListCaseInsensitive(job.ResolvedPath, DefaultPreviewBlocklistCaseInsensitive) {
+			continue
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreviewJobRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+
+	job := PreviewJob{Path: path, Rules: []PreviewOrOpenEntry{{Program: []string{"this-program-does-not-exist", "echo hello"}}}}
+	result := job.Run(context.Background())
+	if result.textView == nil || !result.fenScrolls {
+		t.Fatal("Expected the second program to be used")
+	}
+	if text := result.textView.GetText(true); text != "hello "+path+"\n" {
+		t.Fatal("Expected \"hello " + path + "\\n\", but got \"" + text + "\"")
+	}
+
+	job = PreviewJob{Path: path, Rules: []PreviewOrOpenEntry{{Program: []string{"yes"}}}, MaxOutputBytes: 1000}
+	result = job.Run(context.Background())
+	if result.textView == nil || len(result.textView.GetText(true)) != 1000 {
+		t.Fatal("Expected the output to be cut off at 1000 bytes")
+	}
+
+	slowProgram := filepath.Join(t.TempDir(), "slow.sh")
+	err := os.WriteFile(slowProgram, []byte("#!/bin/sh\nsleep 10\n"), 0o755)
+	if err != nil {
+		t.Fatal("Failed to write " + slowProgram + ": " + err.Error())
+	}
+
+	job = PreviewJob{Path: path, Rules: []PreviewOrOpenEntry{{Program: []string{slowProgram}}}, Timeout: 50 * time.Millisecond}
+	result = job.Run(context.Background())
+	if result.err == nil || !strings.Contains(result.err.Error(), "timed out") {
+		t.Fatal("Expected the preview to time out")
+	}
+
+	job = PreviewJob{Path: path, Rules: []PreviewOrOpenEntry{{Program: []string{"false"}}}}
+	result = job.Run(context.Background())
+	if result.textView != nil || result.luaScreen != nil || result.err != nil {
+		t.Fatal("Expected an empty result when every program fails")
+	}
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"gopkg.in/yaml.v3"
+)
+
+// JSON, YAML and TOML files are pretty-printed in the built-in preview, keeping the order of keys.
+// Keys are highlighted on top of the languages in syntaxhighlight.go, and files that don't parse are shown with the error and the line it's on.
+// CSV and TSV files are shown as a table, with the widest columns cut off to fit the preview
+
+const (
+	STRUCTURED_JSON = "json"
+	STRUCTURED_YAML = "yaml"
+	STRUCTURED_TOML = "toml"
+	STRUCTURED_CSV  = "csv"
+	STRUCTURED_TSV  = "tsv"
+)
+
+// The file extensions of each format, these have to be lowercase
+var structuredFormats = []struct {
+	format     string
+	extensions []string
+}{
+	{STRUCTURED_JSON, []string{".json"}},
+	{STRUCTURED_YAML, []string{".yaml", ".yml"}},
+	{STRUCTURED_TOML, []string{".toml"}},
+	{STRUCTURED_CSV, []string{".csv"}},
+	{STRUCTURED_TSV, []string{".tsv"}},
+}
+
+// Columns are never cut off to be narrower than this
+const minTableColumnWidth = 6
+
+const tableColumnSeparator = " │ "
+
+var (
+	structuredKeyStyle       = tcell.StyleDefault.Foreground(tcell.ColorAqua)
+	structuredHeaderStyle    = tcell.StyleDefault.Bold(true) // TOML tables like "[server]" and the first row of a table
+	structuredErrorStyle     = tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+	structuredErrorLineStyle = tcell.StyleDefault.Foreground(tcell.ColorRed).Underline(true)
+	tableSeparatorStyle      = tcell.StyleDefault.Foreground(tcell.ColorGray)
+)
+
+// Like "yaml: line 3: did not find expected key"
+var yamlErrorLineRegexp = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// A file that couldn't be parsed, Line is 0 if unknown
+type StructuredParseError struct {
+	Line    int
+	Message string
+}
+
+func (err *StructuredParseError) Error() string {
+	if err.Line == 0 {
+		return err.Message
+	}
+	return "Line " + strconv.Itoa(err.Line) + ": " + err.Message
+}
+
+// Returns the format of path, or false if it isn't one of structuredFormats
+func StructuredFormatOf(path string) (string, bool) {
+	lowercasePath := strings.ToLower(path)
+	for _, structuredFormat := range structuredFormats {
+		for _, extension := range structuredFormat.extensions {
+			if strings.HasSuffix(lowercasePath, extension) {
+				return structuredFormat.format, true
+			}
+		}
+	}
+	return "", false
+}
+
+// complete is false when text is only the start of the file, then only tables are shown since the rest wouldn't parse
+func (job PreviewJob) structuredPreview(text string, complete bool, format string) (*PreviewResult, bool) {
+	language := SyntaxLanguageFor(job.Path, "")
+
+	switch format {
+	case STRUCTURED_CSV, STRUCTURED_TSV:
+		comma := ','
+		if format == STRUCTURED_TSV {
+			comma = '\t'
+		}
+
+		records, err := ParseTable(text, comma)
+		if err != nil {
+			return NewLinesPreviewResult(ParseErrorLines(HighlightLines(TextPreviewLines(text), nil), err), false), true
+		}
+		if len(records) == 0 {
+			return nil, false
+		}
+		return NewLinesPreviewResult(TableLines(records, job.Width), false), true
+	}
+
+	if !complete {
+		return nil, false
+	}
+
+	var formatted string
+	var err error
+	switch format {
+	case STRUCTURED_JSON:
+		formatted, err = PrettyJSON(text)
+	case STRUCTURED_YAML:
+		formatted, err = PrettyYAML(text)
+	case STRUCTURED_TOML:
+		formatted, err = PrettyTOML(text)
+	}
+
+	if err != nil {
+		lines := StructuredHighlightLines(TextPreviewLines(text), language, format)
+		return NewLinesPreviewResult(ParseErrorLines(lines, err), false), true
+	}
+
+	if strings.TrimSpace(formatted) == "" {
+		return nil, false
+	}
+
+	// The line numbers of a pretty-printed file wouldn't match the file
+	return NewLinesPreviewResult(StructuredHighlightLines(TextPreviewLines(formatted), language, format), false), true
+}
+
+// Indents text with 2 spaces, keeping the order of keys
+func PrettyJSON(text string) (string, error) {
+	var buffer bytes.Buffer
+	err := json.Indent(&buffer, []byte(text), "", "  ")
+	if err == nil {
+		return buffer.String(), nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset := min(int(syntaxErr.Offset), len(text))
+		return "", &StructuredParseError{Line: 1 + strings.Count(text[:offset], "\n"), Message: syntaxErr.Error()}
+	}
+	return "", &StructuredParseError{Message: err.Error()}
+}
+
+// Re-encodes every document in text indented with 2 spaces, keeping the order of keys and comments
+func PrettyYAML(text string) (string, error) {
+	var builder strings.Builder
+	encoder := yaml.NewEncoder(&builder)
+	encoder.SetIndent(2)
+
+	decoder := yaml.NewDecoder(strings.NewReader(text))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", yamlParseError(err)
+		}
+
+		err = encoder.Encode(&document)
+		if err != nil {
+			return "", &StructuredParseError{Message: err.Error()}
+		}
+	}
+
+	err := encoder.Close()
+	if err != nil {
+		return "", &StructuredParseError{Message: err.Error()}
+	}
+	return builder.String(), nil
+}
+
+func yamlParseError(err error) *StructuredParseError {
+	message := err.Error()
+	match := yamlErrorLineRegexp.FindStringSubmatch(message)
+	if match == nil {
+		return &StructuredParseError{Message: strings.TrimPrefix(message, "yaml: ")}
+	}
+
+	line, _ := strconv.Atoi(match[1])
+	return &StructuredParseError{Line: line, Message: message[len(match[0]):]}
+}
+
+// Re-encodes text with the values of tables indented by 2 spaces, keeping the order of keys.
+// Comments are left out, the TOML package doesn't keep them
+func PrettyTOML(text string) (string, error) {
+	var value map[string]any
+	meta, err := toml.Decode(text, &value)
+	if err != nil {
+		return "", tomlParseError(err)
+	}
+
+	// Where each key is first defined, the tables of an array of tables share their keys
+	order := make(map[string]int)
+	for i, key := range meta.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+
+	var builder strings.Builder
+	err = writeTOMLTable(&builder, value, nil, order)
+	if err != nil {
+		return "", &StructuredParseError{Message: err.Error()}
+	}
+	return strings.TrimPrefix(builder.String(), "\n"), nil
+}
+
+// The values of a table have to come before its tables, or they would belong to the last table
+func writeTOMLTable(builder *strings.Builder, table map[string]any, path toml.Key, order map[string]int) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	keyOrder := func(key string) int {
+		i, ok := order[append(path[:len(path):len(path)], key).String()]
+		if !ok {
+			return len(order)
+		}
+		return i
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if keyOrder(a) != keyOrder(b) {
+			return cmp.Compare(keyOrder(a), keyOrder(b))
+		}
+		return strings.Compare(a, b)
+	})
+
+	indent := strings.Repeat("  ", len(path))
+	for _, key := range keys {
+		switch table[key].(type) {
+		case map[string]any, []map[string]any:
+			continue
+		}
+
+		// Like "key = value", the encoder quotes the key and value as needed
+		var line strings.Builder
+		err := toml.NewEncoder(&line).Encode(map[string]any{key: table[key]})
+		if err != nil {
+			return err
+		}
+		builder.WriteString(indent + line.String())
+	}
+
+	for _, key := range keys {
+		keyPath := append(path[:len(path):len(path)], key)
+		switch value := table[key].(type) {
+		case map[string]any:
+			builder.WriteString("\n" + indent + "[" + keyPath.String() + "]\n")
+			err := writeTOMLTable(builder, value, keyPath, order)
+			if err != nil {
+				return err
+			}
+		case []map[string]any:
+			for _, element := range value {
+				builder.WriteString("\n" + indent + "[[" + keyPath.String() + "]]\n")
+				err := writeTOMLTable(builder, element, keyPath, order)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func tomlParseError(err error) *StructuredParseError {
+	var parseErr toml.ParseError
+	if !errors.As(err, &parseErr) {
+		return &StructuredParseError{Message: err.Error()}
+	}
+
+	message := parseErr.Message
+	if message == "" {
+		message = parseErr.Error()
+	}
+	return &StructuredParseError{Line: parseErr.Position.Line, Message: message}
+}
+
+// Rows can have a different amount of fields, and quotes don't have to be closed so a cut off file can still be shown
+func ParseTable(text string, comma rune) ([][]string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return records, &StructuredParseError{Line: parseErr.Line, Message: parseErr.Err.Error()}
+		}
+		if err != nil {
+			return records, &StructuredParseError{Message: err.Error()}
+		}
+		records = append(records, record)
+	}
+}
+
+// The error, followed by lines with the line of the error marked
+func ParseErrorLines(lines []StyledLine, err error) []StyledLine {
+	message := err.Error()
+	result := []StyledLine{{Text: message, Spans: []StyledSpan{{Start: 0, End: len(message), Style: structuredErrorStyle}}}, {}}
+
+	var parseErr *StructuredParseError
+	if errors.As(err, &parseErr) && parseErr.Line > 0 && parseErr.Line <= len(lines) {
+		line := &lines[parseErr.Line-1]
+		line.Spans = []StyledSpan{{Start: 0, End: len(line.Text), Style: structuredErrorLineStyle}}
+	}
+
+	return append(result, lines...)
+}
+
+// Highlights lines with language, with keys and TOML tables highlighted on top. language can be nil
+func StructuredHighlightLines(lines []string, language *SyntaxLanguage, format string) []StyledLine {
+	if language == nil {
+		return HighlightLines(lines, nil)
+	}
+
+	styledLines := make([]StyledLine, len(lines))
+	var state syntaxState
+	blockScalarIndent := -1 // Lines indented further than this are in a YAML block scalar, like after "key: |"
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if blockScalarIndent != -1 {
+			if indent == len(line) || indent > blockScalarIndent {
+				styledLines[i] = StyledLine{Text: line}
+				if indent < len(line) {
+					styledLines[i].Spans = []StyledSpan{{Start: indent, End: len(line), Style: syntaxStringStyle}}
+				}
+				continue
+			}
+			blockScalarIndent = -1
+		}
+
+		// Continuing a multiline string or comment, like a TOML """ string
+		if state.commentEnd != "" || state.inString != nil {
+			styledLines[i] = language.highlightLine(line, &state)
+			continue
+		}
+
+		start, end, style := structuredKey(line, indent, format, language)
+		styledLine := language.highlightLine(line[end:], &state)
+		styledLine.Text = line
+		for j := range styledLine.Spans {
+			styledLine.Spans[j].Start += end
+			styledLine.Spans[j].End += end
+		}
+		if end > start {
+			styledLine.Spans = append([]StyledSpan{{Start: start, End: end, Style: style}}, styledLine.Spans...)
+		}
+		styledLines[i] = styledLine
+
+		if format == STRUCTURED_YAML {
+			value := strings.TrimLeft(strings.TrimPrefix(line[end:], ":"), " ")
+			if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+				// The lines of "- |" are only indented past the "-"
+				blockScalarIndent = indent
+				if end > start {
+					blockScalarIndent = start
+				}
+			}
+		}
+	}
+	return styledLines
+}
+
+// Returns where the key (or TOML table) starts and ends on the line, end is start if there is none
+func structuredKey(line string, indent int, format string, language *SyntaxLanguage) (int, int, tcell.Style) {
+	start := indent
+	switch format {
+	case STRUCTURED_JSON:
+		if end, ok := structuredQuotedKeyEnd(line, start, language); ok {
+			return start, end, structuredKeyStyle
+		}
+	case STRUCTURED_YAML:
+		// List items like "- key: value"
+		for strings.HasPrefix(line[start:], "- ") {
+			start += 2
+			start += len(line[start:]) - len(strings.TrimLeft(line[start:], " "))
+		}
+
+		if end, ok := structuredQuotedKeyEnd(line, start, language); ok {
+			return start, end, structuredKeyStyle
+		}
+
+		rest := line[start:]
+		if rest == "" || strings.ContainsRune("#[{\"'|>&*!%@`-", rune(rest[0])) {
+			return start, start, tcell.StyleDefault
+		}
+
+		end := strings.Index(rest, ": ")
+		if end == -1 && strings.HasSuffix(rest, ":") {
+			end = len(rest) - 1
+		}
+		if end > 0 && !strings.Contains(rest[:end], " #") {
+			return start, start + end, structuredKeyStyle
+		}
+	case STRUCTURED_TOML:
+		if strings.HasPrefix(line[start:], "[") {
+			end := strings.IndexByte(line[start:], ']')
+			if end == -1 {
+				return start, len(line), structuredHeaderStyle
+			}
+			end += start + 1
+			// Arrays of tables like "[[products]]"
+			if end < len(line) && line[end] == ']' {
+				end++
+			}
+			return start, end, structuredHeaderStyle
+		}
+
+		// Keys can be quoted, like "a.b".c = 1
+		for i := start; i < len(line); i++ {
+			switch line[i] {
+			case '"', '\'':
+				end := strings.IndexByte(line[i+1:], line[i])
+				if end == -1 {
+					return start, start, tcell.StyleDefault
+				}
+				i += end + 1
+			case '=':
+				return start, start + len(strings.TrimRight(line[start:i], " ")), structuredKeyStyle
+			case '#':
+				return start, start, tcell.StyleDefault
+			}
+		}
+	}
+	return start, start, tcell.StyleDefault
+}
+
+// Returns the end of a string at start, if it's followed by a ':'
+func structuredQuotedKeyEnd(line string, start int, language *SyntaxLanguage) (int, bool) {
+	for i := range language.Strings {
+		syntaxString := &language.Strings[i]
+		if !strings.HasPrefix(line[start:], syntaxString.Start) {
+			continue
+		}
+
+		end, ended := scanSyntaxString(line, start+len(syntaxString.Start), syntaxString)
+		return end, ended && strings.HasPrefix(line[end:], ":")
+	}
+	return 0, false
+}
+
+// Newlines and other control characters in a cell would break the table
+func tableCellText(cell string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return '?'
+		}
+		return r
+	}, cell)
+}
+
+// Lines up the columns of records, with the first one as the header. Columns are cut off so the table fits in width when possible
+func TableLines(records [][]string, width int) []StyledLine {
+	columns := 0
+	for _, record := range records {
+		columns = max(columns, len(record))
+	}
+
+	cells := make([][]string, len(records))
+	widths := make([]int, columns)
+	numeric := make([]bool, columns) // Right-aligned, when every value below the header is a number
+	for i := range numeric {
+		numeric[i] = len(records) > 1
+	}
+
+	for i, record := range records {
+		cells[i] = make([]string, columns)
+		for j, cell := range record {
+			cells[i][j] = tableCellText(cell)
+			widths[j] = max(widths[j], runewidth.StringWidth(cells[i][j]))
+
+			if i > 0 && cell != "" {
+				if _, err := strconv.ParseFloat(strings.TrimSpace(cell), 64); err != nil {
+					numeric[j] = false
+				}
+			}
+		}
+	}
+
+	fitTableColumns(widths, width-runewidth.StringWidth(tableColumnSeparator)*(columns-1))
+
+	var lines []StyledLine
+	for i, row := range cells {
+		var builder strings.Builder
+		var spans []StyledSpan
+		for j, cell := range row {
+			if j > 0 {
+				spans = append(spans, StyledSpan{Start: builder.Len(), End: builder.Len() + len(tableColumnSeparator), Style: tableSeparatorStyle})
+				builder.WriteString(tableColumnSeparator)
+			}
+
+			cell = runewidth.Truncate(cell, widths[j], "…")
+			if numeric[j] && i > 0 {
+				cell = runewidth.FillLeft(cell, widths[j])
+			} else if j < columns-1 {
+				cell = runewidth.FillRight(cell, widths[j])
+			}
+
+			if i == 0 {
+				spans = append(spans, StyledSpan{Start: builder.Len(), End: builder.Len() + len(cell), Style: structuredHeaderStyle})
+			}
+			builder.WriteString(cell)
+		}
+		lines = append(lines, StyledLine{Text: strings.TrimRight(builder.String(), " "), Spans: spans})
+
+		if i == 0 {
+			separators := make([]string, columns)
+			for j := range separators {
+				separators[j] = strings.Repeat("─", widths[j])
+			}
+			separator := strings.Join(separators, "─┼─")
+			lines = append(lines, StyledLine{Text: separator, Spans: []StyledSpan{{Start: 0, End: len(separator), Style: tableSeparatorStyle}}})
+		}
+	}
+
+	return lines
+}
+
+// Narrows the widest columns until they add up to available, but no narrower than minTableColumnWidth.
+// The columns that fit in an equal share are left alone, the rest share what's left
+func fitTableColumns(widths []int, available int) {
+	total := 0
+	for _, width := range widths {
+		total += width
+	}
+	if total <= available {
+		return
+	}
+
+	fits := make([]bool, len(widths))
+	remaining := available
+	remainingColumns := len(widths)
+	for {
+		share := remaining / max(1, remainingColumns)
+		changed := false
+		for i, width := range widths {
+			if !fits[i] && width <= share {
+				fits[i] = true
+				remaining -= width
+				remainingColumns--
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	share := max(minTableColumnWidth, remaining/max(1, remainingColumns))
+	for i, width := range widths {
+		if !fits[i] {
+			widths[i] = min(width, share)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	pretty, err := PrettyJSON(`{"b":[1,{"c":null}],"a":"x"}`)
+	expected := "{\n  \"b\": [\n    1,\n    {\n      \"c\": null\n    }\n  ],\n  \"a\": \"x\"\n}"
+	if err != nil || pretty != expected {
+		t.Fatal("Expected \"" + expected + "\" but got \"" + pretty + "\"")
+	}
+
+	_, err = PrettyJSON("{\n  \"a\": 1,\n}")
+	var parseErr *StructuredParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Fatal("Expected a parse error on line 3")
+	}
+}
+
+func TestPrettyYAML(t *testing.T) {
+	pretty, err := PrettyYAML("b:    1 # comment\na:\n    - x\n---\nc: d\n")
+	expected := "b: 1 # comment\na:\n  - x\n---\nc: d\n"
+	if err != nil || pretty != expected {
+		t.Fatal("Expected \"" + expected + "\" but got \"" + pretty + "\"")
+	}
+
+	_, err = PrettyYAML("a: b\n c: d\n")
+	var parseErr *StructuredParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 2 {
+		t.Fatal("Expected a parse error on line 2")
+	}
+}
+
+func TestPrettyTOML(t *testing.T) {
+	pretty, err := PrettyTOML("# comment\nb = 1\n[server]\nport    = 80\nhost = \"a\"\n[[products]]\nname = \"x\"\n[server.\"x.y\"]\nq = [1,2]\n[[products]]\nsku = 2\nname = \"y\"\na = { c = 1 }\n")
+	expected := "b = 1\n\n[server]\n  port = 80\n  host = \"a\"\n\n  [server.\"x.y\"]\n    q = [1, 2]\n\n[[products]]\n  name = \"x\"\n\n[[products]]\n  name = \"y\"\n  sku = 2\n\n  [products.a]\n    c = 1\n"
+	if err != nil || pretty != expected {
+		t.Fatal("Expected \"" + expected + "\" but got \"" + pretty + "\"")
+	}
+
+	_, err = PrettyTOML("a = 1\n\na = 2\n")
+	var parseErr *StructuredParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Fatal("Expected a parse error on line 3")
+	}
+}
+
+func TestStructuredHighlightLines(t *testing.T) {
+	tests := []struct {
+		path  string
+		lines []string
+		keys  []string // The key highlighted on each line
+	}{
+		{"a.json", []string{"{", "  \"a\": \"b:c\",", "  \"d\": [", "    \"e\""}, []string{"", "\"a\"", "\"d\"", ""}},
+		{"a.yaml", []string{"a: b", "c:", "  - d: e # f: g", "  - h", "i: |", "  j: k", "l: m"}, []string{"a", "c", "d", "", "i", "", "l"}},
+		{"a.toml", []string{"[server]", "port = 80", "\"a=b\".c = 1", "d = \"\"\"", "e = f", "\"\"\""}, []string{"[server]", "port", "\"a=b\".c", "d", "", ""}},
+	}
+
+	for _, test := range tests {
+		format, _ := StructuredFormatOf(test.path)
+		styledLines := StructuredHighlightLines(test.lines, SyntaxLanguageFor(test.path, ""), format)
+		for i, styledLine := range styledLines {
+			key := ""
+			if len(styledLine.Spans) > 0 && (styledLine.Spans[0].Style == structuredKeyStyle || styledLine.Spans[0].Style == structuredHeaderStyle) {
+				key = styledLine.Text[styledLine.Spans[0].Start:styledLine.Spans[0].End]
+			}
+			if key != test.keys[i] {
+				t.Fatal("Expected the key \"" + test.keys[i] + "\" but got \"" + key + "\" on \"" + styledLine.Text + "\" in " + test.path)
+			}
+		}
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	records, err := ParseTable("a\tb\n\"c\"\td\te\n", '\t')
+	if err != nil || len(records) != 2 || len(records[1]) != 3 || records[1][0] != "c" {
+		t.Fatal("Expected 2 rows with the quotes removed")
+	}
+
+	records, err = ParseTable("a,b\n\"c,d\n", ',')
+	if err != nil || len(records) != 2 || records[1][0] != "c,d\n" {
+		t.Fatal("Expected an unclosed quote to last until the end")
+	}
+}
+
+func TestTableLines(t *testing.T) {
+	records := [][]string{
+		{"name", "size", "description"},
+		{"a.txt", "12", "A very long description that doesn't fit"},
+		{"b.txt", "3.5"},
+	}
+
+	lines := TableLines(records, 200)
+	expected := []string{
+		"name  │ size │ description",
+		"──────┼──────┼─────────────────────────────────────────",
+		"a.txt │   12 │ A very long description that doesn't fit",
+		"b.txt │  3.5 │",
+	}
+	if len(lines) != len(expected) {
+		t.Fatal("Expected " + strconv.Itoa(len(expected)) + " lines, but got " + strconv.Itoa(len(lines)))
+	}
+	for i, line := range lines {
+		if line.Text != expected[i] {
+			t.Fatal("Expected \"" + expected[i] + "\" but got \"" + line.Text + "\"")
+		}
+	}
+
+	for _, width := range []int{40, 30} {
+		lines = TableLines(records, width)
+		if lines[2].Width() != width || !strings.HasSuffix(lines[2].Text, "…") {
+			t.Fatal("Expected the last column to be cut off at a width of " + strconv.Itoa(width) + ", but got \"" + lines[2].Text + "\"")
+		}
+	}
+
+	// Narrower than minTableColumnWidth for each column, it has to be scrolled instead
+	lines = TableLines(records, 10)
+	if runewidth.StringWidth(lines[2].Text) <= 10 {
+		t.Fatal("Expected columns to not be narrower than " + strconv.Itoa(minTableColumnWidth))
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// A small highlighter for the built-in text preview, it only knows about comments, strings, numbers and keywords.
+// Every file type in codeTypes has a language here, along with the formats in structuredpreview.go
+
+type SyntaxString struct {
+	Start     string
+	End       string
+	Multiline bool
+	Escapes   bool // Whether a backslash escapes the next character
+}
+
+type SyntaxLanguage struct {
+	Extensions []string
+	Shebangs   []string // Program names in a "#!" first line, for files without an extension
+
+	LineComments            []string
+	LineCommentsOnlyAtStart bool // The line comments are only comments as the first thing on a line, like "REM" in batch files
+	BlockComments           [][2]string
+	Strings                 []SyntaxString
+
+	Keywords        []string
+	Builtins        []string
+	CaseInsensitive bool
+	Preprocessor    rune // Words starting with it are highlighted as keywords, like "#include"
+	Variables       rune // Words starting with it are highlighted as builtins, like "$HOME"
+
+	keywords map[string]bool
+	builtins map[string]bool
+}
+
+var (
+	syntaxCommentStyle = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	syntaxStringStyle  = tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	syntaxNumberStyle  = tcell.StyleDefault.Foreground(tcell.ColorFuchsia)
+	syntaxKeywordStyle = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	syntaxBuiltinStyle = tcell.StyleDefault.Foreground(tcell.ColorTeal)
+)
+
+var cStrings = []SyntaxString{{Start: "\"", End: "\"", Escapes: true}, {Start: "'", End: "'", Escapes: true}}
+
+var SyntaxLanguages = []*SyntaxLanguage{
+	{
+		Extensions:    []string{".go"},
+		LineComments:  []string{"//"},
+		BlockComments: [][2]string{{"/*", "*/"}},
+		Strings:       append([]SyntaxString{{Start: "`", End: "`", Multiline: true}}, cStrings...),
+		Keywords:      []string{"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select", "struct", "switch", "type", "var"},
+		Builtins:      []string{"bool", "byte", "complex64", "complex128", "error", "float32", "float64", "int", "int8", "int16", "int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "any", "true", "false", "nil", "iota", "append", "cap", "clear", "close", "complex", "copy", "delete", "imag", "len", "make", "max", "min", "new", "panic", "print", "println", "real", "recover"},
+	},
+	{
+		Extensions:    []string{".c", ".h", ".cpp", ".cxx", ".cc", ".hpp", ".hxx"},
+		LineComments:  []string{"//"},
+		BlockComments: [][2]string{{"/*", "*/"}},
+		Strings:       cStrings,
+		Keywords:      []string{"auto", "break", "case", "char", "const", "continue", "default", "do", "double", "else", "enum", "extern", "float", "for", "goto", "if", "inline", "int", "long", "register", "restrict", "return", "short", "signed", "sizeof", "static", "struct", "switch", "typedef", "union", "unsigned", "void", "volatile", "while", "bool", "class", "namespace", "template", "typename", "public", "private", "protected", "virtual", "override", "new", "delete", "this", "using", "try", "catch", "throw", "const_cast", "static_cast", "dynamic_cast", "reinterpret_cast", "constexpr", "noexcept", "operator", "friend", "explicit", "mutable", "auto"},
+		Builtins:      []string{"true", "false", "NULL", "nullptr", "size_t", "int8_t", "int16_t", "int32_t", "int64_t", "uint8_t", "uint16_t", "uint32_t", "uint64_t", "std"},
+		Preprocessor:  '#',
+	},
+	{
+		Extensions:   []string{".py"},
+		Shebangs:     []string{"python", "python3", "python2"},
+		LineComments: []string{"#"},
+		Strings: []SyntaxString{
+			{Start: "\"\"\"", End: "\"\"\"", Multiline: true, Escapes: true},
+			{Start: "'''", End: "'''", Multiline: true, Escapes: true},
+			{Start: "\"", End: "\"", Escapes: true},
+			{Start: "'", End: "'", Escapes: true},
+		},
+		Keywords: []string{"False", "None", "True", "and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield", "match", "case"},
+		Builtins: []string{"print", "len", "range", "int", "str", "float", "list", "dict", "set", "tuple", "bool", "open", "super", "self", "isinstance", "type", "object", "Exception", "enumerate", "zip", "map", "filter", "sorted", "min", "max", "sum", "abs", "any", "all"},
+	},
+	{
+		Extensions:   []string{".sh", ".bash"},
+		Shebangs:     []string{"sh", "bash", "zsh", "dash", "ksh"},
+		LineComments: []string{"#"},
+		Strings:      []SyntaxString{{Start: "\"", End: "\"", Multiline: true, Escapes: true}, {Start: "'", End: "'", Multiline: true}},
+		Keywords:     []string{"if", "then", "else", "elif", "fi", "case", "esac", "for", "while", "until", "do", "done", "in", "function", "select", "time", "return", "exit", "local", "export", "readonly", "declare", "unset", "shift", "break", "continue", "source", "alias"},
+		Builtins:     []string{"echo", "printf", "read", "cd", "test", "eval", "exec", "set", "trap", "wait", "true", "false"},
+		Variables:    '$',
+	},
+	{
+		Extensions:    []string{".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs"},
+		Shebangs:      []string{"node"},
+		LineComments:  []string{"//"},
+		BlockComments: [][2]string{{"/*", "*/"}},
+		Strings:       append([]SyntaxString{{Start: "`", End: "`", Multiline: true, Escapes: true}}, cStrings...),
+		Keywords:      []string{"break", "case", "catch", "class", "const", "continue", "debugger", "default", "delete", "do", "else", "export", "extends", "finally", "for", "function", "if", "import", "in", "instanceof", "let", "new", "return", "super", "switch", "this", "throw", "try", "typeof", "var", "void", "while", "with", "yield", "async", "await", "of", "static", "get", "set", "from", "as", "interface", "type", "enum", "implements", "private", "public", "protected", "readonly", "declare", "namespace", "abstract", "keyof"},
+		Builtins:      []string{"true", "false", "null", "undefined", "NaN", "Infinity", "console", "Object", "Array", "String", "Number", "Boolean", "Promise", "Map", "Set", "JSON", "Math", "Date", "Error", "any", "string", "number", "boolean", "never", "unknown"},
+	},
+	{
+		Extensions:    []string{".rs"},
+		LineComments:  []string{"//"},
+		BlockComments: [][2]string{{"/*", "*/"}},
+		// Not single quotes, since they are also used for lifetimes like 'a
+		Strings:  []SyntaxString{{Start: "\"", End: "\"", Multiline: true, Escapes: true}},
+		Keywords: []string{"as", "async", "await", "break", "const", "continue", "crate", "dyn", "else", "enum", "extern", "fn", "for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref", "return", "self", "Self", "static", "struct", "super", "trait", "type", "unsafe", "use", "where", "while"},
+		Builtins: []string{"true", "false", "i8", "i16", "i32", "i64", "i128", "isize", "u8", "u16", "u32", "u64", "u128", "usize", "f32", "f64", "bool", "char", "str", "String", "Vec", "Option", "Result", "Some", "None", "Ok", "Err", "Box"},
+	},
+	{
+		Extensions:    []string{".lua"},
+		Shebangs:      []string{"lua", "luajit"},
+		LineComments:  []string{"--"},
+		BlockComments: [][2]string{{"--[[", "]]"}},
+		Strings:       append([]SyntaxString{{Start: "[[", End: "]]", Multiline: true}}, cStrings...),
+		Keywords:      []string{"and", "break", "do", "else", "elseif", "end", "for", "function", "goto", "if", "in", "local", "not", "or", "repeat", "return", "then", "until", "while"},
+		Builtins:      []string{"true", "false", "nil", "print", "pairs", "ipairs", "require", "type", "tostring", "tonumber", "string", "table", "math", "io", "os", "error", "pcall", "select", "setmetatable", "getmetatable"},
+	},
+	{
+		Extensions:              []string{".vim"},
+		LineComments:            []string{"\""},
+		LineCommentsOnlyAtStart: true,
+		Strings:                 []SyntaxString{{Start: "'", End: "'"}, {Start: "\"", End: "\"", Escapes: true}},
+		Keywords:                []string{"if", "else", "elseif", "endif", "for", "endfor", "while", "endwhile", "function", "endfunction", "fun", "endfun", "let", "set", "call", "return", "autocmd", "augroup", "command", "map", "nnoremap", "inoremap", "vnoremap", "noremap", "syntax", "highlight", "execute", "echo"},
+	},
+	{
+		Extensions:    []string{".java"},
+		LineComments:  []string{"//"},
+		BlockComments: [][2]string{{"/*", "*/"}},
+		Strings:       append([]SyntaxString{{Start: "\"\"\"", End: "\"\"\"", Multiline: true, Escapes: true}}, cStrings...),
+		Keywords:      []string{"abstract", "assert", "boolean", "break", "byte", "case", "catch", "char", "class", "const", "continue", "default", "do", "double", "else", "enum", "extends", "final", "finally", "float", "for", "goto", "if", "implements", "import", "instanceof", "int", "interface", "long", "native", "new", "package", "private", "protected", "public", "return", "short", "static", "strictfp", "super", "switch", "synchronized", "this", "throw", "throws", "transient", "try", "void", "volatile", "while", "var", "record"},
+		Builtins:      []string{"true", "false", "null", "String", "Object", "Integer", "System", "List", "Map"},
+	},
+	{
+		Extensions:      []string{".ps1"},
+		Shebangs:        []string{"pwsh"},
+		LineComments:    []string{"#"},
+		BlockComments:   [][2]string{{"<#", "#>"}},
+		Strings:         []SyntaxString{{Start: "\"", End: "\"", Multiline: true}, {Start: "'", End: "'", Multiline: true}},
+		Keywords:        []string{"begin", "break", "catch", "class", "continue", "data", "do", "dynamicparam", "else", "elseif", "end", "exit", "filter", "finally", "for", "foreach", "from", "function", "if", "in", "param", "process", "return", "switch", "throw", "trap", "try", "until", "using", "var", "while"},
+		CaseInsensitive: true,
+		Variables:       '$',
+	},
+	{
+		Extensions:              []string{".bat", ".cmd"},
+		LineComments:            []string{"rem ", "::"},
+		LineCommentsOnlyAtStart: true,
+		Strings:                 []SyntaxString{{Start: "\"", End: "\""}},
+		Keywords:                []string{"echo", "set", "if", "else", "goto", "call", "exit", "for", "in", "do", "not", "exist", "defined", "errorlevel", "setlocal", "endlocal", "pause", "shift"},
+		CaseInsensitive:         true,
+	},
+	{
+		Extensions:      []string{".vb", ".vbs", ".vbscript"},
+		LineComments:    []string{"'", "rem "},
+		Strings:         []SyntaxString{{Start: "\"", End: "\""}},
+		Keywords:        []string{"dim", "set", "if", "then", "else", "elseif", "end", "sub", "function", "for", "each", "next", "do", "loop", "while", "wend", "select", "case", "call", "exit", "return", "private", "public", "const", "new", "class", "option", "explicit", "on", "error", "resume", "with", "to", "step", "and", "or", "not", "is"},
+		Builtins:        []string{"nothing", "true", "false", "empty", "null"},
+		CaseInsensitive: true,
+	},
+	{
+		Extensions: []string{".json"},
+		Strings:    []SyntaxString{{Start: "\"", End: "\"", Escapes: true}},
+		Builtins:   []string{"true", "false", "null"},
+	},
+	{
+		Extensions:      []string{".yaml", ".yml"},
+		LineComments:    []string{"#"},
+		Strings:         []SyntaxString{{Start: "\"", End: "\"", Escapes: true}, {Start: "'", End: "'"}},
+		Builtins:        []string{"true", "false", "null", "yes", "no", "on", "off"},
+		CaseInsensitive: true,
+	},
+	{
+		Extensions:   []string{".toml"},
+		LineComments: []string{"#"},
+		Strings: []SyntaxString{
+			{Start: "\"\"\"", End: "\"\"\"", Multiline: true, Escapes: true},
+			{Start: "'''", End: "'''", Multiline: true},
+			{Start: "\"", End: "\"", Escapes: true},
+			{Start: "'", End: "'"},
+		},
+		Builtins: []string{"true", "false", "inf", "nan"},
+	},
+}
+
+func init() {
+	for _, language := range SyntaxLanguages {
+		language.keywords = make(map[string]bool)
+		language.builtins = make(map[string]bool)
+		for _, keyword := range language.Keywords {
+			language.keywords[language.normalizeWord(keyword)] = true
+		}
+		for _, builtin := range language.Builtins {
+			language.builtins[language.normalizeWord(builtin)] = true
+		}
+	}
+}
+
+func (language *SyntaxLanguage) normalizeWord(word string) string {
+	if language.CaseInsensitive {
+		return strings.ToLower(word)
+	}
+	return word
+}
+
+// Returns nil if the language is unknown. firstLine is used for files without an extension, like "#!/bin/sh"
+func SyntaxLanguageFor(path string, firstLine string) *SyntaxLanguage {
+	name := strings.ToLower(filepath.Base(path))
+	for _, language := range SyntaxLanguages {
+		for _, extension := range language.Extensions {
+			if strings.HasSuffix(name, extension) {
+				return language
+			}
+		}
+	}
+
+	if !strings.HasPrefix(firstLine, "#!") {
+		return nil
+	}
+
+	// Like "#!/bin/sh" or "#!/usr/bin/env python3"
+	fields := strings.Fields(firstLine[2:])
+	if len(fields) == 0 {
+		return nil
+	}
+	program := filepath.Base(fields[0])
+	if program == "env" && len(fields) > 1 {
+		program = fields[1]
+	}
+
+	for _, language := range SyntaxLanguages {
+		if slices.Contains(language.Shebangs, program) {
+			return language
+		}
+	}
+
+	return nil
+}
+
+// Where a multiline comment or string continues on the next line
+type syntaxState struct {
+	commentEnd string
+	inString   *SyntaxString
+}
+
+func (language *SyntaxLanguage) hasPrefix(text, prefix string) bool {
+	if language.CaseInsensitive {
+		return len(text) >= len(prefix) && strings.EqualFold(text[:len(prefix)], prefix)
+	}
+	return strings.HasPrefix(text, prefix)
+}
+
+// Returns the index after the end of the string, or len(line) if it doesn't end on this line
+func scanSyntaxString(line string, i int, syntaxString *SyntaxString) (int, bool) {
+	for i < len(line) {
+		if syntaxString.Escapes && line[i] == '\\' {
+			i += 2
+			continue
+		}
+		if strings.HasPrefix(line[i:], syntaxString.End) {
+			return i + len(syntaxString.End), true
+		}
+		i++
+	}
+	return len(line), false
+}
+
+func isSyntaxWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Highlights a line, continuing from state. Tabs should already be expanded
+func (language *SyntaxLanguage) highlightLine(line string, state *syntaxState) StyledLine {
+	styledLine := StyledLine{Text: line}
+	addSpan := func(start, end int, style tcell.Style) {
+		if end > start {
+			styledLine.Spans = append(styledLine.Spans, StyledSpan{Start: start, End: end, Style: style})
+		}
+	}
+
+	i := 0
+	if state.commentEnd != "" {
+		end := strings.Index(line, state.commentEnd)
+		if end == -1 {
+			addSpan(0, len(line), syntaxCommentStyle)
+			return styledLine
+		}
+		i = end + len(state.commentEnd)
+		addSpan(0, i, syntaxCommentStyle)
+		state.commentEnd = ""
+	} else if state.inString != nil {
+		end, ended := scanSyntaxString(line, 0, state.inString)
+		addSpan(0, end, syntaxStringStyle)
+		if !ended {
+			return styledLine
+		}
+		i = end
+		state.inString = nil
+	}
+
+	firstNonSpace := len(line) - len(strings.TrimLeft(line, " "))
+
+lineLoop:
+	for i < len(line) {
+		rest := line[i:]
+
+		for _, blockComment := range language.BlockComments {
+			if strings.HasPrefix(rest, blockComment[0]) {
+				end := strings.Index(rest[len(blockComment[0]):], blockComment[1])
+				if end == -1 {
+					addSpan(i, len(line), syntaxCommentStyle)
+					state.commentEnd = blockComment[1]
+					return styledLine
+				}
+				end += i + len(blockComment[0]) + len(blockComment[1])
+				addSpan(i, end, syntaxCommentStyle)
+				i = end
+				continue lineLoop
+			}
+		}
+
+		if !language.LineCommentsOnlyAtStart || i == firstNonSpace {
+			for _, lineComment := range language.LineComments {
+				if language.hasPrefix(rest, lineComment) {
+					addSpan(i, len(line), syntaxCommentStyle)
+					return styledLine
+				}
+			}
+		}
+
+		for j := range language.Strings {
+			syntaxString := &language.Strings[j]
+			if strings.HasPrefix(rest, syntaxString.Start) {
+				end, ended := scanSyntaxString(line, i+len(syntaxString.Start), syntaxString)
+				addSpan(i, end, syntaxStringStyle)
+				if !ended && syntaxString.Multiline {
+					state.inString = syntaxString
+				}
+				i = end
+				continue lineLoop
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		if (language.Preprocessor != 0 && r == language.Preprocessor) || (language.Variables != 0 && r == language.Variables) || isSyntaxWordRune(r) {
+			end := i + size
+			for end < len(line) {
+				next, nextSize := utf8.DecodeRuneInString(line[end:])
+				// Numbers like 1.5
+				if !isSyntaxWordRune(next) && !(next == '.' && unicode.IsDigit(r)) {
+					break
+				}
+				end += nextSize
+			}
+
+			word := line[i:end]
+			switch {
+			case unicode.IsDigit(r):
+				addSpan(i, end, syntaxNumberStyle)
+			case r == language.Preprocessor:
+				addSpan(i, end, syntaxKeywordStyle)
+			case r == language.Variables:
+				addSpan(i, end, syntaxBuiltinStyle)
+			case language.keywords[language.normalizeWord(word)]:
+				addSpan(i, end, syntaxKeywordStyle)
+			case language.builtins[language.normalizeWord(word)]:
+				addSpan(i, end, syntaxBuiltinStyle)
+			}
+			i = end
+			continue
+		}
+
+		i += size
+	}
+
+	if state.inString != nil && !state.inString.Multiline {
+		state.inString = nil
+	}
+
+	return styledLine
+}
+
+// Splits text into highlighted lines, language can be nil for plain text
+func HighlightLines(lines []string, language *SyntaxLanguage) []StyledLine {
+	styledLines := make([]StyledLine, len(lines))
+	var state syntaxState
+	for i, line := range lines {
+		if language == nil {
+			styledLines[i] = StyledLine{Text: line}
+			continue
+		}
+		styledLines[i] = language.highlightLine(line, &state)
+	}
+	return styledLines
+}
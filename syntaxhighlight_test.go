@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestSyntaxLanguageFor(t *testing.T) {
+	golang := SyntaxLanguageFor("main.go", "")
+	shell := SyntaxLanguageFor("build.sh", "")
+	python := SyntaxLanguageFor("script.py", "")
+
+	tests := []struct {
+		path      string
+		firstLine string
+		expected  *SyntaxLanguage
+	}{
+		{"/some/folder/MAIN.GO", "", golang},
+		{"build", "#!/bin/bash -e", shell},
+		{"script", "#!/usr/bin/env python3", python},
+		{"script.py", "#!/bin/sh", python},
+		{"notes.txt", "", nil},
+		{"notes", "#!/usr/bin/env", nil},
+		{"notes", "#!/unknown/shell", nil},
+	}
+
+	for i, test := range tests {
+		if SyntaxLanguageFor(test.path, test.firstLine) != test.expected {
+			t.Fatal("Test " + strconv.Itoa(i) + " got the wrong language for " + test.path)
+		}
+	}
+}
+
+func TestHighlightLines(t *testing.T) {
+	lines := HighlightLines([]string{
+		`func main() { // comment`,
+		`    x := "a /* b" /* multiline`,
+		`comment */ return 42`,
+	}, SyntaxLanguageFor("main.go", ""))
+
+	type span struct {
+		line  int
+		text  string
+		style string
+	}
+
+	styleNames := map[tcell.Style]string{
+		syntaxCommentStyle: "comment",
+		syntaxStringStyle:  "string",
+		syntaxNumberStyle:  "number",
+		syntaxKeywordStyle: "keyword",
+		syntaxBuiltinStyle: "builtin",
+	}
+
+	expected := []span{
+		{0, "func", "keyword"},
+		{0, "// comment", "comment"},
+		{1, `"a /* b"`, "string"},
+		{1, "/* multiline", "comment"},
+		{2, "comment */", "comment"},
+		{2, "return", "keyword"},
+		{2, "42", "number"},
+	}
+
+	var got []span
+	for i, line := range lines {
+		for _, s := range line.Spans {
+			got = append(got, span{i, line.Text[s.Start:s.End], styleNames[s.Style]})
+		}
+	}
+
+	if len(got) != len(expected) {
+		t.Fatal("Expected " + strconv.Itoa(len(expected)) + " spans, but got " + strconv.Itoa(len(got)))
+	}
+
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatal("Expected \"" + expected[i].text + "\" (" + expected[i].style + ") but got \"" + got[i].text + "\" (" + got[i].style + ")")
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+//lint:file-ignore ST1005 some user-visible messages are stored in error values and thus occasionally require capitalization
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Everything in Fen specific to a single tab. The current tab lives in the Fen struct itself,
+// it is only copied into its Tab when switching to another tab, see fen.saveTab() and fen.loadTab()
+type Tab struct {
+	wd      string
+	lastWD  string
+	sel     string
+	lastSel string
+	history *History
+
+	selected map[string]bool
+
+	selectingWithV               bool
+	selectingWithVStartIndex     int
+	selectingWithVEndIndex       int
+	selectedBeforeSelectingWithV map[string]bool
+
+	filter       string
+	filterFolder string
+
+	leftPane    *FilesPane
+	middlePane  *FilesPane
+	rightPane   *FilesPane
+	parentPanes []*FilesPane // Created by fen.UpdateLayout() when fen.parent_columns is more than 1
+}
+
+// Yanked files (fen.yankSelected) are intentionally not part of a tab, so you can yank in one tab and paste in another
+func (fen *Fen) saveTab(tab *Tab) {
+	tab.wd = fen.wd
+	tab.lastWD = fen.lastWD
+	tab.sel = fen.sel
+	tab.lastSel = fen.lastSel
+	tab.history = fen.history
+
+	tab.selected = fen.selected
+
+	tab.selectingWithV = fen.selectingWithV
+	tab.selectingWithVStartIndex = fen.selectingWithVStartIndex
+	tab.selectingWithVEndIndex = fen.selectingWithVEndIndex
+	tab.selectedBeforeSelectingWithV = fen.selectedBeforeSelectingWithV
+
+	tab.filter = fen.filter
+	tab.filterFolder = fen.filterFolder
+
+	tab.leftPane = fen.leftPane
+	tab.middlePane = fen.middlePane
+	tab.rightPane = fen.rightPane
+	tab.parentPanes = fen.parentPanes
+}
+
+func (fen *Fen) loadTab(tab *Tab) {
+	fen.wd = tab.wd
+	fen.lastWD = tab.lastWD
+	fen.sel = tab.sel
+	fen.lastSel = tab.lastSel
+	fen.history = tab.history
+
+	fen.selected = tab.selected
+
+	fen.selectingWithV = tab.selectingWithV
+	fen.selectingWithVStartIndex = tab.selectingWithVStartIndex
+	fen.selectingWithVEndIndex = tab.selectingWithVEndIndex
+	fen.selectedBeforeSelectingWithV = tab.selectedBeforeSelectingWithV
+
+	fen.filter = tab.filter
+	fen.filterFolder = tab.filterFolder
+
+	fen.leftPane = tab.leftPane
+	fen.middlePane = tab.middlePane
+	fen.rightPane = tab.rightPane
+	fen.parentPanes = tab.parentPanes
+}
+
+// Opens a new tab at the current path, right after the current tab, and switches to it
+func (fen *Fen) NewTab() {
+	fen.saveTab(fen.tabs[fen.currentTab])
+
+	newTab := &Tab{
+		wd:      fen.wd,
+		sel:     fen.sel,
+		history: &History{},
+
+		selected:                     map[string]bool{},
+		selectedBeforeSelectingWithV: map[string]bool{},
+
+		leftPane:   NewFilesPane(fen, LeftPane),
+		middlePane: NewFilesPane(fen, MiddlePane),
+		rightPane:  NewFilesPane(fen, RightPane),
+	}
+
+	newTab.leftPane.tab = newTab
+	newTab.middlePane.tab = newTab
+	newTab.rightPane.tab = newTab
+	newTab.leftPane.Init()
+	newTab.middlePane.Init()
+	newTab.rightPane.Init()
+	newTab.history.AddToHistory(fen.sel)
+
+	fen.tabs = append(fen.tabs[:fen.currentTab+1], append([]*Tab{newTab}, fen.tabs[fen.currentTab+1:]...)...)
+
+	fen.lastTab = fen.currentTab
+	fen.currentTab++
+	fen.loadTab(newTab)
+	fen.switchedTab()
+}
+
+// Returns an error if it is the last tab
+func (fen *Fen) CloseTab() error {
+	if len(fen.tabs) <= 1 {
+		return errors.New("Can't close the last tab")
+	}
+
+	fen.leftPane.Close()
+	fen.middlePane.Close()
+	fen.rightPane.Close()
+	for _, parentPane := range fen.parentPanes {
+		parentPane.Close()
+	}
+
+	fen.tabs = append(fen.tabs[:fen.currentTab], fen.tabs[fen.currentTab+1:]...)
+
+	// Go back to the last tab we were in, like closing a tab in a web browser
+	newTab := fen.lastTab
+	if newTab > fen.currentTab {
+		newTab--
+	}
+	newTab = max(0, min(len(fen.tabs)-1, newTab))
+
+	fen.currentTab = newTab
+	fen.lastTab = newTab
+	fen.loadTab(fen.tabs[fen.currentTab])
+	fen.switchedTab()
+	return nil
+}
+
+// Panics if index is out of range
+func (fen *Fen) SwitchTab(index int) {
+	if index < 0 || index >= len(fen.tabs) {
+		panic("In SwitchTab(): Invalid tab index " + strconv.Itoa(index))
+	}
+
+	if index == fen.currentTab {
+		return
+	}
+
+	fen.saveTab(fen.tabs[fen.currentTab])
+	fen.lastTab = fen.currentTab
+	fen.currentTab = index
+	fen.loadTab(fen.tabs[fen.currentTab])
+	fen.switchedTab()
+}
+
+// Wraps around to the first tab
+func (fen *Fen) NextTab() {
+	fen.SwitchTab((fen.currentTab + 1) % len(fen.tabs))
+}
+
+// Wraps around to the last tab
+func (fen *Fen) PreviousTab() {
+	fen.SwitchTab((fen.currentTab - 1 + len(fen.tabs)) % len(fen.tabs))
+}
+
+func (fen *Fen) switchedTab() {
+	// All tabs share the global selection
+	if fen.config.GlobalSelection && fen.initializedGlobalSelection {
+		fen.globalSelectionHandler.Apply()
+	}
+
+	fen.UpdateLayout()
+
+	// The options menu might have changed things like fen.config.HiddenFiles while in another tab
+	fen.InvalidateFolderFileCountCache()
+	fen.UpdatePanes(true)
+	fen.TriggerGitStatus()
+}
+
+// Returns the selected paths of the tab fp belongs to, since the commander layout draws a filespane from another tab
+func (fen *Fen) SelectedForFilesPane(fp *FilesPane) map[string]bool {
+	if fp == fen.leftPane || fp == fen.middlePane || fp == fen.rightPane {
+		return fen.selected
+	}
+
+	for i, tab := range fen.tabs {
+		if i == fen.currentTab {
+			continue
+		}
+
+		if fp == tab.leftPane || fp == tab.middlePane || fp == tab.rightPane {
+			return tab.selected
+		}
+	}
+
+	return fen.selected
+}
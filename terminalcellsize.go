@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// The size of a terminal cell in pixels, or 0, 0 if the terminal doesn't tell us
+func TerminalCellSize() (int, int) {
+	for _, file := range []*os.File{os.Stdout, os.Stdin} {
+		size, err := unix.IoctlGetWinsize(int(file.Fd()), unix.TIOCGWINSZ)
+		if err != nil || size.Col == 0 || size.Row == 0 {
+			continue
+		}
+		return int(size.Xpixel) / int(size.Col), int(size.Ypixel) / int(size.Row)
+	}
+	return 0, 0
+}
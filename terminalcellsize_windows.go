@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package main
+
+// The Windows console doesn't tell us the size of a cell in pixels
+func TerminalCellSize() (int, int) {
+	return 0, 0
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/png"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/image/draw"
+)
+
+// In terminals supporting the kitty graphics protocol or Sixel, images are shown with real pixels instead of colored blocks (see imagepreview.go).
+// tcell doesn't know about images, so they are written to the terminal after drawing, and the cells under them are locked so tcell doesn't draw over them
+
+// Used when TerminalCellSize() doesn't know, a common size for cells in monospace fonts
+const (
+	defaultCellWidth  = 10
+	defaultCellHeight = 20
+)
+
+// How much base64 data goes in each escape sequence, the maximum allowed by the kitty graphics protocol
+const kittyChunkSize = 4096
+
+// Each image sent to a kitty terminal gets a new ID, so we can delete it again
+var nextKittyImageID atomic.Uint32
+
+// Looks at environment variables like TERM to guess which protocol the terminal supports.
+// Asking the terminal would mean reading its reply before tcell starts, which can hang on terminals that never reply
+func DetectImageProtocol(getenv func(string) string) string {
+	term := getenv("TERM")
+	termProgram := getenv("TERM_PROGRAM")
+
+	// tmux and GNU screen only pass images through when configured to, and don't move or clear them with the rest of the screen
+	if getenv("TMUX") != "" || strings.HasPrefix(term, "screen") || strings.HasPrefix(term, "tmux") {
+		return IMAGE_PROTOCOL_NONE
+	}
+
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", termProgram == "ghostty", termProgram == "WezTerm":
+		return IMAGE_PROTOCOL_KITTY
+	case strings.HasPrefix(term, "foot"), strings.HasPrefix(term, "mlterm"), strings.HasPrefix(term, "contour"), strings.HasPrefix(term, "yaft"), termProgram == "mintty":
+		return IMAGE_PROTOCOL_SIXEL
+	}
+
+	return IMAGE_PROTOCOL_NONE
+}
+
+// An image ready to be written to the terminal
+type TerminalImage struct {
+	sequence       string // Draws the image at the cursor position
+	deleteSequence string // Removes it again, sixel images are removed by tcell drawing the cells under them
+	columns        int
+	rows           int
+}
+
+// Keeps track of the image on screen, there is only ever one (the file preview)
+type TerminalImageHandler struct {
+	screen tcell.Screen // From the last AfterDraw()
+
+	wanted  *TerminalImage // Set by Show() while drawing, reset every frame
+	wantedX int
+	wantedY int
+
+	shown  *TerminalImage
+	shownX int
+	shownY int
+
+	screenWidth  int
+	screenHeight int
+}
+
+// Call this while drawing every frame the image should be visible, it is written to the terminal in AfterDraw()
+func (handler *TerminalImageHandler) Show(image *TerminalImage, x, y int) {
+	handler.wanted = image
+	handler.wantedX = x
+	handler.wantedY = y
+}
+
+// Writes the image wanted by Show() to the terminal after the rest of the screen is drawn, or removes it.
+// It is hidden while a popup is open, since it would be drawn on top of it
+func (handler *TerminalImageHandler) AfterDraw(screen tcell.Screen, popupOpen bool) {
+	handler.screen = screen
+	wanted := handler.wanted
+	handler.wanted = nil
+	if popupOpen {
+		wanted = nil
+	}
+
+	// Resizing clears the screen
+	width, height := screen.Size()
+	if width != handler.screenWidth || height != handler.screenHeight {
+		handler.Hide()
+		handler.screenWidth = width
+		handler.screenHeight = height
+	}
+
+	if wanted == handler.shown && handler.wantedX == handler.shownX && handler.wantedY == handler.shownY {
+		return
+	}
+
+	handler.Hide()
+	if wanted == nil {
+		return
+	}
+
+	tty, ok := screen.Tty()
+	if !ok {
+		return
+	}
+
+	// The cells under the image have to be drawn first
+	screen.Show()
+
+	// The cursor is saved and restored, since tcell expects it where it left it
+	io.WriteString(tty, "\x1b7\x1b["+strconv.Itoa(handler.wantedY+1)+";"+strconv.Itoa(handler.wantedX+1)+"H"+wanted.sequence+"\x1b8")
+	screen.LockRegion(handler.wantedX, handler.wantedY, wanted.columns, wanted.rows, true)
+
+	handler.shown = wanted
+	handler.shownX = handler.wantedX
+	handler.shownY = handler.wantedY
+}
+
+// Removes the image from the screen, like before running a program in the terminal
+func (handler *TerminalImageHandler) Hide() {
+	if handler.shown == nil || handler.screen == nil {
+		return
+	}
+
+	tty, ok := handler.screen.Tty()
+	if ok && handler.shown.deleteSequence != "" {
+		io.WriteString(tty, handler.shown.deleteSequence)
+	}
+
+	// Unlocking marks the cells as changed, so tcell draws over a sixel image on the next Show()
+	handler.screen.LockRegion(handler.shownX, handler.shownY, handler.shown.columns, handler.shown.rows, false)
+	handler.shown = nil
+}
+
+// Transmits and displays a PNG image at the cursor position, without moving the cursor.
+// q=2 stops the terminal from replying, which tcell would read as key presses
+func KittyImageSequence(id uint32, pngData []byte) string {
+	data := base64.StdEncoding.EncodeToString(pngData)
+
+	var builder strings.Builder
+	for start := 0; start == 0 || start < len(data); start += kittyChunkSize {
+		end := min(len(data), start+kittyChunkSize)
+		more := "0"
+		if end < len(data) {
+			more = "1"
+		}
+
+		builder.WriteString("\x1b_G")
+		if start == 0 {
+			builder.WriteString("a=T,f=100,i=" + strconv.FormatUint(uint64(id), 10) + ",q=2,C=1,")
+		}
+		builder.WriteString("m=" + more + ";" + data[start:end] + "\x1b\\")
+	}
+
+	return builder.String()
+}
+
+// Deletes the image and frees its data in the terminal
+func KittyDeleteSequence(id uint32) string {
+	return "\x1b_Ga=d,d=I,i=" + strconv.FormatUint(uint64(id), 10) + ",q=2\x1b\\"
+}
+
+// Reduces img to the colors of a palette the size most sixel terminals support, with dithering
+func QuantizeImage(img image.Image) *image.Paletted {
+	colors := color.Palette(palette.Plan9)
+	if opaque, ok := img.(interface{ Opaque() bool }); !ok || !opaque.Opaque() {
+		colors = append(slices.Clone(palette.WebSafe), color.Transparent)
+	}
+
+	paletted := image.NewPaletted(img.Bounds(), colors)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, img.Bounds().Min)
+	return paletted
+}
+
+// Encodes img as sixels, 6 rows of pixels at a time, transparent pixels are left undrawn
+func SixelSequence(img *image.Paletted) string {
+	bounds := img.Bounds()
+
+	var builder strings.Builder
+	builder.WriteString("\x1bP0;1;0q\"1;1;" + strconv.Itoa(bounds.Dx()) + ";" + strconv.Itoa(bounds.Dy()))
+
+	transparent := make([]bool, len(img.Palette))
+	for i, c := range img.Palette {
+		_, _, _, a := c.RGBA()
+		transparent[i] = a < 0x8000
+	}
+	defined := make([]bool, len(img.Palette))
+
+	// The sixels of each color in the current band, made in one pass over the pixels
+	bands := make([][]byte, len(img.Palette))
+	for bandY := bounds.Min.Y; bandY < bounds.Max.Y; bandY += 6 {
+		if bandY != bounds.Min.Y {
+			builder.WriteByte('-') // Next band
+		}
+
+		for i := range bands {
+			bands[i] = nil
+		}
+
+		for row := 0; row < 6 && bandY+row < bounds.Max.Y; row++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				colorIndex := img.ColorIndexAt(x, bandY+row)
+				if transparent[colorIndex] {
+					continue
+				}
+
+				if bands[colorIndex] == nil {
+					bands[colorIndex] = bytes.Repeat([]byte{'?'}, bounds.Dx())
+				}
+				bands[colorIndex][x-bounds.Min.X] += 1 << row
+			}
+		}
+
+		firstColor := true
+		for colorIndex, sixels := range bands {
+			if sixels == nil {
+				continue
+			}
+
+			if !firstColor {
+				builder.WriteByte('$') // Back to the start of the band
+			}
+			firstColor = false
+
+			// Colors are defined when first used, as percentages
+			builder.WriteString("#" + strconv.Itoa(colorIndex))
+			if !defined[colorIndex] {
+				r, g, b, _ := img.Palette[colorIndex].RGBA()
+				builder.WriteString(";2;" + strconv.Itoa(int(r*100/0xffff)) + ";" + strconv.Itoa(int(g*100/0xffff)) + ";" + strconv.Itoa(int(b*100/0xffff)))
+				defined[colorIndex] = true
+			}
+			writeSixelRuns(&builder, bytes.TrimRight(sixels, "?"))
+		}
+	}
+
+	builder.WriteString("\x1b\\")
+	return builder.String()
+}
+
+// Repeated sixels are written like "!10?"
+func writeSixelRuns(builder *strings.Builder, sixels []byte) {
+	for i := 0; i < len(sixels); {
+		run := 1
+		for i+run < len(sixels) && sixels[i+run] == sixels[i] {
+			run++
+		}
+
+		if run > 3 {
+			builder.WriteString("!" + strconv.Itoa(run))
+			builder.WriteByte(sixels[i])
+		} else {
+			builder.Write(sixels[i : i+run])
+		}
+		i += run
+	}
+}
+
+func (job PreviewJob) terminalImagePreview(ctx context.Context, img image.Image, footer []StyledLine) *PreviewResult {
+	cellWidth, cellHeight := job.CellWidth, job.CellHeight
+	if cellWidth <= 0 || cellHeight <= 0 {
+		cellWidth, cellHeight = defaultCellWidth, defaultCellHeight
+	}
+
+	// Images are not enlarged, they would only get blurry. The footer lines like the caption go below it
+	bounds := img.Bounds()
+	scaled := ScaleImageToFit(img, min(job.Width*cellWidth, bounds.Dx()), min(max(1, job.Height-len(footer))*cellHeight, bounds.Dy()))
+
+	terminalImage := &TerminalImage{
+		columns: (scaled.Bounds().Dx() + cellWidth - 1) / cellWidth,
+		rows:    (scaled.Bounds().Dy() + cellHeight - 1) / cellHeight,
+	}
+
+	if job.ImageProtocol == IMAGE_PROTOCOL_KITTY {
+		var pngData bytes.Buffer
+		err := png.Encode(&pngData, scaled)
+		if err != nil {
+			return &PreviewResult{err: err}
+		}
+
+		id := nextKittyImageID.Add(1)
+		terminalImage.sequence = KittyImageSequence(id, pngData.Bytes())
+		terminalImage.deleteSequence = KittyDeleteSequence(id)
+	} else {
+		terminalImage.sequence = SixelSequence(QuantizeImage(scaled))
+	}
+
+	if ctx.Err() != nil {
+		return job.timedOutResult(ctx)
+	}
+
+	// Empty lines where the image goes
+	lines := append(make([]StyledLine, terminalImage.rows), footer...)
+	result := NewLinesPreviewResult(lines, false)
+	result.terminalImage = terminalImage
+	return result
+}
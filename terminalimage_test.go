@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSixelSequence(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{red, blue, color.Transparent})
+	img.SetColorIndex(0, 0, 0)
+	img.SetColorIndex(1, 0, 1)
+	img.SetColorIndex(0, 1, 1)
+	img.SetColorIndex(1, 1, 0)
+
+	expected := "\x1bP0;1;0q\"1;1;2;2#0;2;100;0;0@A$#1;2;0;0;100A@\x1b\\"
+	got := SixelSequence(img)
+	if got != expected {
+		t.Fatal("Expected " + strconv.Quote(expected) + " but got " + strconv.Quote(got))
+	}
+
+	// 8 rows make 2 bands, transparent pixels are skipped and repeated sixels are shortened
+	img = image.NewPaletted(image.Rect(0, 0, 5, 8), color.Palette{red, blue, color.Transparent})
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 5; x++ {
+			img.SetColorIndex(x, y, 0)
+		}
+	}
+	img.SetColorIndex(4, 0, 2)
+	img.SetColorIndex(0, 7, 1)
+
+	expected = "\x1bP0;1;0q\"1;1;5;8#0;2;100;0;0!4~}-#0@!4B$#1;2;0;0;100A\x1b\\"
+	got = SixelSequence(img)
+	if got != expected {
+		t.Fatal("Expected " + strconv.Quote(expected) + " but got " + strconv.Quote(got))
+	}
+}
+
+func TestKittyImageSequence(t *testing.T) {
+	expected := "\x1b_Ga=T,f=100,i=7,q=2,C=1,m=0;aGVsbG8=\x1b\\"
+	got := KittyImageSequence(7, []byte("hello"))
+	if got != expected {
+		t.Fatal("Expected " + strconv.Quote(expected) + " but got " + strconv.Quote(got))
+	}
+
+	// 3072 bytes is 4096 in base64, so this is split in 3 escape sequences
+	data := bytes.Repeat([]byte{0}, 3072*2+3)
+	chunk := strings.Repeat("A", kittyChunkSize)
+	expected = "\x1b_Ga=T,f=100,i=8,q=2,C=1,m=1;" + chunk + "\x1b\\" +
+		"\x1b_Gm=1;" + chunk + "\x1b\\" +
+		"\x1b_Gm=0;AAAA\x1b\\"
+	got = KittyImageSequence(8, data)
+	if got != expected {
+		t.Fatal("Expected a chunked sequence, but got " + strconv.Quote(got[:min(len(got), 100)]) + "...")
+	}
+
+	expected = "\x1b_Ga=d,d=I,i=8,q=2\x1b\\"
+	got = KittyDeleteSequence(8)
+	if got != expected {
+		t.Fatal("Expected " + strconv.Quote(expected) + " but got " + strconv.Quote(got))
+	}
+}
+
+func TestDetectImageProtocol(t *testing.T) {
+	tests := map[string]map[string]string{
+		IMAGE_PROTOCOL_KITTY + " (kitty)":   {"TERM": "xterm-kitty"},
+		IMAGE_PROTOCOL_KITTY + " (WezTerm)": {"TERM": "xterm-256color", "TERM_PROGRAM": "WezTerm"},
+		IMAGE_PROTOCOL_SIXEL + " (foot)":    {"TERM": "foot-extra"},
+		IMAGE_PROTOCOL_NONE + " (xterm)":    {"TERM": "xterm-256color"},
+		IMAGE_PROTOCOL_NONE + " (tmux)":     {"TERM": "tmux-256color", "KITTY_WINDOW_ID": "1", "TMUX": "/tmp/tmux-1000/default,1,0"},
+	}
+
+	for name, env := range tests {
+		expected := strings.Fields(name)[0]
+		got := DetectImageProtocol(func(key string) string { return env[key] })
+		if got != expected {
+			t.Fatal("Expected " + expected + " for " + name + ", but got " + got)
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"github.com/rivo/tview"
 )
 
@@ -33,6 +35,29 @@ func (topBar *TopBar) Draw(screen tcell.Screen) {
 
 	x, y, w, _ := topBar.GetInnerRect()
 
+	// The tab strip is drawn first so the path can't cover it up
+	if len(topBar.fen.tabs) > 1 {
+		var tabStrip strings.Builder
+		for i, tab := range topBar.fen.tabs {
+			tabWD := tab.wd
+			if i == topBar.fen.currentTab {
+				tabWD = topBar.fen.wd // The current tab is only saved when switching away from it
+			}
+
+			tabName := runewidth.Truncate(filepath.Base(tabWD), 12, "…")
+
+			if i == topBar.fen.currentTab {
+				tabStrip.WriteString("[::r]")
+			} else {
+				tabStrip.WriteString("[::d]")
+			}
+			tabStrip.WriteString(" " + strconv.Itoa(i+1) + ":" + tview.Escape(tabName) + " [-:-:-:-]")
+		}
+
+		_, tabStripLength := tview.Print(screen, tabStrip.String(), x, y, w, tview.AlignRight, tcell.ColorDefault)
+		w -= tabStripLength + 1
+	}
+
 	path := topBar.fen.sel
 
 	var username string
@@ -74,8 +99,14 @@ func (topBar *TopBar) Draw(screen tcell.Screen) {
 
 	_, pathPrintedLength := tview.Print(screen, pathText, x+1+usernameAndHostnameLength, y, w, tview.AlignLeft, tcell.ColorBlue)
 
+	filterPrintedLength := 0
+	if filter := topBar.fen.middlePane.Filter(); filter != "" {
+		_, filterPrintedLength = tview.Print(screen, "[::d]filter:[-:-:-:-] [yellow::]"+tview.Escape(filter), x+usernameAndHostnameLength+1+pathPrintedLength+1, y, w, tview.AlignLeft, tcell.ColorDefault)
+		filterPrintedLength++
+	}
+
 	if topBar.showAdditionalText {
-		tview.Print(screen, "« "+topBar.additionalText, x+usernameAndHostnameLength+1+pathPrintedLength+1, y, w, tview.AlignLeft, tcell.ColorDefault)
+		tview.Print(screen, "« "+topBar.additionalText, x+usernameAndHostnameLength+1+pathPrintedLength+1+filterPrintedLength, y, w, tview.AlignLeft, tcell.ColorDefault)
 	}
 
 	if topBar.fen.runningGitStatus {
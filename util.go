@@ -20,6 +20,7 @@ import (
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -82,6 +83,61 @@ func BytesToHumanReadableUnitString(bytes uint64, maxDecimals int) string {
 	return trimLastDecimals(strconv.FormatFloat(float64(bytes)/unitValues[len(unitValues)-1], 'f', -1, 64), maxDecimals) + " " + unitStrings[len(unitStrings)-1]
 }
 
+// Like BytesToHumanReadableUnitString(), but in powers of 1024 (KiB, MiB, ...)
+func BytesToHumanReadableUnitStringIEC(bytes uint64, maxDecimals int) string {
+	unitStrings := []string{
+		"KiB",
+		"MiB",
+		"GiB",
+		"TiB",
+		"PiB",
+		"EiB", // Largest unit that fits in 64 bits
+	}
+
+	if bytes < 1024 {
+		return strconv.FormatUint(bytes, 10) + " B"
+	}
+
+	unitValue := uint64(1024)
+	for i := range unitStrings {
+		if i == len(unitStrings)-1 || bytes < unitValue*1024 {
+			return trimLastDecimals(strconv.FormatFloat(float64(bytes)/float64(unitValue), 'f', -1, 64), maxDecimals) + " " + unitStrings[i]
+		}
+		unitValue *= 1024
+	}
+
+	panic("unreachable")
+}
+
+// Returns a short description of how long before now t was, like "5 min ago"
+func RelativeTimeString(t, now time.Time) string {
+	duration := now.Sub(t)
+	if duration < 0 {
+		return "in the future"
+	}
+
+	units := []struct {
+		duration time.Duration
+		name     string
+	}{
+		{365 * 24 * time.Hour, "y"},
+		{30 * 24 * time.Hour, "mo"},
+		{7 * 24 * time.Hour, "w"},
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "min"},
+		{time.Second, "s"},
+	}
+
+	for _, unit := range units {
+		if duration >= unit.duration {
+			return strconv.FormatInt(int64(duration/unit.duration), 10) + " " + unit.name + " ago"
+		}
+	}
+
+	return "now"
+}
+
 func PathWithEndSeparator(path string) string {
 	if strings.HasSuffix(path, string(os.PathSeparator)) {
 		return path
@@ -98,6 +154,15 @@ func PathWithoutEndSeparator(path string) string {
 	return path
 }
 
+// Returns true if path is folder or inside of it, both have to be absolute
+func PathIsInFolder(path, folder string) bool {
+	rel, err := filepath.Rel(folder, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
 // TODO: Maybe make these file functions take a fs.FileInfo from a previously done os.Stat()
 
 // stat should be from an os.Lstat(). If stat is nil, it returns an error.
@@ -172,6 +237,14 @@ func FolderFileCount(path string, hiddenFiles bool) (int, error) {
 	return len(files), nil
 }
 
+// Like "1 file" or "2 files"
+func CountText(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(count) + " " + noun + "s"
+}
+
 func FilePermissionsString(stat os.FileInfo) string {
 	var ret strings.Builder
 
@@ -477,12 +550,26 @@ var windowsExecutableTypes = []string{
 	".msi",
 }
 
-// stat should be from an os.Lstat(). If stat is nil, it returns tcell.StyleDefault
-func FileColor(stat os.FileInfo, path string) tcell.Style {
-	if stat == nil {
-		return tcell.StyleDefault
-	}
+// The categories of files which FileColor() gives different colors, in the order they are checked.
+// Also used for sorting by file type (SORT_FILE_TYPE)
+type FileCategory int
+
+const (
+	FILE_CATEGORY_FOLDER FileCategory = iota
+	FILE_CATEGORY_EXECUTABLE
+	FILE_CATEGORY_SYMLINK_TO_FOLDER
+	FILE_CATEGORY_SYMLINK
+	FILE_CATEGORY_IMAGE
+	FILE_CATEGORY_VIDEO
+	FILE_CATEGORY_ARCHIVE
+	FILE_CATEGORY_CODE
+	FILE_CATEGORY_AUDIO
+	FILE_CATEGORY_DOCUMENT
+	FILE_CATEGORY_OTHER
+	FILE_CATEGORY_SPECIAL // Devices, sockets, named pipes etc.
+)
 
+func FileCategoryOf(stat os.FileInfo, path string) FileCategory {
 	hasSuffixFromList := func(str string, list []string) bool {
 		for _, e := range list {
 			if strings.HasSuffix(strings.ToLower(str), e) {
@@ -493,49 +580,85 @@ func FileColor(stat os.FileInfo, path string) tcell.Style {
 		return false
 	}
 
-	var ret tcell.Style
-
-	if stat != nil {
-		if stat.IsDir() {
-			return ret.Foreground(tcell.ColorBlue).Bold(true)
-		} else if stat.Mode().IsRegular() {
-			if stat.Mode()&0111 != 0 || (runtime.GOOS == "windows" && hasSuffixFromList(path, windowsExecutableTypes)) { // Executable file
-				return ret.Foreground(tcell.NewRGBColor(0, 255, 0)).Bold(true) // Green
-			}
-		} else if stat.Mode()&os.ModeSymlink != 0 {
-			targetStat, err := os.Stat(path)
-			if err == nil && targetStat.IsDir() {
-				return ret.Foreground(tcell.ColorTeal).Bold(true)
-			}
-
-			return ret.Foreground(tcell.ColorTeal)
-		} else {
-			// Should not happen?
-			return ret.Foreground(tcell.ColorDarkGray)
+	if stat.IsDir() {
+		return FILE_CATEGORY_FOLDER
+	} else if stat.Mode().IsRegular() {
+		if stat.Mode()&0111 != 0 || (runtime.GOOS == "windows" && hasSuffixFromList(path, windowsExecutableTypes)) { // Executable file
+			return FILE_CATEGORY_EXECUTABLE
+		}
+	} else if stat.Mode()&os.ModeSymlink != 0 {
+		targetStat, err := os.Stat(path)
+		if err == nil && targetStat.IsDir() {
+			return FILE_CATEGORY_SYMLINK_TO_FOLDER
 		}
+
+		return FILE_CATEGORY_SYMLINK
+	} else {
+		// Should not happen?
+		return FILE_CATEGORY_SPECIAL
 	}
 
 	if hasSuffixFromList(path, imageTypes) {
-		return ret.Foreground(tcell.ColorOlive)
+		return FILE_CATEGORY_IMAGE
 	}
 
 	if hasSuffixFromList(path, videoTypes) {
-		return ret.Foreground(tcell.ColorHotPink)
+		return FILE_CATEGORY_VIDEO
 	}
 
 	if hasSuffixFromList(path, archiveTypes) {
-		return ret.Foreground(tcell.ColorRed)
+		return FILE_CATEGORY_ARCHIVE
 	}
 
 	if hasSuffixFromList(path, codeTypes) {
-		return ret.Foreground(tcell.ColorNavy)
+		return FILE_CATEGORY_CODE
 	}
 
 	if hasSuffixFromList(path, audioTypes) {
-		return ret.Foreground(tcell.ColorPurple)
+		return FILE_CATEGORY_AUDIO
 	}
 
 	if hasSuffixFromList(path, documentTypes) {
+		return FILE_CATEGORY_DOCUMENT
+	}
+
+	return FILE_CATEGORY_OTHER
+}
+
+// stat should be from an os.Lstat(). If stat is nil, it returns tcell.StyleDefault
+func FileColor(stat os.FileInfo, path string) tcell.Style {
+	if stat == nil {
+		return tcell.StyleDefault
+	}
+
+	return FileCategoryStyle(FileCategoryOf(stat, path))
+}
+
+func FileCategoryStyle(category FileCategory) tcell.Style {
+	var ret tcell.Style
+
+	switch category {
+	case FILE_CATEGORY_FOLDER:
+		return ret.Foreground(tcell.ColorBlue).Bold(true)
+	case FILE_CATEGORY_EXECUTABLE:
+		return ret.Foreground(tcell.NewRGBColor(0, 255, 0)).Bold(true) // Green
+	case FILE_CATEGORY_SYMLINK_TO_FOLDER:
+		return ret.Foreground(tcell.ColorTeal).Bold(true)
+	case FILE_CATEGORY_SYMLINK:
+		return ret.Foreground(tcell.ColorTeal)
+	case FILE_CATEGORY_SPECIAL:
+		return ret.Foreground(tcell.ColorDarkGray)
+	case FILE_CATEGORY_IMAGE:
+		return ret.Foreground(tcell.ColorOlive)
+	case FILE_CATEGORY_VIDEO:
+		return ret.Foreground(tcell.ColorHotPink)
+	case FILE_CATEGORY_ARCHIVE:
+		return ret.Foreground(tcell.ColorRed)
+	case FILE_CATEGORY_CODE:
+		return ret.Foreground(tcell.ColorNavy)
+	case FILE_CATEGORY_AUDIO:
+		return ret.Foreground(tcell.ColorPurple)
+	case FILE_CATEGORY_DOCUMENT:
 		return ret.Foreground(tcell.ColorGray)
 	}
 
@@ -562,6 +685,20 @@ func PathMatchesListCaseInsensitive(path string, matchList []string) bool {
 	return false
 }
 
+// Case-insensitive. If filter contains any of the glob characters "*?[" it is matched as a pattern, otherwise as a substring
+// Details on the pattern syntax: https://pkg.go.dev/path/filepath#Match
+func FilterMatches(filter, filename string) bool {
+	filter = strings.ToLower(filter)
+	filename = strings.ToLower(filename)
+
+	if strings.ContainsAny(filter, "*?[") {
+		matched, _ := filepath.Match(filter, filename)
+		return matched
+	}
+
+	return strings.Contains(filename, filter)
+}
+
 // We could maybe cache this to a certain extent
 func ProgramsAndDescriptionsForFile(fen *Fen) ([]string, []string) {
 	var programs []string
@@ -679,6 +816,7 @@ func OpenFile(fen *Fen, app *tview.Application, openWith string) error {
 		panic("In OpenFile(): Length of programs and descriptions weren't the same")
 	}
 
+	fen.terminalImages.Hide()
 	app.Suspend(func() {
 		for i, programOrScript := range programsAndFallbacks {
 			description := descriptions[i]
@@ -1121,3 +1259,157 @@ func CurrentWorkingDirectory() (string, error) {
 
 	return path, nil
 }
+
+// Returns the folder for data fen keeps between sessions (like marks), it's created by WriteFileAtomic() when something is saved.
+// $XDG_DATA_HOME/fen (~/.local/share/fen) on Linux/FreeBSD, otherwise the OS-specific config folder with "fen" appended
+func FenDataFolder() (string, error) {
+	var folder string
+	if runtime.GOOS == "linux" || runtime.GOOS == "freebsd" {
+		folder = os.Getenv("XDG_DATA_HOME")
+		if folder == "" || !filepath.IsAbs(folder) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			folder = filepath.Join(home, ".local", "share")
+		}
+	} else {
+		var err error
+		folder, err = os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(folder, "fen"), nil
+}
+
+// Returns the folder for temporary data shared between fen instances (like the global selection), it's created by WriteFileAtomic() when something is saved.
+// The OS-specific cache folder with "fen" appended
+func FenCacheFolder() (string, error) {
+	folder, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(folder, "fen"), nil
+}
+
+// Writes to a temporary file in the same folder and renames it to path,
+// so other fen instances reading the file never see it half-written. The folder is created if it doesn't exist
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o775)
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Chmod(perm)
+	}
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+// Creates path with ".lock" appended, waiting for other fen instances to remove theirs first.
+// Lock files older than 10 seconds are assumed to be left over from a crash, and are removed.
+// Call the returned function to unlock
+func LockFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	start := time.Now()
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o664)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		stat, statErr := os.Stat(lockPath)
+		if statErr == nil && time.Since(stat.ModTime()) > 10*time.Second {
+			os.Remove(lockPath)
+			continue
+		}
+
+		if time.Since(start) > 2*time.Second {
+			return nil, errors.New("Timed out waiting for " + lockPath + " to be removed")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Compares like strings.Compare, except numbers are compared by their value, so "file2" comes before "file10".
+// Letters are compared case-insensitively, falling back to strings.Compare when the names are otherwise equal
+func NaturalCompare(a, b string) int {
+	isDigit := func(c byte) bool {
+		return c >= '0' && c <= '9'
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			aStart, bStart := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+
+			aNumber := strings.TrimLeft(a[aStart:i], "0")
+			bNumber := strings.TrimLeft(b[bStart:j], "0")
+
+			// More digits means a bigger number
+			if len(aNumber) != len(bNumber) {
+				return len(aNumber) - len(bNumber)
+			}
+
+			if c := strings.Compare(aNumber, bNumber); c != 0 {
+				return c
+			}
+			continue
+		}
+
+		aLower, bLower := unicode.ToLower(rune(a[i])), unicode.ToLower(rune(b[j]))
+		if a[i] >= utf8.RuneSelf || b[j] >= utf8.RuneSelf {
+			aLower, bLower = rune(a[i]), rune(b[j])
+		}
+
+		if aLower != bLower {
+			return int(aLower) - int(bLower)
+		}
+
+		i++
+		j++
+	}
+
+	if remaining := (len(a) - i) - (len(b) - j); remaining != 0 {
+		return remaining
+	}
+
+	return strings.Compare(a, b)
+}
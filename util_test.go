@@ -4,6 +4,7 @@ import (
 	"slices"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 )
@@ -136,6 +137,29 @@ func TestMapStringBoolKeys(t *testing.T) {
 	}
 }
 
+func TestPathIsInFolder(t *testing.T) {
+	tests := []struct {
+		path     string
+		folder   string
+		expected bool
+	}{
+		{"/a", "/a", true},
+		{"/a/sub", "/a", true},
+		{"/a/sub/deeper", "/a", true},
+		{"/a", "/a/sub", false},
+		{"/ab", "/a", false},
+		{"/b/..a", "/b", true},
+		{"/", "/a", false},
+		{"/a", "/", true},
+	}
+
+	for _, test := range tests {
+		if PathIsInFolder(test.path, test.folder) != test.expected {
+			t.Fatal("Expected PathIsInFolder(\"" + test.path + "\", \"" + test.folder + "\") to be " + strconv.FormatBool(test.expected))
+		}
+	}
+}
+
 func TestStringSliceHasDuplicate(t *testing.T) {
 	s := []string{"hello", "world", "", "hi"}
 	_, err := StringSliceHasDuplicate(s)
@@ -175,3 +199,103 @@ func TestRandomStringPathSafe(t *testing.T) {
 		t.Fatal("Expected \"\" (for -1 length), but got: " + r)
 	}
 }
+
+func TestFilterMatches(t *testing.T) {
+	type filterAndFilename struct {
+		filter   string
+		filename string
+	}
+
+	expectedResults := map[filterAndFilename]bool{
+		{"log", "build.log"}:          true,
+		{"LOG", "build.log"}:          true,
+		{"log", "build.LOG"}:          true,
+		{"*.log", "build.log"}:        true,
+		{"*.log", "build.log.1"}:      false,
+		{"build", "build.log"}:        true,
+		{"txt", "build.log"}:          false,
+		{"b?ild*", "build.log"}:       true,
+		{"[ab]uild.log", "build.log"}: true,
+		{"[", "build.log"}:            false, // Malformed pattern
+	}
+
+	for input, expected := range expectedResults {
+		got := FilterMatches(input.filter, input.filename)
+		if got != expected {
+			t.Fatalf("Expected " + strconv.FormatBool(expected) + " for filter \"" + input.filter + "\" and filename \"" + input.filename + "\", but got " + strconv.FormatBool(got))
+		}
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	sortedNames := []string{
+		"",
+		"01",
+		"1",
+		"2",
+		"10",
+		"a",
+		"file",
+		"file1",
+		"file2",
+		"File3",
+		"file10",
+		"file10a",
+		"file10b",
+		"file100",
+		"x2y1",
+		"x2y10",
+		"x10y1",
+	}
+
+	for i, a := range sortedNames {
+		for j, b := range sortedNames {
+			got := NaturalCompare(a, b)
+			if (i < j && got >= 0) || (i > j && got <= 0) || (i == j && got != 0) {
+				t.Fatalf("Wrong order for \"" + a + "\" and \"" + b + "\", got " + strconv.Itoa(got))
+			}
+		}
+	}
+}
+
+func TestBytesToHumanReadableUnitStringIEC(t *testing.T) {
+	expectedResults := map[uint64]string{
+		0:                    "0 B",
+		1023:                 "1023 B",
+		1024:                 "1 KiB",
+		1536:                 "1.5 KiB",
+		1048576:              "1 MiB",
+		1073741824:           "1 GiB",
+		1152921504606846976:  "1 EiB",
+		18446744073709551615: "16 EiB",
+	}
+
+	for byteCount, expected := range expectedResults {
+		got := BytesToHumanReadableUnitStringIEC(byteCount, 3)
+		if got != expected {
+			t.Fatalf("Expected " + expected + ", but got " + got)
+		}
+	}
+}
+
+func TestRelativeTimeString(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	expectedResults := map[time.Duration]string{
+		0:                    "now",
+		-time.Hour:           "in the future",
+		59 * time.Second:     "59 s ago",
+		90 * time.Second:     "1 min ago",
+		3 * time.Hour:        "3 h ago",
+		49 * time.Hour:       "2 d ago",
+		15 * 24 * time.Hour:  "2 w ago",
+		70 * 24 * time.Hour:  "2 mo ago",
+		800 * 24 * time.Hour: "2 y ago",
+	}
+
+	for ago, expected := range expectedResults {
+		got := RelativeTimeString(now.Add(-ago), now)
+		if got != expected {
+			t.Fatalf("Expected " + expected + ", but got " + got)
+		}
+	}
+}
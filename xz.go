@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Every block of an xz file says how large a dictionary it needs, which the xz package allocates before decompressing it.
+// The blocks are found through the index at the end of each stream, https://tukaani.org/xz/xz-file-format.txt
+
+const (
+	xzHeaderBytes = 12 // The stream header and footer
+	xzFilterLZMA2 = 0x21
+)
+
+// Larger indexes aren't read, it's 2 small numbers per block
+const maxXZIndexBytes = 16 * 1024 * 1024
+
+// Returns the largest dictionary size needed by a block of the xz file
+func XZDictionarySize(file io.ReaderAt, fileSize int64) (int64, error) {
+	var largest int64
+	end := fileSize
+	padding := make([]byte, 4)
+	footer := make([]byte, xzHeaderBytes)
+	for end > 0 {
+		// Zero bytes between and after streams
+		_, err := file.ReadAt(padding, end-4)
+		if err != nil {
+			return 0, err
+		}
+		if binary.LittleEndian.Uint32(padding) == 0 {
+			end -= 4
+			continue
+		}
+
+		_, err = file.ReadAt(footer, end-xzHeaderBytes)
+		if err != nil {
+			return 0, err
+		}
+		if string(footer[10:]) != "YZ" {
+			return 0, errors.New("Invalid xz stream footer")
+		}
+
+		indexSize := (int64(binary.LittleEndian.Uint32(footer[4:])) + 1) * 4
+		if indexSize > maxXZIndexBytes || indexSize > end-2*xzHeaderBytes {
+			return 0, errors.New("Invalid xz index size")
+		}
+		index := make([]byte, indexSize)
+		_, err = file.ReadAt(index, end-xzHeaderBytes-indexSize)
+		if err != nil {
+			return 0, err
+		}
+
+		blockSizes, err := xzBlockSizes(index)
+		if err != nil {
+			return 0, err
+		}
+
+		var blocksSize int64
+		for _, size := range blockSizes {
+			blocksSize += size
+		}
+		streamStart := end - xzHeaderBytes - indexSize - blocksSize - xzHeaderBytes
+		if blocksSize < 0 || streamStart < 0 {
+			return 0, errors.New("Invalid xz index")
+		}
+
+		offset := streamStart + xzHeaderBytes
+		for _, size := range blockSizes {
+			dictionarySize, err := xzBlockDictionarySize(file, offset)
+			if err != nil {
+				return 0, err
+			}
+			largest = max(largest, dictionarySize)
+			offset += size
+		}
+
+		end = streamStart
+	}
+
+	return largest, nil
+}
+
+// The size of each block including its padding, from the records of an index
+func xzBlockSizes(index []byte) ([]int64, error) {
+	if len(index) == 0 || index[0] != 0 {
+		return nil, errors.New("Invalid xz index")
+	}
+
+	position := 1
+	count, err := xzVarint(index, &position)
+	if err != nil {
+		return nil, err
+	}
+	// Each record is at least 2 bytes
+	if count > uint64(len(index))/2 {
+		return nil, errors.New("Invalid xz index")
+	}
+
+	sizes := make([]int64, count)
+	for i := range sizes {
+		unpaddedSize, err := xzVarint(index, &position)
+		if err != nil {
+			return nil, err
+		}
+		_, err = xzVarint(index, &position) // The uncompressed size
+		if err != nil {
+			return nil, err
+		}
+		if unpaddedSize > 1<<62 {
+			return nil, errors.New("Invalid xz index")
+		}
+		sizes[i] = (int64(unpaddedSize) + 3) / 4 * 4
+	}
+	return sizes, nil
+}
+
+// The dictionary size of the LZMA2 filter in the block header at offset, 0 if it has none
+func xzBlockDictionarySize(file io.ReaderAt, offset int64) (int64, error) {
+	header := make([]byte, 1024)
+	_, err := file.ReadAt(header[:1], offset)
+	if err != nil {
+		return 0, err
+	}
+	header = header[:(int(header[0])+1)*4]
+	_, err = file.ReadAt(header, offset)
+	if err != nil {
+		return 0, err
+	}
+	if len(header) < 2 {
+		return 0, errors.New("Invalid xz block header")
+	}
+
+	flags := header[1]
+	position := 2
+	// The compressed and uncompressed sizes
+	for _, flag := range []byte{0x40, 0x80} {
+		if flags&flag != 0 {
+			_, err = xzVarint(header, &position)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	filters := int(flags&0x03) + 1
+	for i := 0; i < filters; i++ {
+		id, err := xzVarint(header, &position)
+		if err != nil {
+			return 0, err
+		}
+		propertiesSize, err := xzVarint(header, &position)
+		if err != nil {
+			return 0, err
+		}
+		if propertiesSize > uint64(len(header)-position) {
+			return 0, errors.New("Invalid xz block header")
+		}
+
+		if id == xzFilterLZMA2 && propertiesSize == 1 {
+			bits := header[position] & 0x3f
+			if bits > 40 {
+				return 0, errors.New("Invalid xz dictionary size")
+			}
+			if bits == 40 {
+				return 0xffffffff, nil
+			}
+			return int64(2|bits&1) << (bits/2 + 11), nil
+		}
+		position += int(propertiesSize)
+	}
+	return 0, nil
+}
+
+// Reads a variable length integer of up to 9 bytes at data[*position], and moves position past it
+func xzVarint(data []byte, position *int) (uint64, error) {
+	var value uint64
+	for i := 0; i < 9; i++ {
+		if *position >= len(data) {
+			return 0, errors.New("Invalid xz variable length integer")
+		}
+		b := data[*position]
+		*position++
+		value |= uint64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("Invalid xz variable length integer")
+}